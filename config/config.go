@@ -4,6 +4,7 @@ import (
 	"bytes"
 	_ "embed"
 	"fmt"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -16,6 +17,10 @@ type Config struct {
 	Http        HttpConfig         `mapstructure:"http"`
 	Elastic     ElasticConfig      `mapstructure:"elasticsearch"`
 	DataSources []DataSourceConfig `mapstructure:"datasources"`
+	Webhook     WebhookConfig      `mapstructure:"webhook"`
+	Indexer     IndexerConfig      `mapstructure:"indexer"`
+	Harvest     HarvestConfig      `mapstructure:"harvest"`
+	Thumbnails  ThumbnailConfig    `mapstructure:"thumbnails"`
 }
 
 // HttpConfig holds HTTP server configuration parameters such as address binding.
@@ -30,11 +35,66 @@ type ElasticConfig struct {
 	Address string `mapstructure:"address"`
 }
 
+// IndexerConfig holds the settings of the background indexer.
+type IndexerConfig struct {
+	// APIOnly runs the replica without scanning data sources or retrying failed jobs. Items pushed
+	// through webhooks are still indexed.
+	APIOnly bool `mapstructure:"apiOnly"`
+	// LeaseTTL is how long a replica keeps the lease on a data source without renewing it. When a
+	// replica dies, another one takes over its data sources after this time.
+	LeaseTTL time.Duration `mapstructure:"leaseTTL"`
+}
+
+// HarvestConfig holds the settings of the job that proposes completion terms mined from reports.
+type HarvestConfig struct {
+	// Interval is how often terms are harvested. Defaults to once a day.
+	Interval time.Duration `mapstructure:"interval"`
+	// MaxDocuments is the number of most recent reports sampled per harvest.
+	MaxDocuments int `mapstructure:"maxDocuments"`
+	// MaxWords is the length of the longest phrase proposed, in words.
+	MaxWords int `mapstructure:"maxWords"`
+	// MinDocCount is the fewest sampled reports a phrase must appear in to be proposed.
+	MinDocCount int `mapstructure:"minDocCount"`
+	// MaxDocRatio is the largest share of sampled reports a phrase may appear in; more frequent
+	// phrases are considered boilerplate.
+	MaxDocRatio float64 `mapstructure:"maxDocRatio"`
+	// MaxCandidates is the number of most significant phrases proposed per harvest.
+	MaxCandidates int `mapstructure:"maxCandidates"`
+	// AutoAddMinDocs adds phrases appearing in at least this many sampled reports as terms without
+	// review. Other phrases wait for a curator's approval. 0 disables adding terms automatically.
+	AutoAddMinDocs int `mapstructure:"autoAddMinDocs"`
+}
+
+// ThumbnailConfig holds the settings of the thumbnails rendered by DICOMweb data sources.
+type ThumbnailConfig struct {
+	// CacheDir is the directory rendered thumbnails are cached in.
+	CacheDir string `mapstructure:"cacheDir"`
+	// MaxCacheSize is the total size of the cached thumbnails in bytes. The least recently used
+	// thumbnails are removed when it is exceeded.
+	MaxCacheSize int64 `mapstructure:"maxCacheSize"`
+}
+
+// WebhookConfig holds the settings of the push notification receiver.
+type WebhookConfig struct {
+	// Secret is the bearer token that notifications must send in the Authorization header.
+	// Webhooks are disabled if it is empty, since notifications can add and delete documents.
+	Secret string `mapstructure:"secret"`
+}
+
 // DataSourceConfig represents a single external data source (e.g., DICOMweb or FHIR server).
 type DataSourceConfig struct {
 	Name string `mapstructure:"name"`
-	Type string `mapstructure:"type"` // e.g., "dicomweb", "fhir"
-	URL  string `mapstructure:"url"`
+	Type string `mapstructure:"type"` // e.g., "dicomweb", "fhir", "dicomdir", "hl7", "file"
+	URL  string `mapstructure:"url"`  // Server base URL, directory path for "dicomdir" and "file", mllp:// or file:// URL for "hl7"
+
+	// Options holds type-specific settings, such as "fetchMode" for "dicomweb" or a field
+	// "mapping" for "hl7" and "file". Each datasource type validates its own options.
+	Options map[string]interface{} `mapstructure:"options"`
+
+	// Viewer is an optional deep-link template into an image viewer, such as OHIF, for the documents
+	// of this datasource (e.g., "http://ohif/viewer?StudyInstanceUIDs={studyInstanceUid}"). It may
+	// use the {studyInstanceUid}, {accessionNumber}, {patientId} and {resourceId} placeholders.
+	Viewer string `mapstructure:"viewer"`
 }
 
 // Load loads configuration from a YAML file.
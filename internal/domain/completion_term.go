@@ -1,11 +1,80 @@
 package domain
 
+import (
+	"strings"
+	"time"
+)
+
 // CompletionTerm represents a term for autocomplete suggestions.
 type CompletionTerm struct {
-	Term string `json:"term"` // The display text shown in suggestions
+	ID       string   `json:"id"`                 // Unique ID; defaults to the term itself
+	Term     string   `json:"term"`               // The text inserted into the search box when the suggestion is selected
+	Label    string   `json:"label,omitempty"`    // The display text shown in suggestions; defaults to Term
+	Category string   `json:"category,omitempty"` // Grouping of the term, e.g., anatomy or finding
+	Source   string   `json:"source,omitempty"`   // Vocabulary the term comes from, e.g., RadLex or a local list
+	Weight   int      `json:"weight,omitempty"`   // Ranking weight; higher weights are suggested first
+	Synonyms []string `json:"synonyms,omitempty"` // Alternative spellings that also suggest the term
+	System   string   `json:"system,omitempty"`   // Code system of the concept the term names, e.g., http://snomed.info/sct
+	Code     string   `json:"code,omitempty"`     // Code of the concept in System
+	Parents  []string `json:"parents,omitempty"`  // Codes of the broader concepts in System
+
+	Stats *TermStats `json:"stats,omitempty"` // Popularity of the term, computed from indexed reports and searches
+}
+
+// TermStats describes how often a completion term is used. It is maintained by the service and
+// cannot be edited.
+type TermStats struct {
+	DocCount   int64     `json:"docCount"`             // Number of indexed documents mentioning the term
+	QueryCount int64     `json:"queryCount"`           // Number of searches for the term
+	Modalities []string  `json:"modalities,omitempty"` // Modalities of the studies whose documents mention the term
+	UpdatedAt  time.Time `json:"updatedAt,omitzero"`   // When DocCount and Modalities were last computed
 }
 
 // NewCompletionTerm creates a new CompletionTerm instance.
 func NewCompletionTerm(term string) CompletionTerm {
-	return CompletionTerm{Term: term}
+	return CompletionTerm{ID: term, Term: term}
+}
+
+// DisplayLabel returns the label of the term, or the term itself if it has no label.
+func (t CompletionTerm) DisplayLabel() string {
+	if t.Label != "" {
+		return t.Label
+	}
+	return t.Term
+}
+
+// Coding returns the "system|code" token of the concept the term names, or an empty string if the
+// term is not coded.
+func (t CompletionTerm) Coding() string {
+	if t.Code == "" {
+		return ""
+	}
+	return t.System + "|" + t.Code
+}
+
+// Normalize trims the fields of the term, drops empty and duplicate synonyms, and defaults the ID
+// to the term.
+func (t *CompletionTerm) Normalize() {
+	t.Term = strings.TrimSpace(t.Term)
+	t.Label = strings.TrimSpace(t.Label)
+	t.Category = strings.TrimSpace(t.Category)
+	t.Source = strings.TrimSpace(t.Source)
+	t.System = strings.TrimSpace(t.System)
+	t.Code = strings.TrimSpace(t.Code)
+	t.ID = strings.TrimSpace(t.ID)
+	if t.ID == "" {
+		t.ID = t.Term
+	}
+
+	seen := map[string]bool{strings.ToLower(t.Term): true}
+	synonyms := t.Synonyms[:0]
+	for _, s := range t.Synonyms {
+		s = strings.TrimSpace(s)
+		if s == "" || seen[strings.ToLower(s)] {
+			continue
+		}
+		seen[strings.ToLower(s)] = true
+		synonyms = append(synonyms, s)
+	}
+	t.Synonyms = synonyms
 }
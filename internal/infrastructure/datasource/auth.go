@@ -0,0 +1,54 @@
+package datasource
+
+import "net/http"
+
+// Auth holds the credentials sent with every request to a data source.
+type Auth struct {
+	Username string // HTTP Basic username
+	Password string // HTTP Basic password
+	Token    string // Bearer token; takes precedence over the Basic credentials
+}
+
+// authOptions are the option keys that parseAuth reads.
+var authOptions = []string{"username", "password", "token"}
+
+// parseAuth reads the credentials from the "username", "password" and "token" options.
+func parseAuth(opts Options) (Auth, error) {
+	var auth Auth
+	var err error
+	if auth.Username, err = opts.String("username"); err != nil {
+		return Auth{}, err
+	}
+	if auth.Password, err = opts.String("password"); err != nil {
+		return Auth{}, err
+	}
+	if auth.Token, err = opts.String("token"); err != nil {
+		return Auth{}, err
+	}
+	return auth, nil
+}
+
+// transport returns a RoundTripper that adds the credentials to each request, or nil to use the
+// default transport if no credentials are configured.
+func (a Auth) transport() http.RoundTripper {
+	if a.Token == "" && a.Username == "" {
+		return nil
+	}
+	return &authTransport{auth: a, next: http.DefaultTransport}
+}
+
+// authTransport adds the Authorization header to the requests sent through it.
+type authTransport struct {
+	auth Auth
+	next http.RoundTripper
+}
+
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	if t.auth.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+t.auth.Token)
+	} else {
+		req.SetBasicAuth(t.auth.Username, t.auth.Password)
+	}
+	return t.next.RoundTrip(req)
+}
@@ -2,17 +2,36 @@ package datasource
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"sort"
+	"strings"
+	"sync"
 
+	"github.com/yangszwei/koala/config"
 	"github.com/yangszwei/koala/internal/usecase/search"
 )
 
 // DataSummary represents a summary returned by a data source.
 type DataSummary struct {
-	ID     string // Data ID from the data source
-	Source string // Data source name (e.g., "dicomweb", "fhir")
-	Type   string // study/report/etc.
-	Raw    any    // Backend-specific context for Fetch()
+	ID      string // Data ID from the data source
+	Source  string // Data source name (e.g., "dicomweb", "fhir")
+	Type    string // study/report/etc.
+	Raw     any    // Backend-specific context for Fetch()
+	Cursor  string // Position to resume a ResumableStreamer from, so that this item is emitted again
+	Deleted bool   // Whether the item was removed from the data source, so its document is removed
+
+	// Done, if set, is called once with nil when the document of the item was indexed or deleted,
+	// or with the error that kept it from being processed. Data sources that acknowledge items to
+	// their sender use it to acknowledge them only once they are indexed.
+	Done func(error)
+}
+
+// Finish reports the outcome of processing the item to its data source, if it asked for it.
+func (d *DataSummary) Finish(err error) {
+	if d.Done != nil {
+		d.Done(err)
+	}
 }
 
 // DocID builds the document ID of the data summary, used in Elasticsearch.
@@ -20,15 +39,42 @@ func (d *DataSummary) DocID() string {
 	return fmt.Sprintf("%s:%s:%s", d.Type, d.Source, d.ID)
 }
 
+// ParseDocID splits a document ID built by DocID into the type, source and ID of its summary.
+// Colons in the source name are escaped as a double backslash followed by the colon, as done by
+// elasticutil.EscapeQueryString, while the data ID may contain colons itself.
+func ParseDocID(id string) (DataSummary, bool) {
+	typ, rest, ok := strings.Cut(id, ":")
+	if !ok || typ == "" {
+		return DataSummary{}, false
+	}
+	for i := 0; i < len(rest); i++ {
+		switch rest[i] {
+		case '\\':
+			i += 2 // The second backslash and the escaped character
+		case ':':
+			if i == 0 || i == len(rest)-1 {
+				return DataSummary{}, false
+			}
+			return DataSummary{ID: rest[i+1:], Source: rest[:i], Type: typ}, true
+		}
+	}
+	return DataSummary{}, false
+}
+
 // Client defines the interface for external data sources (e.g., DICOMweb, FHIR).
-// It provides methods for fetching and counting documents.
+// It provides methods for fetching documents; listing them is provided by Pager or Streamer.
 type Client interface {
 	// Name returns a sanitized name of the data source client (e.g., "dicomweb", "fhir").
 	Name() string
 	// Fetch retrieves a full document from a given summary.
 	Fetch(ctx context.Context, summary DataSummary) (*search.Document, error)
+}
+
+// Counter represents clients that can report the number of documents available.
+type Counter interface {
+	Client
+
 	// Count returns the total number of documents available.
-	// Returns -1 if the operation is not supported.
 	Count(ctx context.Context) (int, error)
 }
 
@@ -50,15 +96,117 @@ type Streamer interface {
 	Stream(ctx context.Context, pageSize int) (<-chan DataSummary, error)
 }
 
-// New creates a Client implementation based on the provided type string.
-// Supported types include "dicomweb" and "fhir".
-func New(typ, name, url string) (Client, error) {
-	switch typ {
-	case "dicomweb":
-		return NewDICOMwebClient(name, url), nil
-	case "fhir":
-		return NewFHIRClient(name, url), nil
-	default:
-		return nil, fmt.Errorf("unsupported datasource type: %s", typ)
+// ResumableStreamer represents streamers that can continue an interrupted stream.
+type ResumableStreamer interface {
+	Streamer
+
+	// StreamFrom is like Stream, but starts at the Cursor of a previously emitted summary.
+	// Items from the cursor onward are emitted again; an empty cursor starts from the beginning.
+	StreamFrom(ctx context.Context, pageSize int, cursor string) (<-chan DataSummary, error)
+}
+
+// Stateful is implemented by incremental clients whose position between scans must survive a
+// restart, or move to another replica, e.g., the time of the last export.
+type Stateful interface {
+	// State returns the position after the last completed scan, or "" before the first one.
+	State() string
+	// Restore sets the position saved after the last completed scan. An empty state makes the
+	// next scan start from the beginning.
+	Restore(state string)
+}
+
+// RawCodec is implemented by clients that can only fetch an item from the Raw context of its
+// summary, e.g., because the item was pushed to Koala or read from a file. Failed items of these
+// clients are stored with their serialized context so they can be fetched again on retry. Other
+// clients fetch items by ID, so retries read the current version of the item.
+type RawCodec interface {
+	// EncodeRaw serializes the Raw context of a summary as a JSON object.
+	EncodeRaw(raw any) (json.RawMessage, error)
+	// DecodeRaw restores a Raw context serialized by EncodeRaw.
+	DecodeRaw(data json.RawMessage) (any, error)
+}
+
+// Capabilities describes what a client supports, so the indexer can choose how to scan it.
+type Capabilities struct {
+	Pager     bool `json:"pager"`     // Implements Pager
+	Streamer  bool `json:"streamer"`  // Implements Streamer
+	Resumable bool `json:"resumable"` // Implements ResumableStreamer
+	Counter   bool `json:"counter"`   // Implements Counter
+
+	// Incremental clients only emit items that are new or changed since the previous scan, so
+	// every emitted item should be (re)indexed even if a document already exists.
+	Incremental bool `json:"incremental"`
+	// DeleteDetection clients can report items that were removed from the source.
+	DeleteDetection bool `json:"deleteDetection"`
+}
+
+// CapabilityReporter is implemented by clients that support more than their interfaces reveal.
+type CapabilityReporter interface {
+	// Capabilities returns the incremental and delete detection support of the client.
+	Capabilities() Capabilities
+}
+
+// CapabilitiesOf returns the capabilities of a client. Interface support is detected from the
+// client itself; other capabilities are taken from CapabilityReporter if implemented.
+func CapabilitiesOf(client Client) Capabilities {
+	var caps Capabilities
+	if reporter, ok := client.(CapabilityReporter); ok {
+		caps = reporter.Capabilities()
+	}
+	_, caps.Pager = client.(Pager)
+	_, caps.Streamer = client.(Streamer)
+	_, caps.Resumable = client.(ResumableStreamer)
+	_, caps.Counter = client.(Counter)
+	return caps
+}
+
+// Factory creates a client from its name, URL and type-specific options. It validates the
+// options and returns an error if they are invalid.
+type Factory func(name, url string, opts Options) (Client, error)
+
+var (
+	factories   = make(map[string]Factory)
+	factoriesMu sync.RWMutex
+)
+
+// Register makes a datasource type available to New. It panics if the type is already registered
+// or the factory is nil, since both indicate a programming error.
+func Register(typ string, factory Factory) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+	if factory == nil {
+		panic("datasource: Register factory is nil")
+	}
+	if _, dup := factories[typ]; dup {
+		panic("datasource: Register called twice for type " + typ)
+	}
+	factories[typ] = factory
+}
+
+// Types returns the sorted list of registered datasource types.
+func Types() []string {
+	factoriesMu.RLock()
+	defer factoriesMu.RUnlock()
+	types := make([]string, 0, len(factories))
+	for typ := range factories {
+		types = append(types, typ)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// New creates a Client using the factory registered for the configured type.
+func New(cfg config.DataSourceConfig) (Client, error) {
+	factoriesMu.RLock()
+	factory, ok := factories[cfg.Type]
+	factoriesMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unsupported datasource type: %s", cfg.Type)
+	}
+
+	client, err := factory(cfg.Name, cfg.URL, Options(cfg.Options))
+	if err != nil {
+		return nil, fmt.Errorf("%s datasource %s: %w", cfg.Type, cfg.Name, err)
 	}
+	return client, nil
 }
@@ -0,0 +1,107 @@
+package datasource
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/yangszwei/koala/internal/usecase/search"
+	"github.com/yangszwei/koala/pkg/hl7"
+)
+
+func TestRawCodec(t *testing.T) {
+	dir := t.TempDir()
+	hl7Client, err := NewHL7Client("hl7", "file://"+dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dicomdir, err := NewDICOMDirClient("dicomdir", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := NewFileClient("file", dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := hl7.Parse([]byte("MSH|^~\\&|RIS|HOSP|KOALA|HOSP|20240101120000||ORU^R01|MSG1|P|2.5\r" +
+		"PID|1||P1^^^HOSP||DOE^JANE||19800101|F\r" +
+		"OBR|1||ACC1|CTCHEST^CT Chest\r" +
+		"OBX|1|TX|IMP||No acute findings \\T\\ stable nodule||||||F\r"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		client Client
+		raw    any
+	}{
+		{name: "hl7 message", client: hl7Client, raw: msg},
+		{
+			name:   "dicomdir headers",
+			client: dicomdir,
+			raw: []map[string]interface{}{{
+				"0020000D": map[string]interface{}{"vr": "UI", "Value": []interface{}{"1.2.3"}},
+				"00080060": map[string]interface{}{"vr": "CS", "Value": []interface{}{"CT"}},
+				"00100010": map[string]interface{}{"vr": "PN", "Value": []interface{}{map[string]interface{}{"Alphabetic": "DOE^JANE"}}},
+				"00201208": map[string]interface{}{"vr": "IS", "Value": []interface{}{float64(12)}},
+			}},
+		},
+		{
+			name:   "fhir bulk report",
+			client: NewFHIRBulkClient("bulk", "http://fhir.invalid"),
+			raw: &bulkReport{
+				resource: map[string]interface{}{
+					"resourceType": "DiagnosticReport",
+					"id":           "r1",
+					"status":       "final",
+					"conclusion":   "No acute findings",
+					"subject":      map[string]interface{}{"reference": "Patient/p1"},
+				},
+				patient: map[string]interface{}{"resourceType": "Patient", "id": "p1", "gender": "female"},
+				studies: []map[string]interface{}{{"resourceType": "ImagingStudy", "id": "s1"}},
+			},
+		},
+		{
+			name:   "file row",
+			client: file,
+			raw:    search.Document{Type: "report", PatientID: "P1", ReportText: "No acute findings", Categories: []string{"CT"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			codec, ok := tt.client.(RawCodec)
+			if !ok {
+				t.Fatalf("%T does not implement RawCodec", tt.client)
+			}
+
+			summary := DataSummary{ID: "1", Source: tt.client.Name(), Type: "report", Raw: tt.raw}
+			want, err := tt.client.Fetch(ctx, summary)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			data, err := codec.EncodeRaw(tt.raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(data) == 0 || data[0] != '{' {
+				t.Fatalf("encoded raw %s is not a JSON object", data)
+			}
+			if summary.Raw, err = codec.DecodeRaw(data); err != nil {
+				t.Fatal(err)
+			}
+			got, err := tt.client.Fetch(ctx, summary)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("document after round trip = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
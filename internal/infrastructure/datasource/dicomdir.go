@@ -0,0 +1,429 @@
+package datasource
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/suyashkumar/dicom"
+	"github.com/yangszwei/koala/internal/usecase/search"
+	"github.com/yangszwei/koala/pkg/elasticutil"
+)
+
+// dicomFileTags lists the header attributes kept for each DICOM file. Parsing stops once the
+// last of these groups has been read, so pixel data is never loaded.
+var dicomFileTags = map[string]bool{
+	"00080020": true, // StudyDate
+	"00080050": true, // AccessionNumber
+	"00080060": true, // Modality
+	"00080080": true, // InstitutionName
+	"00080090": true, // ReferringPhysicianName
+	"00081030": true, // StudyDescription
+	"0008103E": true, // SeriesDescription
+	"00100010": true, // PatientName
+	"00100020": true, // PatientID
+	"00100040": true, // PatientSex
+	"00180015": true, // BodyPartExamined
+	"0020000D": true, // StudyInstanceUID
+	"0020000E": true, // SeriesInstanceUID
+}
+
+// dicomFile caches the parsed header of a single file together with the file state it was read from.
+type dicomFile struct {
+	modTime time.Time
+	size    int64
+	study   string                 // StudyInstanceUID, empty if the file is not a DICOM file
+	header  map[string]interface{} // DICOM JSON model, nil if the file is not a DICOM file or was restored
+}
+
+// dicomdirClient implements the Streamer interface for DICOM Part 10 files stored in a local directory tree.
+type dicomdirClient struct {
+	name  string
+	root  string
+	files map[string]*dicomFile
+	state string // State last returned or restored, so that restoring it again is a no-op
+	mu    sync.Mutex
+}
+
+func init() {
+	factory := func(name, url string, opts Options) (Client, error) {
+		if err := opts.Validate(); err != nil {
+			return nil, err
+		}
+		return NewDICOMDirClient(name, url)
+	}
+	Register("dicomdir", factory)
+	Register("filesystem", factory)
+}
+
+// NewDICOMDirClient creates a new client that crawls DICOM files below the given root directory.
+// The root may be given as a plain path or a file:// URL.
+func NewDICOMDirClient(name, root string) (Client, error) {
+	root = strings.TrimPrefix(root, "file://")
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, fmt.Errorf("dicom directory: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("dicom directory: %s is not a directory", root)
+	}
+
+	return &dicomdirClient{
+		name:  name,
+		root:  root,
+		files: make(map[string]*dicomFile),
+	}, nil
+}
+
+func (d *dicomdirClient) Name() string {
+	return elasticutil.EscapeQueryString(d.name)
+}
+
+// Capabilities reports that only studies with new, modified or removed files are emitted.
+func (d *dicomdirClient) Capabilities() Capabilities {
+	return Capabilities{Incremental: true}
+}
+
+// storedDICOMFile is the state of a scanned file saved by State. Headers are not saved, since they
+// are only needed again when another file of the study changes, and can then be read again.
+type storedDICOMFile struct {
+	ModTime time.Time `json:"m"`
+	Size    int64     `json:"s"`
+	Study   string    `json:"u,omitempty"`
+}
+
+// State returns the files seen by the last walk, so that files unchanged since are not read
+// again after a restart, and files removed since are noticed. It is gzipped JSON in base64, since
+// it lists every file below the root.
+func (d *dicomdirClient) State() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if len(d.files) == 0 {
+		return ""
+	}
+	stored := make(map[string]storedDICOMFile, len(d.files))
+	for path, file := range d.files {
+		stored[path] = storedDICOMFile{ModTime: file.modTime, Size: file.size, Study: file.study}
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(zw).Encode(stored); err != nil {
+		log.Printf("[%s] Failed to encode scan state: %v", d.name, err)
+		return d.state
+	}
+	if err := zw.Close(); err != nil {
+		log.Printf("[%s] Failed to encode scan state: %v", d.name, err)
+		return d.state
+	}
+	d.state = base64.StdEncoding.EncodeToString(buf.Bytes())
+	return d.state
+}
+
+// Restore replaces the seen files with the ones saved by State. A state that cannot be decoded is
+// treated as empty, so the next walk reads every file again.
+func (d *dicomdirClient) Restore(state string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if state == d.state {
+		return
+	}
+	d.state = state
+	d.files = make(map[string]*dicomFile)
+	if state == "" {
+		return
+	}
+
+	stored, err := decodeDICOMDirState(state)
+	if err != nil {
+		log.Printf("[%s] Failed to decode scan state, rescanning all files: %v", d.name, err)
+		return
+	}
+	for path, file := range stored {
+		d.files[path] = &dicomFile{modTime: file.ModTime, size: file.Size, study: file.Study}
+	}
+}
+
+// decodeDICOMDirState decodes a state returned by State.
+func decodeDICOMDirState(state string) (map[string]storedDICOMFile, error) {
+	data, err := base64.StdEncoding.DecodeString(state)
+	if err != nil {
+		return nil, err
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	var stored map[string]storedDICOMFile
+	if err := json.NewDecoder(zr).Decode(&stored); err != nil {
+		return nil, err
+	}
+	return stored, nil
+}
+
+// Stream walks the directory tree, reads the headers of new or modified files, and emits one
+// summary for each study that has new, modified or removed files since the previous walk. Studies
+// whose files were all removed are emitted as deleted. Files whose modification time and size are
+// unchanged are not read again. The walk is only remembered once every summary has been received,
+// so the studies of an interrupted scan are emitted again by the next one.
+func (d *dicomdirClient) Stream(ctx context.Context, _ int) (<-chan DataSummary, error) {
+	out := make(chan DataSummary)
+
+	go func() {
+		defer close(out)
+
+		files, studies, err := d.scan(ctx)
+		if err != nil {
+			log.Printf("[%s] Directory scan failed: %v", d.name, err)
+			return
+		}
+
+		for uid, instances := range studies {
+			summary := DataSummary{ID: uid, Source: d.Name(), Type: "study", Raw: instances}
+			if len(instances) == 0 {
+				summary.Raw, summary.Deleted = nil, true
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case out <- summary:
+			}
+		}
+		if ctx.Err() != nil {
+			return
+		}
+
+		d.mu.Lock()
+		d.files = files
+		d.mu.Unlock()
+	}()
+
+	return out, nil
+}
+
+func (d *dicomdirClient) Fetch(_ context.Context, summary DataSummary) (*search.Document, error) {
+	instances, ok := summary.Raw.([]map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("study %s has not been scanned", summary.ID)
+	}
+	return mapMetadataToDocument(summary.DocID(), instances), nil
+}
+
+// EncodeRaw serializes the scanned file headers of a study. Headers are kept in the DICOM JSON
+// model, so they are restored unchanged.
+func (d *dicomdirClient) EncodeRaw(raw any) (json.RawMessage, error) {
+	instances, ok := raw.([]map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unexpected raw type %T", raw)
+	}
+	return json.Marshal(map[string]interface{}{"instances": instances})
+}
+
+// DecodeRaw restores file headers serialized by EncodeRaw.
+func (d *dicomdirClient) DecodeRaw(data json.RawMessage) (any, error) {
+	var stored struct {
+		Instances []map[string]interface{} `json:"instances"`
+	}
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, err
+	}
+	return stored.Instances, nil
+}
+
+// scan walks the root directory and groups the headers of all DICOM files by StudyInstanceUID.
+// It returns the files seen by the walk, which replace the seen files once the studies are
+// emitted. Only studies with files that changed since the previous walk are returned; studies
+// whose files were all removed are returned without headers.
+func (d *dicomdirClient) scan(ctx context.Context) (map[string]*dicomFile, map[string][]map[string]interface{}, error) {
+	// Restore replaces the map rather than changing it, and cached files are never changed, so the
+	// previous walk can be read without holding the lock
+	d.mu.Lock()
+	previous := d.files
+	d.mu.Unlock()
+
+	files := make(map[string]*dicomFile, len(previous))
+	changed := make(map[string]struct{})
+
+	err := filepath.WalkDir(d.root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			log.Printf("[%s] Skipping %s: %v", d.name, path, err)
+			if entry != nil && entry.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if entry.IsDir() || !entry.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return nil
+		}
+
+		cached, ok := previous[path]
+		if ok && cached.modTime.Equal(info.ModTime()) && cached.size == info.Size() {
+			files[path] = cached
+			return nil
+		}
+
+		header, err := readDICOMHeader(path, info.Size())
+		if err != nil {
+			log.Printf("[%s] Failed to read %s: %v", d.name, path, err)
+		}
+
+		file := &dicomFile{modTime: info.ModTime(), size: info.Size(), header: header}
+		if header != nil {
+			file.study = dicomString(header, "0020000D")
+			changed[file.study] = struct{}{}
+		}
+		if ok && cached.study != "" {
+			changed[cached.study] = struct{}{}
+		}
+		files[path] = file
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Studies of files removed since the previous walk changed too
+	for path, file := range previous {
+		if _, ok := files[path]; !ok && file.study != "" {
+			changed[file.study] = struct{}{}
+		}
+	}
+
+	studies := make(map[string][]map[string]interface{}, len(changed))
+	for uid := range changed {
+		if uid != "" {
+			studies[uid] = nil
+		}
+	}
+
+	for path, file := range files {
+		if _, ok := changed[file.study]; !ok || file.study == "" {
+			continue
+		}
+
+		// Read the headers of files whose headers were not read since the state was restored
+		if file.header == nil {
+			if ctx.Err() != nil {
+				return nil, nil, ctx.Err()
+			}
+			header, err := readDICOMHeader(path, file.size)
+			if err != nil {
+				log.Printf("[%s] Failed to read %s: %v", d.name, path, err)
+			}
+			if header == nil || dicomString(header, "0020000D") != file.study {
+				// Changed without a new modification time or size; read it again on the next walk
+				files[path] = &dicomFile{size: file.size, study: file.study}
+				continue
+			}
+			file = &dicomFile{modTime: file.modTime, size: file.size, study: file.study, header: header}
+			files[path] = file
+		}
+
+		studies[file.study] = append(studies[file.study], file.header)
+	}
+
+	return files, studies, nil
+}
+
+// readDICOMHeader parses the header of a DICOM Part 10 file into the DICOM JSON model, keeping only
+// dicomFileTags. It returns a nil header without error for files that are not DICOM files.
+func readDICOMHeader(path string, size int64) (map[string]interface{}, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	// Part 10 files start with a 128 byte preamble followed by the "DICM" prefix
+	preamble := make([]byte, 132)
+	if _, err := io.ReadFull(f, preamble); err != nil || !bytes.Equal(preamble[128:], []byte("DICM")) {
+		return nil, nil
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	parser, err := dicom.NewParser(f, size, nil, dicom.SkipPixelData(), dicom.AllowUnknownSpecificCharacterSet())
+	if err != nil {
+		return nil, err
+	}
+
+	header := make(map[string]interface{})
+	for {
+		elem, err := parser.Next()
+		if errors.Is(err, dicom.ErrorEndOfDICOM) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if elem.Tag.Group > 0x0020 {
+			break
+		}
+
+		key := fmt.Sprintf("%04X%04X", elem.Tag.Group, elem.Tag.Element)
+		if !dicomFileTags[key] {
+			continue
+		}
+		if values := dicomElementValues(elem); len(values) > 0 {
+			header[key] = map[string]interface{}{"vr": elem.RawValueRepresentation, "Value": values}
+		}
+	}
+
+	if _, ok := header["0020000D"]; !ok {
+		return nil, nil
+	}
+
+	return header, nil
+}
+
+// dicomElementValues converts the value of a parsed element to DICOM JSON model values.
+func dicomElementValues(elem *dicom.Element) []interface{} {
+	var values []interface{}
+
+	switch elem.Value.ValueType() {
+	case dicom.Strings:
+		for _, s := range dicom.MustGetStrings(elem.Value) {
+			s = strings.TrimRight(s, " \x00")
+			if elem.RawValueRepresentation == "PN" {
+				values = append(values, map[string]interface{}{"Alphabetic": s})
+			} else {
+				values = append(values, s)
+			}
+		}
+	case dicom.Ints:
+		for _, n := range dicom.MustGetInts(elem.Value) {
+			values = append(values, float64(n))
+		}
+	case dicom.Floats:
+		for _, n := range dicom.MustGetFloats(elem.Value) {
+			values = append(values, n)
+		}
+	}
+
+	return values
+}
@@ -0,0 +1,175 @@
+package datasource
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/suyashkumar/dicom"
+	"github.com/suyashkumar/dicom/pkg/tag"
+)
+
+// writeDICOM writes a DICOM file of an instance of a study.
+func writeDICOM(t *testing.T, path, studyUID, instanceUID string) {
+	t.Helper()
+	var elems []*dicom.Element
+	for _, e := range []struct {
+		tag   tag.Tag
+		value []string
+	}{
+		{tag.MediaStorageSOPClassUID, []string{"1.2.840.10008.5.1.4.1.1.2"}},
+		{tag.MediaStorageSOPInstanceUID, []string{instanceUID}},
+		{tag.TransferSyntaxUID, []string{"1.2.840.10008.1.2.1"}},
+		{tag.Modality, []string{"CT"}},
+		{tag.StudyInstanceUID, []string{studyUID}},
+	} {
+		elem, err := dicom.NewElement(e.tag, e.value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		elems = append(elems, elem)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := dicom.Write(f, dicom.Dataset{Elements: elems}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// streamStudies runs a scan and returns the number of instances of each emitted study, or -1 for
+// deleted studies.
+func streamStudies(t *testing.T, client Client) map[string]int {
+	t.Helper()
+	out, err := client.(Streamer).Stream(context.Background(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	studies := make(map[string]int)
+	for summary := range out {
+		if summary.Deleted {
+			studies[summary.ID] = -1
+			continue
+		}
+		studies[summary.ID] = len(summary.Raw.([]map[string]interface{}))
+	}
+	return studies
+}
+
+func TestDICOMDirScan(t *testing.T) {
+	dir := t.TempDir()
+	client, err := NewDICOMDirClient("dicomdir", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		name    string
+		write   map[string]string // Files written before the scan, by name, with their study
+		remove  []string          // Files removed before the scan
+		restart bool              // Whether the scan runs on a new client restored from the state
+		want    map[string]int
+	}{
+		{
+			name:  "new files",
+			write: map[string]string{"a.dcm": "1.1", "b.dcm": "1.1", "c.dcm": "1.2", "notes.txt": ""},
+			want:  map[string]int{"1.1": 2, "1.2": 1},
+		},
+		{
+			name: "unchanged files",
+			want: map[string]int{},
+		},
+		{
+			name:   "all files of a study removed",
+			remove: []string{"c.dcm"},
+			want:   map[string]int{"1.2": -1},
+		},
+		{
+			name:   "some files of a study removed",
+			remove: []string{"a.dcm"},
+			want:   map[string]int{"1.1": 1},
+		},
+		{
+			name:    "unchanged files after restart",
+			restart: true,
+			want:    map[string]int{},
+		},
+		{
+			name:    "files added after restart",
+			write:   map[string]string{"d.dcm": "1.1"},
+			restart: true,
+			want:    map[string]int{"1.1": 2},
+		},
+		{
+			name:    "files removed after restart",
+			remove:  []string{"b.dcm", "d.dcm"},
+			restart: true,
+			want:    map[string]int{"1.1": -1},
+		},
+	}
+
+	for i, step := range steps {
+		names := make([]string, 0, len(step.write))
+		for name := range step.write {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			path := filepath.Join(dir, name)
+			if study := step.write[name]; study != "" {
+				writeDICOM(t, path, study, study+"."+name)
+			} else if err := os.WriteFile(path, []byte("not a DICOM file"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for _, name := range step.remove {
+			if err := os.Remove(filepath.Join(dir, name)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if step.restart {
+			state := client.(Stateful).State()
+			if client, err = NewDICOMDirClient("dicomdir", dir); err != nil {
+				t.Fatal(err)
+			}
+			client.(Stateful).Restore(state)
+		}
+
+		if got := streamStudies(t, client); !reflect.DeepEqual(got, step.want) {
+			t.Fatalf("step %d (%s): studies = %v, want %v", i, step.name, got, step.want)
+		}
+	}
+}
+
+func TestDICOMDirInterruptedScan(t *testing.T) {
+	dir := t.TempDir()
+	writeDICOM(t, filepath.Join(dir, "a.dcm"), "1.1", "1.1.1")
+	writeDICOM(t, filepath.Join(dir, "b.dcm"), "1.2", "1.2.1")
+
+	client, err := NewDICOMDirClient("dicomdir", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Stop the scan after the first study, as when the lease is lost
+	ctx, cancel := context.WithCancel(context.Background())
+	out, err := client.(Streamer).Stream(ctx, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-out
+	cancel()
+	for range out {
+	}
+
+	want := map[string]int{"1.1": 1, "1.2": 1}
+	if got := streamStudies(t, client); !reflect.DeepEqual(got, want) {
+		t.Errorf("studies after an interrupted scan = %v, want %v", got, want)
+	}
+}
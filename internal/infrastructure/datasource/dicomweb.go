@@ -2,9 +2,10 @@ package datasource
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"net/http"
+	neturl "net/url"
+	"strconv"
 	"strings"
 	"time"
 
@@ -12,55 +13,106 @@ import (
 	"github.com/yangszwei/koala/pkg/elasticutil"
 )
 
+// FetchMode selects how a DICOMweb client builds study documents.
+type FetchMode string
+
+const (
+	// FetchModeStudy builds documents from the QIDO-RS study response only. Series-level QIDO-RS
+	// is queried only when the archive omits the modalities or series/instance counts.
+	FetchModeStudy FetchMode = "study"
+	// FetchModeSeries builds documents from the QIDO-RS study response and its series, which adds
+	// body parts and series descriptions. This is the default.
+	FetchModeSeries FetchMode = "series"
+	// FetchModeMetadata downloads the full WADO-RS metadata of every instance in the study.
+	FetchModeMetadata FetchMode = "metadata"
+)
+
+// studyIncludeFields lists the optional study-level attributes requested from QIDO-RS.
+var studyIncludeFields = []string{
+	"00081030", // StudyDescription
+	"00080050", // AccessionNumber
+	"00080090", // ReferringPhysicianName
+	"00080080", // InstitutionName
+	"00080061", // ModalitiesInStudy
+	"00201206", // NumberOfStudyRelatedSeries
+	"00201208", // NumberOfStudyRelatedInstances
+}
+
+// seriesIncludeFields lists the optional series-level attributes requested from QIDO-RS.
+var seriesIncludeFields = []string{
+	"0008103E", // SeriesDescription
+	"00180015", // BodyPartExamined
+	"00201209", // NumberOfSeriesRelatedInstances
+}
+
 // dicomwebClient implements the Client interface for DICOMweb data sources.
 type dicomwebClient struct {
 	name   string
 	base   string
+	mode   FetchMode
 	client *http.Client
 }
 
-// NewDICOMwebClient creates a new DICOMweb client.
-func NewDICOMwebClient(name, base string) Client {
+func init() {
+	Register("dicomweb", func(name, url string, opts Options) (Client, error) {
+		if err := opts.Validate(append([]string{"fetchMode"}, authOptions...)...); err != nil {
+			return nil, err
+		}
+		mode, err := opts.String("fetchMode")
+		if err != nil {
+			return nil, err
+		}
+		auth, err := parseAuth(opts)
+		if err != nil {
+			return nil, err
+		}
+		return NewDICOMwebClient(name, url, FetchMode(mode), auth)
+	})
+}
+
+// NewDICOMwebClient creates a new DICOMweb client that sends the credentials with every request.
+// An empty mode selects FetchModeSeries.
+func NewDICOMwebClient(name, base string, mode FetchMode, auth Auth) (Client, error) {
+	switch mode {
+	case "":
+		mode = FetchModeSeries
+	case FetchModeStudy, FetchModeSeries, FetchModeMetadata:
+	default:
+		return nil, fmt.Errorf("unsupported DICOMweb fetch mode: %s", mode)
+	}
+
 	return &dicomwebClient{
 		name:   name,
 		base:   strings.TrimRight(base, "/"),
-		client: &http.Client{Timeout: 10 * time.Second},
-	}
+		mode:   mode,
+		client: &http.Client{Timeout: 10 * time.Second, Transport: auth.transport()},
+	}, nil
 }
 
 func (d *dicomwebClient) Name() string {
 	return elasticutil.EscapeQueryString(d.name)
 }
 
+// Link adds the WADO-RS and QIDO-RS URLs of a study.
+func (d *dicomwebClient) Link(src *Source) {
+	uid := neturl.PathEscape(src.ResourceID)
+	src.WADOURL = fmt.Sprintf("%s/studies/%s", d.base, uid)
+	src.QIDOURL = fmt.Sprintf("%s/studies?StudyInstanceUID=%s", d.base, neturl.QueryEscape(src.ResourceID))
+}
+
 func (d *dicomwebClient) List(ctx context.Context, offset, limit int) ([]DataSummary, error) {
-	url := fmt.Sprintf("%s/studies?offset=%d&limit=%d", d.base, offset, limit)
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-	if err != nil {
-		return nil, err
-	}
+	url := fmt.Sprintf("%s/studies?offset=%d&limit=%d&includefield=%s", d.base, offset, limit, strings.Join(studyIncludeFields, ","))
 
-	resp, err := d.client.Do(req)
+	studies, err := d.getDatasets(ctx, url)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("bad status: %s", resp.Status)
-	}
-
-	var studies []map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&studies); err != nil {
-		return nil, err
-	}
 
 	summaries := make([]DataSummary, 0, len(studies))
 	for _, s := range studies {
-		id, _ := s["0020000D"].(map[string]interface{}) // StudyInstanceUID
-		uid, _ := id["Value"].([]interface{})
-		if len(uid) > 0 {
+		if uid := dicomString(s, "0020000D"); uid != "" { // StudyInstanceUID
 			summaries = append(summaries, DataSummary{
-				ID:     uid[0].(string),
+				ID:     uid,
 				Source: d.Name(),
 				Type:   "study",
 				Raw:    s,
@@ -74,12 +126,50 @@ func (d *dicomwebClient) List(ctx context.Context, offset, limit int) ([]DataSum
 func (d *dicomwebClient) Fetch(ctx context.Context, summary DataSummary) (*search.Document, error) {
 	studyUID := summary.ID
 
-	url := fmt.Sprintf("%s/studies/%s/metadata", d.base, studyUID)
+	if d.mode == FetchModeMetadata {
+		metadata, err := d.getDatasets(ctx, fmt.Sprintf("%s/studies/%s/metadata", d.base, studyUID))
+		if err != nil {
+			return nil, err
+		}
+		return mapMetadataToDocument(summary.DocID(), metadata), nil
+	}
+
+	// Summaries produced by List already hold the QIDO-RS study response
+	study, ok := summary.Raw.(map[string]interface{})
+	if !ok {
+		url := fmt.Sprintf("%s/studies?StudyInstanceUID=%s&includefield=%s", d.base, studyUID, strings.Join(studyIncludeFields, ","))
+		studies, err := d.getDatasets(ctx, url)
+		if err != nil {
+			return nil, err
+		}
+		if len(studies) == 0 {
+			return nil, fmt.Errorf("study %s not found", studyUID)
+		}
+		study = studies[0]
+	}
+
+	doc := mapStudyToDocument(summary.DocID(), study)
+
+	if d.mode == FetchModeSeries || doc.SeriesCount == 0 || doc.InstanceCount == 0 || len(doc.Modalities) == 0 {
+		url := fmt.Sprintf("%s/studies/%s/series?includefield=%s", d.base, studyUID, strings.Join(seriesIncludeFields, ","))
+		series, err := d.getDatasets(ctx, url)
+		if err != nil {
+			return nil, err
+		}
+		mapSeriesToDocument(doc, series)
+	}
+
+	return doc, nil
+}
 
+// getDatasets performs a GET request and decodes the response into DICOM JSON datasets.
+// A 204 No Content response is treated as an empty result.
+func (d *dicomwebClient) getDatasets(ctx context.Context, url string) ([]map[string]interface{}, error) {
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
+	req.Header.Set("Accept", dicomAccept)
 
 	resp, err := d.client.Do(req)
 	if err != nil {
@@ -87,23 +177,72 @@ func (d *dicomwebClient) Fetch(ctx context.Context, summary DataSummary) (*searc
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNoContent {
+		return nil, nil
+	}
 	if resp.StatusCode != 200 {
 		return nil, fmt.Errorf("bad status: %s", resp.Status)
 	}
 
-	var metadata []map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&metadata); err != nil {
-		return nil, err
+	return decodeDatasets(resp.Header.Get("Content-Type"), resp.Body)
+}
+
+// mapStudyToDocument converts a QIDO-RS study response to a search.Document.
+func mapStudyToDocument(id string, study map[string]interface{}) *search.Document {
+	doc := &search.Document{
+		ID:         id, // Elasticsearch document ID
+		Type:       "image",
+		Modality:   "UNKNOWN",
+		Categories: []string{"unsorted"},
+	}
+
+	mapStudyAttributes(doc, study)
+
+	doc.Modalities = dicomStrings(study, "00080061")
+	if len(doc.Modalities) > 0 {
+		doc.Modality = doc.Modalities[0]
 	}
+	doc.SeriesCount = dicomInt(study, "00201206")
+	doc.InstanceCount = dicomInt(study, "00201208")
 
-	return mapMetadataToDocument(summary.DocID(), metadata), nil
+	return doc
 }
 
-func (d *dicomwebClient) Count(_ context.Context) (int, error) {
-	return -1, nil // not supported
+// mapSeriesToDocument adds the attributes of a QIDO-RS series response to a study document.
+// Modalities and counts reported at study level are kept; otherwise they are derived from the series.
+func mapSeriesToDocument(doc *search.Document, series []map[string]interface{}) {
+	modalities := doc.Modalities
+	instances := 0
+
+	for _, elem := range series {
+		if modality := dicomString(elem, "00080060"); modality != "" {
+			modalities = appendUnique(modalities, modality)
+		}
+		if desc := dicomString(elem, "0008103E"); desc != "" {
+			doc.SeriesDescriptions = appendUnique(doc.SeriesDescriptions, desc)
+		}
+		if bodyPart := dicomString(elem, "00180015"); bodyPart != "" {
+			doc.BodyParts = appendUnique(doc.BodyParts, bodyPart)
+		}
+		instances += dicomInt(elem, "00201209")
+	}
+
+	doc.Modalities = modalities
+	if doc.Modality == "UNKNOWN" && len(modalities) > 0 {
+		doc.Modality = modalities[0]
+	}
+	if doc.SeriesCount == 0 {
+		doc.SeriesCount = len(series)
+	}
+	if doc.InstanceCount == 0 {
+		doc.InstanceCount = instances
+	}
 }
 
 // mapMetadataToDocument converts DICOM metadata to a search.Document.
+// The metadata contains one element per instance; study-level attributes are taken from the first
+// instance that carries them, while modalities, body parts and series descriptions are collected
+// across all series of the study.
 func mapMetadataToDocument(id string, metadata []map[string]interface{}) *search.Document {
 	doc := &search.Document{
 		ID:         id, // Elasticsearch document ID
@@ -112,43 +251,114 @@ func mapMetadataToDocument(id string, metadata []map[string]interface{}) *search
 		Categories: []string{"unsorted"},
 	}
 
+	series := make(map[string]struct{})
 	for _, elem := range metadata {
-		if tag, ok := elem["00080060"].(map[string]interface{}); ok {
-			if val, ok := tag["Value"].([]interface{}); ok && len(val) > 0 {
-				doc.Modality = val[0].(string)
+		mapStudyAttributes(doc, elem)
+
+		if uid := dicomString(elem, "0020000E"); uid != "" {
+			if _, seen := series[uid]; !seen {
+				series[uid] = struct{}{}
+				if desc := dicomString(elem, "0008103E"); desc != "" {
+					doc.SeriesDescriptions = appendUnique(doc.SeriesDescriptions, desc)
+				}
 			}
 		}
-		if tag, ok := elem["00100020"].(map[string]interface{}); ok {
-			if val, ok := tag["Value"].([]interface{}); ok && len(val) > 0 {
-				doc.PatientID = val[0].(string)
-			}
+		if modality := dicomString(elem, "00080060"); modality != "" {
+			doc.Modalities = appendUnique(doc.Modalities, modality)
 		}
-		if tag, ok := elem["00100010"].(map[string]interface{}); ok {
-			if val, ok := tag["Value"].([]interface{}); ok && len(val) > 0 {
-				doc.PatientName = val[0].(string)
-			}
+		if bodyPart := dicomString(elem, "00180015"); bodyPart != "" {
+			doc.BodyParts = appendUnique(doc.BodyParts, bodyPart)
+		}
+	}
+
+	if len(doc.Modalities) > 0 {
+		doc.Modality = doc.Modalities[0]
+	}
+	doc.SeriesCount = len(series)
+	doc.InstanceCount = len(metadata)
+
+	// No standard DICOM tags for report text or impression in image metadata.
+	// These fields are typically extracted from Structured Reports or external systems.
+	return doc
+}
+
+// mapStudyAttributes fills study-level attributes that are not yet set on the document from a
+// DICOM JSON dataset.
+func mapStudyAttributes(doc *search.Document, elem map[string]interface{}) {
+	setIfEmpty := func(field *string, tag string) {
+		if *field == "" {
+			*field = dicomString(elem, tag)
+		}
+	}
+
+	setIfEmpty(&doc.PatientID, "00100020")
+	setIfEmpty(&doc.PatientName, "00100010")
+	setIfEmpty(&doc.StudyDescription, "00081030")
+	setIfEmpty(&doc.ReferringPhysician, "00080090")
+	setIfEmpty(&doc.Institution, "00080080")
+
+	if doc.Gender == "" {
+		if sex := dicomString(elem, "00100040"); sex != "" {
+			doc.Gender = mapDICOMGender(sex)
 		}
-		if tag, ok := elem["00100040"].(map[string]interface{}); ok {
-			if val, ok := tag["Value"].([]interface{}); ok && len(val) > 0 {
-				doc.Gender = mapDICOMGender(val[0].(string))
+	}
+	if doc.StudyDate == "" {
+		if raw := dicomString(elem, "00080020"); raw != "" {
+			if len(raw) == 8 {
+				doc.StudyDate = fmt.Sprintf("%s-%s-%s", raw[:4], raw[4:6], raw[6:])
+			} else {
+				doc.StudyDate = raw
 			}
 		}
-		if tag, ok := elem["00080020"].(map[string]interface{}); ok {
-			if val, ok := tag["Value"].([]interface{}); ok && len(val) > 0 {
-				raw := val[0].(string)
-				if len(raw) == 8 {
-					doc.StudyDate = fmt.Sprintf("%s-%s-%s", raw[:4], raw[4:6], raw[6:])
-				} else {
-					doc.StudyDate = raw
-				}
+	}
+	if accession := dicomString(elem, "00080050"); accession != "" {
+		doc.AccessionNumbers = appendUnique(doc.AccessionNumbers, accession)
+	}
+}
+
+// dicomString returns the first value of a DICOM JSON attribute as a string.
+// Person names are returned in their alphabetic representation with "^" separators replaced by spaces.
+func dicomString(elem map[string]interface{}, tag string) string {
+	values := dicomStrings(elem, tag)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+// dicomInt returns the first value of a numeric DICOM JSON attribute, or 0 if it is absent.
+func dicomInt(elem map[string]interface{}, tag string) int {
+	n, _ := strconv.Atoi(dicomString(elem, tag))
+	return n
+}
+
+// dicomStrings returns all values of a DICOM JSON attribute as strings.
+func dicomStrings(elem map[string]interface{}, tag string) []string {
+	attr, ok := elem[tag].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	values, ok := attr["Value"].([]interface{})
+	if !ok {
+		return nil
+	}
+
+	out := make([]string, 0, len(values))
+	for _, v := range values {
+		switch val := v.(type) {
+		case string:
+			if val = strings.TrimSpace(val); val != "" {
+				out = append(out, val)
+			}
+		case float64:
+			out = append(out, strconv.FormatFloat(val, 'f', -1, 64))
+		case map[string]interface{}: // PN
+			if name, ok := val["Alphabetic"].(string); ok && name != "" {
+				out = append(out, strings.TrimSpace(strings.ReplaceAll(name, "^", " ")))
 			}
 		}
-		// No standard DICOM tags for report text or impression in image metadata.
-		// These fields are typically extracted from Structured Reports or external systems.
-		doc.ReportText = ""
-		doc.Impression = ""
 	}
-	return doc
+	return out
 }
 
 // mapDICOMGender maps DICOM gender values (e.g., "M", "F", "O", etc.) to "male", "female", or "other".
@@ -0,0 +1,184 @@
+package datasource
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"strconv"
+	"strings"
+)
+
+// dicomAccept is the Accept header sent with DICOMweb requests. DICOM JSON is preferred, with
+// plain JSON and the native DICOM XML model accepted as fallbacks.
+const dicomAccept = "application/dicom+json, application/json;q=0.9, multipart/related; type=\"application/dicom+xml\";q=0.5, application/dicom+xml;q=0.5"
+
+// decodeDatasets decodes a DICOMweb response body into a list of datasets in the DICOM JSON
+// model, regardless of whether the server answered with DICOM JSON, a single DICOM XML
+// document, or a multipart/related message of either.
+func decodeDatasets(contentType string, body io.Reader) ([]map[string]interface{}, error) {
+	mediaType := "application/dicom+json"
+	params := map[string]string{}
+	if contentType != "" {
+		var err error
+		mediaType, params, err = mime.ParseMediaType(contentType)
+		if err != nil {
+			return nil, fmt.Errorf("invalid content type %q: %w", contentType, err)
+		}
+	}
+
+	switch {
+	case mediaType == "multipart/related":
+		return decodeMultipartDatasets(params, body)
+	case strings.HasSuffix(mediaType, "xml"):
+		dataset, err := decodeNativeDicomModel(body)
+		if err != nil {
+			return nil, err
+		}
+		return []map[string]interface{}{dataset}, nil
+	case strings.HasSuffix(mediaType, "json"):
+		return decodeJSONDatasets(body)
+	default:
+		return nil, fmt.Errorf("unsupported content type %q", mediaType)
+	}
+}
+
+// decodeJSONDatasets decodes a DICOM JSON array of datasets. A single dataset object is also accepted.
+func decodeJSONDatasets(body io.Reader) ([]map[string]interface{}, error) {
+	var raw json.RawMessage
+	if err := json.NewDecoder(body).Decode(&raw); err != nil {
+		if err == io.EOF {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var datasets []map[string]interface{}
+	if err := json.Unmarshal(raw, &datasets); err == nil {
+		return datasets, nil
+	}
+
+	var dataset map[string]interface{}
+	if err := json.Unmarshal(raw, &dataset); err != nil {
+		return nil, err
+	}
+	return []map[string]interface{}{dataset}, nil
+}
+
+// decodeMultipartDatasets decodes each part of a multipart/related response as its own content type.
+// Parts without a Content-Type fall back to the "type" parameter of the enclosing message.
+func decodeMultipartDatasets(params map[string]string, body io.Reader) ([]map[string]interface{}, error) {
+	boundary := params["boundary"]
+	if boundary == "" {
+		return nil, fmt.Errorf("multipart response without boundary")
+	}
+
+	var datasets []map[string]interface{}
+	reader := multipart.NewReader(body, boundary)
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return datasets, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read multipart response: %w", err)
+		}
+
+		partType := part.Header.Get("Content-Type")
+		if partType == "" {
+			partType = params["type"]
+		}
+		if mediaType, _, _ := mime.ParseMediaType(partType); mediaType == "multipart/related" {
+			return nil, fmt.Errorf("nested multipart responses are not supported")
+		}
+
+		parsed, err := decodeDatasets(partType, part)
+		if err != nil {
+			return nil, err
+		}
+		datasets = append(datasets, parsed...)
+	}
+}
+
+// nativeDicomModel is a dataset in the native DICOM model (PS3.19 Annex A), the XML
+// representation used by application/dicom+xml.
+type nativeDicomModel struct {
+	Attributes []nativeAttribute `xml:"DicomAttribute"`
+}
+
+// nativeAttribute is a single attribute of a native DICOM model dataset.
+type nativeAttribute struct {
+	Tag         string             `xml:"tag,attr"`
+	VR          string             `xml:"vr,attr"`
+	Values      []string           `xml:"Value"`
+	PersonNames []nativePersonName `xml:"PersonName"`
+	Items       []nativeDicomModel `xml:"Item"`
+}
+
+// nativePersonName is a person name value in the native DICOM model.
+type nativePersonName struct {
+	Alphabetic *struct {
+		FamilyName string `xml:"FamilyName"`
+		GivenName  string `xml:"GivenName"`
+		MiddleName string `xml:"MiddleName"`
+		NamePrefix string `xml:"NamePrefix"`
+		NameSuffix string `xml:"NameSuffix"`
+	} `xml:"Alphabetic"`
+}
+
+// decodeNativeDicomModel parses an application/dicom+xml document into the DICOM JSON model.
+func decodeNativeDicomModel(body io.Reader) (map[string]interface{}, error) {
+	var model nativeDicomModel
+	if err := xml.NewDecoder(body).Decode(&model); err != nil {
+		return nil, fmt.Errorf("decode dicom+xml: %w", err)
+	}
+	return model.toJSONModel(), nil
+}
+
+// toJSONModel converts the dataset to its DICOM JSON model representation.
+func (m nativeDicomModel) toJSONModel() map[string]interface{} {
+	dataset := make(map[string]interface{}, len(m.Attributes))
+
+	for _, attr := range m.Attributes {
+		values := make([]interface{}, 0, len(attr.Values)+len(attr.PersonNames)+len(attr.Items))
+
+		switch attr.VR {
+		case "PN":
+			for _, pn := range attr.PersonNames {
+				if pn.Alphabetic == nil {
+					values = append(values, map[string]interface{}{})
+					continue
+				}
+				a := pn.Alphabetic
+				name := strings.TrimRight(strings.Join([]string{a.FamilyName, a.GivenName, a.MiddleName, a.NamePrefix, a.NameSuffix}, "^"), "^")
+				values = append(values, map[string]interface{}{"Alphabetic": name})
+			}
+		case "SQ":
+			for _, item := range attr.Items {
+				values = append(values, item.toJSONModel())
+			}
+		case "DS", "FL", "FD", "IS", "SL", "SS", "SV", "UL", "US", "UV":
+			for _, v := range attr.Values {
+				if n, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+					values = append(values, n)
+				} else {
+					values = append(values, v)
+				}
+			}
+		default:
+			for _, v := range attr.Values {
+				values = append(values, v)
+			}
+		}
+
+		elem := map[string]interface{}{"vr": attr.VR}
+		if len(values) > 0 {
+			elem["Value"] = values
+		}
+		dataset[strings.ToUpper(attr.Tag)] = elem
+	}
+
+	return dataset
+}
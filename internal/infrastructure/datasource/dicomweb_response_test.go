@@ -0,0 +1,133 @@
+package datasource
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// studyXML is a study in the native DICOM model.
+const studyXML = `<?xml version="1.0" encoding="UTF-8"?>
+<NativeDicomModel>
+  <DicomAttribute tag="0020000D" vr="UI"><Value number="1">1.2.3</Value></DicomAttribute>
+  <DicomAttribute tag="00100010" vr="PN">
+    <PersonName number="1"><Alphabetic><FamilyName>Doe</FamilyName><GivenName>Jane</GivenName></Alphabetic></PersonName>
+  </DicomAttribute>
+  <DicomAttribute tag="00201208" vr="IS"><Value number="1">12</Value></DicomAttribute>
+  <DicomAttribute tag="00081032" vr="SQ">
+    <Item number="1"><DicomAttribute tag="00080100" vr="SH"><Value number="1">CTCHEST</Value></DicomAttribute></Item>
+  </DicomAttribute>
+  <DicomAttribute tag="00081030" vr="LO"/>
+</NativeDicomModel>`
+
+// studyJSON is studyXML in the DICOM JSON model.
+var studyJSON = map[string]interface{}{
+	"0020000D": map[string]interface{}{"vr": "UI", "Value": []interface{}{"1.2.3"}},
+	"00100010": map[string]interface{}{"vr": "PN", "Value": []interface{}{map[string]interface{}{"Alphabetic": "Doe^Jane"}}},
+	"00201208": map[string]interface{}{"vr": "IS", "Value": []interface{}{float64(12)}},
+	"00081032": map[string]interface{}{"vr": "SQ", "Value": []interface{}{
+		map[string]interface{}{"00080100": map[string]interface{}{"vr": "SH", "Value": []interface{}{"CTCHEST"}}},
+	}},
+	"00081030": map[string]interface{}{"vr": "LO"},
+}
+
+// multipartBody joins parts, each given with its headers, into a multipart/related body.
+func multipartBody(boundary string, parts ...string) string {
+	var sb strings.Builder
+	for _, part := range parts {
+		sb.WriteString("--" + boundary + "\r\n" + part + "\r\n")
+	}
+	sb.WriteString("--" + boundary + "--\r\n")
+	return sb.String()
+}
+
+func TestDecodeDatasets(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        []map[string]interface{}
+		wantErr     string
+	}{
+		{
+			name:        "dicom json array",
+			contentType: "application/dicom+json",
+			body:        `[{"0020000D":{"vr":"UI","Value":["1.2.3"]}},{"0020000D":{"vr":"UI","Value":["4.5.6"]}}]`,
+			want: []map[string]interface{}{
+				{"0020000D": map[string]interface{}{"vr": "UI", "Value": []interface{}{"1.2.3"}}},
+				{"0020000D": map[string]interface{}{"vr": "UI", "Value": []interface{}{"4.5.6"}}},
+			},
+		},
+		{
+			name:        "single json dataset",
+			contentType: "application/json; charset=utf-8",
+			body:        `{"0020000D":{"vr":"UI","Value":["1.2.3"]}}`,
+			want:        []map[string]interface{}{{"0020000D": map[string]interface{}{"vr": "UI", "Value": []interface{}{"1.2.3"}}}},
+		},
+		{
+			name:        "empty body without content type",
+			contentType: "",
+			body:        "",
+			want:        nil,
+		},
+		{
+			name:        "dicom xml",
+			contentType: "application/dicom+xml",
+			body:        studyXML,
+			want:        []map[string]interface{}{studyJSON},
+		},
+		{
+			name:        "multipart of xml parts typed by the message",
+			contentType: `multipart/related; type="application/dicom+xml"; boundary=b1`,
+			body:        multipartBody("b1", "\r\n"+studyXML, "\r\n"+studyXML),
+			want:        []map[string]interface{}{studyJSON, studyJSON},
+		},
+		{
+			name:        "multipart of json parts typed by the part",
+			contentType: `multipart/related; boundary=b2`,
+			body:        multipartBody("b2", "Content-Type: application/dicom+json\r\n\r\n"+`[{"0020000D":{"vr":"UI","Value":["1.2.3"]}}]`),
+			want:        []map[string]interface{}{{"0020000D": map[string]interface{}{"vr": "UI", "Value": []interface{}{"1.2.3"}}}},
+		},
+		{
+			name:        "multipart without boundary",
+			contentType: `multipart/related; type="application/dicom+xml"`,
+			wantErr:     "without boundary",
+		},
+		{
+			name:        "nested multipart",
+			contentType: `multipart/related; boundary=outer`,
+			body:        multipartBody("outer", "Content-Type: multipart/related; boundary=inner\r\n\r\n"),
+			wantErr:     "nested multipart",
+		},
+		{
+			name:        "unsupported content type",
+			contentType: "text/html",
+			body:        "<html></html>",
+			wantErr:     "unsupported content type",
+		},
+		{
+			name:        "malformed xml",
+			contentType: "application/dicom+xml",
+			body:        "<NativeDicomModel><DicomAttribute",
+			wantErr:     "decode dicom+xml",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeDatasets(tt.contentType, strings.NewReader(tt.body))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("datasets = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
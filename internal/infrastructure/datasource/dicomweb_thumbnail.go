@@ -0,0 +1,142 @@
+package datasource
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"sort"
+
+	"github.com/yangszwei/koala/internal/usecase/thumbnail"
+)
+
+// Thumbnailer is implemented by clients that can render preview images of their studies.
+type Thumbnailer interface {
+	// Thumbnail renders a representative instance of a study to fit in size × size pixels. It
+	// returns thumbnail.ErrNoImage if the study has no instance that can be rendered.
+	Thumbnail(ctx context.Context, studyUID string, size int) ([]byte, error)
+	// FindStudy returns the StudyInstanceUID of the study with an accession number, or "" if
+	// there is none.
+	FindStudy(ctx context.Context, accessionNumber string) (string, error)
+}
+
+// maxThumbnailSize bounds the size of a rendered image read from an archive. Larger images are
+// rejected rather than truncated, since a truncated image cannot be decoded.
+const maxThumbnailSize = 10 << 20
+
+// nonImageModalities are the modalities of series without renderable pixel data.
+var nonImageModalities = map[string]bool{
+	"SR": true, "PR": true, "KO": true, "DOC": true, "REG": true, "SEG": true, "FID": true,
+	"RTSTRUCT": true, "RTPLAN": true, "RTDOSE": true, "RTRECORD": true, "ECG": true, "AU": true,
+}
+
+// Thumbnail renders the middle instance of the image series with the most instances. The
+// WADO-RS thumbnail resource is preferred, and the rendered resource is used for archives that
+// do not implement it.
+func (d *dicomwebClient) Thumbnail(ctx context.Context, studyUID string, size int) ([]byte, error) {
+	study := fmt.Sprintf("%s/studies/%s", d.base, neturl.PathEscape(studyUID))
+
+	series, err := d.getDatasets(ctx, study+"/series?includefield=00201209") // NumberOfSeriesRelatedInstances
+	if err != nil {
+		return nil, fmt.Errorf("list series: %w", err)
+	}
+	seriesUID := representativeSeries(series)
+	if seriesUID == "" {
+		return nil, thumbnail.ErrNoImage
+	}
+
+	instances, err := d.getDatasets(ctx, fmt.Sprintf("%s/series/%s/instances", study, neturl.PathEscape(seriesUID)))
+	if err != nil {
+		return nil, fmt.Errorf("list instances: %w", err)
+	}
+	instanceUID := middleInstance(instances)
+	if instanceUID == "" {
+		return nil, thumbnail.ErrNoImage
+	}
+
+	instance := fmt.Sprintf("%s/series/%s/instances/%s", study, neturl.PathEscape(seriesUID), neturl.PathEscape(instanceUID))
+	for _, resource := range []string{"thumbnail", "rendered"} {
+		data, err := d.getImage(ctx, fmt.Sprintf("%s/%s?viewport=%d,%d", instance, resource, size, size))
+		if err != nil || data != nil {
+			return data, err
+		}
+	}
+	return nil, thumbnail.ErrNoImage
+}
+
+// FindStudy looks up a study by its accession number using QIDO-RS.
+func (d *dicomwebClient) FindStudy(ctx context.Context, accessionNumber string) (string, error) {
+	studies, err := d.getDatasets(ctx, fmt.Sprintf("%s/studies?AccessionNumber=%s&limit=1", d.base, neturl.QueryEscape(accessionNumber)))
+	if err != nil {
+		return "", err
+	}
+	for _, s := range studies {
+		if uid := dicomString(s, "0020000D"); uid != "" { // StudyInstanceUID
+			return uid, nil
+		}
+	}
+	return "", nil
+}
+
+// getImage performs a GET request for a rendered image. It returns nil without an error if the
+// archive does not implement the resource or cannot render the instance.
+func (d *dicomwebClient) getImage(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "image/jpeg, image/png;q=0.9")
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		data, err := io.ReadAll(io.LimitReader(resp.Body, maxThumbnailSize+1))
+		if err != nil {
+			return nil, err
+		}
+		if len(data) > maxThumbnailSize {
+			return nil, fmt.Errorf("image exceeds %d bytes", maxThumbnailSize)
+		}
+		return data, nil
+	case http.StatusBadRequest, http.StatusNotFound, http.StatusNotAcceptable, http.StatusNotImplemented:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("bad status: %s", resp.Status)
+	}
+}
+
+// representativeSeries returns the UID of the image series with the most instances, or "" if the
+// study has no image series.
+func representativeSeries(series []map[string]interface{}) string {
+	var uid string
+	most := -1
+	for _, s := range series {
+		if nonImageModalities[dicomString(s, "00080060")] { // Modality
+			continue
+		}
+		if n := dicomInt(s, "00201209"); n > most { // NumberOfSeriesRelatedInstances
+			uid, most = dicomString(s, "0020000E"), n // SeriesInstanceUID
+		}
+	}
+	return uid
+}
+
+// middleInstance returns the UID of the middle instance of a series by instance number, which
+// usually shows the center of the scanned volume.
+func middleInstance(instances []map[string]interface{}) string {
+	sort.SliceStable(instances, func(i, j int) bool {
+		return dicomInt(instances[i], "00200013") < dicomInt(instances[j], "00200013") // InstanceNumber
+	})
+	for i := len(instances) / 2; i < len(instances); i++ {
+		if uid := dicomString(instances[i], "00080018"); uid != "" { // SOPInstanceUID
+			return uid
+		}
+	}
+	return ""
+}
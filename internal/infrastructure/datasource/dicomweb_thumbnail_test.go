@@ -0,0 +1,47 @@
+package datasource
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGetImage(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		size    int
+		wantLen int
+		wantErr bool
+	}{
+		{name: "rendered", status: http.StatusOK, size: 1024, wantLen: 1024},
+		{name: "at the size limit", status: http.StatusOK, size: maxThumbnailSize, wantLen: maxThumbnailSize},
+		{name: "over the size limit", status: http.StatusOK, size: maxThumbnailSize + 1, wantErr: true},
+		{name: "not implemented", status: http.StatusNotImplemented},
+		{name: "server error", status: http.StatusInternalServerError, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				_, _ = w.Write(bytes.Repeat([]byte{0xff}, tt.size))
+			}))
+			defer srv.Close()
+
+			client, err := NewDICOMwebClient("pacs", srv.URL, "", Auth{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := client.(*dicomwebClient).getImage(context.Background(), srv.URL+"/rendered")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %t", err, tt.wantErr)
+			}
+			if len(data) != tt.wantLen {
+				t.Errorf("len(data) = %d, want %d", len(data), tt.wantLen)
+			}
+		})
+	}
+}
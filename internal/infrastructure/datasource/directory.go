@@ -0,0 +1,183 @@
+package datasource
+
+import (
+	"context"
+	"net/url"
+	"regexp"
+	"sort"
+	"sync"
+
+	"github.com/yangszwei/koala/config"
+	"github.com/yangszwei/koala/internal/usecase/search"
+	"github.com/yangszwei/koala/internal/usecase/thumbnail"
+	"github.com/yangszwei/koala/pkg/elasticutil"
+)
+
+// Source describes the data source an indexed document was fetched from, with links back to the
+// original item.
+type Source struct {
+	Datasource   string `json:"datasource"`          // Configured name of the data source
+	Type         string `json:"type"`                // Data source type, e.g., "dicomweb" or "fhir"
+	ResourceType string `json:"resourceType"`        // Type of the original item, e.g., "DiagnosticReport"
+	ResourceID   string `json:"resourceId"`          // ID of the original item in the data source
+	FHIRURL      string `json:"fhirUrl,omitempty"`   // URL of the FHIR resource
+	WADOURL      string `json:"wadoUrl,omitempty"`   // WADO-RS URL of the DICOM study
+	QIDOURL      string `json:"qidoUrl,omitempty"`   // QIDO-RS query for the DICOM study
+	ViewerURL    string `json:"viewerUrl,omitempty"` // Deep link into the configured viewer
+}
+
+// Linker is implemented by clients whose items can be addressed on the source system.
+type Linker interface {
+	// Link fills in the resource type and URLs of the item identified by src.ResourceID.
+	Link(src *Source)
+}
+
+// Directory looks up the configured data sources of indexed documents.
+type Directory struct {
+	mu      sync.RWMutex
+	entries map[string]directoryEntry // by client name, as used in document IDs
+}
+
+// directoryEntry is a configured data source and its client.
+type directoryEntry struct {
+	cfg    config.DataSourceConfig
+	client Client
+}
+
+// NewDirectory creates an empty Directory.
+func NewDirectory() *Directory {
+	return &Directory{entries: make(map[string]directoryEntry)}
+}
+
+// Add registers the client created from a data source configuration.
+func (d *Directory) Add(cfg config.DataSourceConfig, client Client) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.entries[client.Name()] = directoryEntry{cfg: cfg, client: client}
+}
+
+// Type returns the configured type of a data source, looked up by its configured name or by its
+// client name.
+func (d *Directory) Type(name string) (string, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	entry, ok := d.entries[name]
+	if !ok {
+		entry, ok = d.entries[elasticutil.EscapeQueryString(name)]
+	}
+	return entry.cfg.Type, ok
+}
+
+// Source returns where a document was fetched from, or false if its ID was not built by a data
+// source or the data source is no longer configured.
+func (d *Directory) Source(doc search.Document) (*Source, bool) {
+	summary, ok := ParseDocID(doc.ID)
+	if !ok {
+		return nil, false
+	}
+
+	d.mu.RLock()
+	entry, ok := d.entries[summary.Source]
+	d.mu.RUnlock()
+	if !ok {
+		return nil, false
+	}
+
+	src := &Source{
+		Datasource:   entry.cfg.Name,
+		Type:         entry.cfg.Type,
+		ResourceType: summary.Type,
+		ResourceID:   summary.ID,
+	}
+	if linker, ok := entry.client.(Linker); ok {
+		linker.Link(src)
+	}
+
+	if entry.cfg.Viewer != "" {
+		values := map[string]string{
+			"resourceId": summary.ID,
+			"patientId":  doc.PatientID,
+		}
+		if summary.Type == "study" {
+			values["studyInstanceUid"] = summary.ID
+		}
+		if len(doc.AccessionNumbers) > 0 {
+			values["accessionNumber"] = doc.AccessionNumbers[0]
+		}
+		src.ViewerURL = expandTemplate(entry.cfg.Viewer, values)
+	}
+
+	return src, true
+}
+
+// Render renders a thumbnail of the study of a document. Studies are rendered by the data source
+// they were fetched from. Reports are rendered from the study with the same accession number in
+// any data source that can render thumbnails.
+func (d *Directory) Render(ctx context.Context, doc search.Document, size int) ([]byte, error) {
+	if summary, ok := ParseDocID(doc.ID); ok && summary.Type == "study" {
+		d.mu.RLock()
+		entry := d.entries[summary.Source]
+		d.mu.RUnlock()
+		if t, ok := entry.client.(Thumbnailer); ok {
+			return t.Thumbnail(ctx, summary.ID, size)
+		}
+	}
+
+	var lastErr error
+	for _, t := range d.thumbnailers() {
+		for _, accession := range doc.AccessionNumbers {
+			uid, err := t.FindStudy(ctx, accession)
+			if err != nil {
+				lastErr = err
+				continue
+			}
+			if uid != "" {
+				return t.Thumbnail(ctx, uid, size)
+			}
+		}
+	}
+	if lastErr != nil {
+		return nil, lastErr
+	}
+	return nil, thumbnail.ErrNoImage
+}
+
+// thumbnailers returns the clients that can render thumbnails, ordered by name.
+func (d *Directory) thumbnailers() []Thumbnailer {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	names := make([]string, 0, len(d.entries))
+	for name, entry := range d.entries {
+		if _, ok := entry.client.(Thumbnailer); ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	thumbnailers := make([]Thumbnailer, len(names))
+	for i, name := range names {
+		thumbnailers[i] = d.entries[name].client.(Thumbnailer)
+	}
+	return thumbnailers
+}
+
+// placeholder matches the placeholders of a link template, e.g., "{studyInstanceUid}".
+var placeholder = regexp.MustCompile(`\{(\w+)\}`)
+
+// expandTemplate replaces the placeholders of a link template with their query-escaped values.
+// It returns "" if a placeholder has no value, since the link would not lead anywhere.
+func expandTemplate(template string, values map[string]string) string {
+	complete := true
+	link := placeholder.ReplaceAllStringFunc(template, func(m string) string {
+		value := values[m[1:len(m)-1]]
+		if value == "" {
+			complete = false
+		}
+		return url.QueryEscape(value)
+	})
+	if !complete {
+		return ""
+	}
+	return link
+}
@@ -2,10 +2,10 @@ package datasource
 
 import (
 	"context"
-	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	neturl "net/url"
 	"strings"
 	"time"
 
@@ -20,6 +20,15 @@ type fhirClient struct {
 	client *http.Client
 }
 
+func init() {
+	Register("fhir", func(name, url string, opts Options) (Client, error) {
+		if err := opts.Validate(); err != nil {
+			return nil, err
+		}
+		return NewFHIRClient(name, url), nil
+	})
+}
+
 // NewFHIRClient creates a new FHIR client.
 func NewFHIRClient(name, base string) Client {
 	return &fhirClient{
@@ -33,6 +42,12 @@ func (f *fhirClient) Name() string {
 	return elasticutil.EscapeQueryString(f.name)
 }
 
+// Link adds the URL of a DiagnosticReport.
+func (f *fhirClient) Link(src *Source) {
+	src.ResourceType = "DiagnosticReport"
+	src.FHIRURL = fmt.Sprintf("%s/DiagnosticReport/%s", f.base, neturl.PathEscape(src.ResourceID))
+}
+
 func (f *fhirClient) Count(ctx context.Context) (int, error) {
 	req, err := http.NewRequestWithContext(ctx, "GET", f.base+"/DiagnosticReport?_summary=count", nil)
 	if err != nil {
@@ -54,11 +69,20 @@ func (f *fhirClient) Count(ctx context.Context) (int, error) {
 }
 
 func (f *fhirClient) Stream(ctx context.Context, pageSize int) (<-chan DataSummary, error) {
+	return f.StreamFrom(ctx, pageSize, "")
+}
+
+// StreamFrom streams reports in order of their last update. The cursor of each summary is the
+// report's meta.lastUpdated, so a resumed stream starts with the reports updated at or after it.
+func (f *fhirClient) StreamFrom(ctx context.Context, pageSize int, cursor string) (<-chan DataSummary, error) {
 	out := make(chan DataSummary, 100)
 
 	go func() {
 		defer close(out)
-		url := fmt.Sprintf("%s/DiagnosticReport?_count=%d", f.base, pageSize)
+		url := fmt.Sprintf("%s/DiagnosticReport?_count=%d&_sort=_lastUpdated", f.base, pageSize)
+		if cursor != "" {
+			url += "&_lastUpdated=ge" + neturl.QueryEscape(cursor)
+		}
 
 		wait := 250 * time.Millisecond
 		slowThreshold := 500 * time.Millisecond
@@ -90,12 +114,16 @@ func (f *fhirClient) Stream(ctx context.Context, pageSize int) (<-chan DataSumma
 
 			for _, e := range bundle.Entry {
 				if id, ok := e.Resource["id"].(string); ok {
-					out <- DataSummary{
+					summary := DataSummary{
 						ID:     id,
 						Source: f.Name(),
 						Type:   "report",
 						Raw:    e.Resource,
 					}
+					if meta, ok := e.Resource["meta"].(map[string]interface{}); ok {
+						summary.Cursor, _ = meta["lastUpdated"].(string)
+					}
+					out <- summary
 				}
 			}
 
@@ -126,10 +154,67 @@ func (f *fhirClient) Stream(ctx context.Context, pageSize int) (<-chan DataSumma
 	return out, nil
 }
 
-func (f *fhirClient) Fetch(_ context.Context, summary DataSummary) (*search.Document, error) {
-	res := summary.Raw.(map[string]interface{})
+func (f *fhirClient) Fetch(ctx context.Context, summary DataSummary) (*search.Document, error) {
+	res, ok := summary.Raw.(map[string]interface{})
+	if !ok {
+		var err error
+		if res, err = f.getReport(ctx, summary.ID); err != nil {
+			return nil, err
+		}
+	}
+	doc := f.mapReport(ctx, summary.DocID(), res)
+	if doc.PatientID != "" {
+		f.populatePatientInfo(ctx, doc)
+	}
+
+	return doc, nil
+}
+
+// getReport reads a single DiagnosticReport resource by ID.
+func (f *fhirClient) getReport(ctx context.Context, id string) (map[string]interface{}, error) {
+	res, err := f.getResource(ctx, "DiagnosticReport", id)
+	if err != nil {
+		return nil, fmt.Errorf("get report: %w", err)
+	}
+	if res == nil {
+		return nil, fmt.Errorf("report %s not found", id)
+	}
+	return res, nil
+}
+
+// getResource reads a single resource by type and ID. It returns nil without an error if the
+// resource does not exist or was deleted.
+func (f *fhirClient) getResource(ctx context.Context, typ, id string) (map[string]interface{}, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/%s/%s", f.base, typ, neturl.PathEscape(id)), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/fhir+json")
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound, http.StatusGone:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("get %s/%s: unexpected status %s", typ, id, resp.Status)
+	}
+
+	var res map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return nil, fmt.Errorf("decode %s: %w", typ, err)
+	}
+	return res, nil
+}
+
+// mapReport converts a DiagnosticReport resource to a search.Document. Patient demographics are
+// not resolved; only the patient ID is taken from the subject reference.
+func (f *fhirClient) mapReport(ctx context.Context, id string, res map[string]interface{}) *search.Document {
 	doc := &search.Document{
-		ID:         summary.DocID(),
+		ID:         id,
 		Type:       "report",
 		Categories: []string{},
 	}
@@ -154,43 +239,153 @@ func (f *fhirClient) Fetch(_ context.Context, summary DataSummary) (*search.Docu
 		doc.Impression = concl
 	}
 
-	if forms, ok := res["presentedForm"].([]interface{}); ok && len(forms) > 0 {
-		if data, ok := forms[0].(map[string]interface{})["data"].(string); ok {
-			if decoded, err := base64.StdEncoding.DecodeString(data); err == nil {
-				doc.ReportText = string(decoded)
-			}
-		}
-	}
+	mapReportMetadata(doc, res)
+
+	doc.ReportText = f.extractReportText(ctx, res)
 
 	if subj, ok := res["subject"].(map[string]interface{}); ok {
 		if ref, ok := subj["reference"].(string); ok && strings.HasPrefix(ref, "Patient/") {
 			doc.PatientID = strings.TrimPrefix(ref, "Patient/")
-			f.populatePatientInfo(doc)
 		}
 	}
 
-	return doc, nil
+	return doc
+}
+
+// mapReportMetadata copies the status, code, performers, issue time, encounter and accession
+// numbers of a DiagnosticReport resource into the document.
+func mapReportMetadata(doc *search.Document, res map[string]interface{}) {
+	doc.Status, _ = res["status"].(string)
+	doc.Issued, _ = res["issued"].(string)
+
+	if code, ok := res["code"].(map[string]interface{}); ok {
+		doc.CodeDisplay, _ = code["text"].(string)
+		if coding, ok := code["coding"].([]interface{}); ok {
+			for _, c := range coding {
+				cm, ok := c.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				value, _ := cm["code"].(string)
+				if value == "" {
+					continue
+				}
+				if system, ok := cm["system"].(string); ok && system != "" {
+					doc.Codes = append(doc.Codes, system+"|"+value)
+				}
+				doc.Codes = append(doc.Codes, value)
+				if display, ok := cm["display"].(string); ok && doc.CodeDisplay == "" {
+					doc.CodeDisplay = display
+				}
+			}
+		}
+	}
+
+	for _, key := range []string{"performer", "resultsInterpreter"} {
+		refs, ok := res[key].([]interface{})
+		if !ok {
+			continue
+		}
+		for _, r := range refs {
+			rm, ok := r.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if display, ok := rm["display"].(string); ok && display != "" {
+				doc.Performers = appendUnique(doc.Performers, display)
+			}
+			if ref, ok := rm["reference"].(string); ok && ref != "" {
+				doc.PerformerIDs = appendUnique(doc.PerformerIDs, ref)
+			}
+		}
+	}
+
+	if enc, ok := res["encounter"].(map[string]interface{}); ok {
+		if ref, ok := enc["reference"].(string); ok {
+			doc.EncounterID = strings.TrimPrefix(ref, "Encounter/")
+		}
+	}
+
+	// Accession numbers are carried either as identifiers on the basedOn order references
+	// or as report identifiers typed "ACSN".
+	if basedOn, ok := res["basedOn"].([]interface{}); ok {
+		for _, b := range basedOn {
+			if bm, ok := b.(map[string]interface{}); ok {
+				if ident, ok := bm["identifier"].(map[string]interface{}); ok {
+					if value, ok := ident["value"].(string); ok && value != "" {
+						doc.AccessionNumbers = appendUnique(doc.AccessionNumbers, value)
+					}
+				}
+			}
+		}
+	}
+	if idents, ok := res["identifier"].([]interface{}); ok {
+		for _, i := range idents {
+			im, ok := i.(map[string]interface{})
+			if !ok || !isAccessionIdentifier(im) {
+				continue
+			}
+			if value, ok := im["value"].(string); ok && value != "" {
+				doc.AccessionNumbers = appendUnique(doc.AccessionNumbers, value)
+			}
+		}
+	}
+}
+
+// isAccessionIdentifier reports whether a FHIR Identifier is typed as an accession number (v2-0203 "ACSN").
+func isAccessionIdentifier(ident map[string]interface{}) bool {
+	typ, ok := ident["type"].(map[string]interface{})
+	if !ok {
+		return false
+	}
+	coding, ok := typ["coding"].([]interface{})
+	if !ok {
+		return false
+	}
+	for _, c := range coding {
+		if cm, ok := c.(map[string]interface{}); ok && cm["code"] == "ACSN" {
+			return true
+		}
+	}
+	return false
+}
+
+// appendUnique appends value to values unless it is already present.
+func appendUnique(values []string, value string) []string {
+	for _, v := range values {
+		if v == value {
+			return values
+		}
+	}
+	return append(values, value)
 }
 
 // populatePatientInfo fetches and populates patient information from the FHIR server.
-func (f *fhirClient) populatePatientInfo(doc *search.Document) {
+func (f *fhirClient) populatePatientInfo(ctx context.Context, doc *search.Document) {
 	url := fmt.Sprintf("%s/Patient/%s", f.base, doc.PatientID)
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return
 	}
-	req = req.WithContext(context.Background())
 	resp, err := f.client.Do(req)
-	if err != nil || resp.StatusCode != http.StatusOK {
+	if err != nil {
 		return
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return
+	}
 
 	var patient map[string]interface{}
 	if err := json.NewDecoder(resp.Body).Decode(&patient); err != nil {
 		return
 	}
 
+	applyPatient(doc, patient)
+}
+
+// applyPatient copies the name and gender of a Patient resource into the document.
+func applyPatient(doc *search.Document, patient map[string]interface{}) {
 	if nameList, ok := patient["name"].([]interface{}); ok && len(nameList) > 0 {
 		if nameMap, ok := nameList[0].(map[string]interface{}); ok {
 			given := ""
@@ -0,0 +1,158 @@
+package datasource
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/yangszwei/koala/pkg/textutil"
+)
+
+// maxAttachmentSize bounds the size of a downloaded Binary resource. Larger attachments are
+// skipped, rather than indexing the text of their first part as if it were the whole report.
+const maxAttachmentSize = 32 << 20
+
+// extractReportText builds the report text from all presentedForm attachments of a DiagnosticReport.
+// If no attachment yields any text, the narrative in text.div is used instead.
+func (f *fhirClient) extractReportText(ctx context.Context, res map[string]interface{}) string {
+	var parts []string
+
+	if forms, ok := res["presentedForm"].([]interface{}); ok {
+		for i, form := range forms {
+			att, ok := form.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			text, err := f.attachmentText(ctx, att)
+			if err != nil {
+				log.Printf("[%s] Skipping presentedForm[%d] of DiagnosticReport/%v: %v", f.name, i, res["id"], err)
+				continue
+			}
+			if text != "" {
+				parts = append(parts, text)
+			}
+		}
+	}
+
+	if len(parts) == 0 {
+		if narrative, ok := res["text"].(map[string]interface{}); ok {
+			if div, ok := narrative["div"].(string); ok {
+				return textutil.StripHTML(div)
+			}
+		}
+	}
+
+	return strings.Join(parts, "\n\n")
+}
+
+// attachmentText returns the plain text of a FHIR Attachment, reading either its inline data or
+// the Binary resource referenced by its url.
+func (f *fhirClient) attachmentText(ctx context.Context, att map[string]interface{}) (string, error) {
+	contentType, _ := att["contentType"].(string)
+
+	var data []byte
+	if encoded, ok := att["data"].(string); ok && encoded != "" {
+		decoded, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return "", fmt.Errorf("decode attachment data: %w", err)
+		}
+		data = decoded
+	} else if url, ok := att["url"].(string); ok && url != "" {
+		fetched, fetchedType, err := f.fetchBinary(ctx, url)
+		if err != nil {
+			return "", err
+		}
+		data = fetched
+		if contentType == "" {
+			contentType = fetchedType
+		}
+	} else {
+		return "", nil
+	}
+
+	return decodeAttachment(contentType, data)
+}
+
+// fetchBinary downloads the content referenced by an attachment url. Relative references such as
+// "Binary/123" are resolved against the server base. Both raw content and Binary resources
+// returned as FHIR JSON are supported.
+func (f *fhirClient) fetchBinary(ctx context.Context, ref string) ([]byte, string, error) {
+	url := ref
+	if !strings.HasPrefix(ref, "http://") && !strings.HasPrefix(ref, "https://") {
+		url = f.base + "/" + strings.TrimPrefix(ref, "/")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("Accept", "application/fhir+json, */*;q=0.8")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("fetch %s: bad status: %s", ref, resp.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxAttachmentSize+1))
+	if err != nil {
+		return nil, "", fmt.Errorf("read %s: %w", ref, err)
+	}
+	if len(body) > maxAttachmentSize {
+		return nil, "", fmt.Errorf("%s exceeds %d bytes", ref, maxAttachmentSize)
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	if mediaType, _, _ := mime.ParseMediaType(contentType); strings.HasSuffix(mediaType, "json") {
+		var binary struct {
+			ResourceType string `json:"resourceType"`
+			ContentType  string `json:"contentType"`
+			Data         string `json:"data"`
+		}
+		if err := json.Unmarshal(body, &binary); err == nil && binary.ResourceType == "Binary" {
+			decoded, err := base64.StdEncoding.DecodeString(binary.Data)
+			if err != nil {
+				return nil, "", fmt.Errorf("decode Binary data: %w", err)
+			}
+			return decoded, binary.ContentType, nil
+		}
+	}
+
+	return body, contentType, nil
+}
+
+// decodeAttachment converts attachment content to plain text based on its content type.
+// Content without a declared type is treated as plain text.
+func decodeAttachment(contentType string, data []byte) (string, error) {
+	mediaType := "text/plain"
+	if contentType != "" {
+		parsed, _, err := mime.ParseMediaType(contentType)
+		if err != nil {
+			return "", fmt.Errorf("invalid content type %q: %w", contentType, err)
+		}
+		mediaType = parsed
+	}
+
+	switch mediaType {
+	case "text/plain":
+		return textutil.Normalize(string(data)), nil
+	case "text/html", "application/xhtml+xml":
+		return textutil.StripHTML(string(data)), nil
+	case "text/rtf", "application/rtf":
+		return textutil.StripRTF(string(data)), nil
+	case "application/pdf":
+		return textutil.ExtractPDF(data)
+	default:
+		return "", fmt.Errorf("unsupported content type %q", mediaType)
+	}
+}
@@ -0,0 +1,58 @@
+package datasource
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFetchBinary(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		contentType string
+		body        []byte
+		wantLen     int
+		wantType    string
+		wantErr     bool
+	}{
+		{name: "raw content", status: http.StatusOK, contentType: "text/plain", body: []byte("normal"), wantLen: 6, wantType: "text/plain"},
+		{
+			name:        "Binary resource",
+			status:      http.StatusOK,
+			contentType: "application/fhir+json",
+			body:        []byte(`{"resourceType":"Binary","contentType":"text/html","data":"PHA+bm9ybWFsPC9wPg=="}`),
+			wantLen:     13,
+			wantType:    "text/html",
+		},
+		{name: "at the size limit", status: http.StatusOK, contentType: "text/plain", body: bytes.Repeat([]byte("a"), maxAttachmentSize), wantLen: maxAttachmentSize, wantType: "text/plain"},
+		{name: "over the size limit", status: http.StatusOK, contentType: "text/plain", body: bytes.Repeat([]byte("a"), maxAttachmentSize+1), wantErr: true},
+		{name: "not found", status: http.StatusNotFound, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/Binary/1" {
+					http.NotFound(w, r)
+					return
+				}
+				w.Header().Set("Content-Type", tt.contentType)
+				w.WriteHeader(tt.status)
+				_, _ = w.Write(tt.body)
+			}))
+			defer srv.Close()
+
+			client := NewFHIRClient("fhir", srv.URL).(*fhirClient)
+			data, contentType, err := client.fetchBinary(context.Background(), "Binary/1")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %t", err, tt.wantErr)
+			}
+			if len(data) != tt.wantLen || contentType != tt.wantType {
+				t.Errorf("fetchBinary() = %d bytes of %q, want %d bytes of %q", len(data), contentType, tt.wantLen, tt.wantType)
+			}
+		})
+	}
+}
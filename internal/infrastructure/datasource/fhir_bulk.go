@@ -0,0 +1,464 @@
+package datasource
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yangszwei/koala/internal/usecase/search"
+	"github.com/yangszwei/koala/pkg/elasticutil"
+)
+
+// bulkExportTypes lists the resource types requested from the $export operation. Patients and
+// imaging studies are downloaded before reports so they can be joined locally.
+var bulkExportTypes = []string{"Patient", "ImagingStudy", "DiagnosticReport"}
+
+// bulkReport is the backend-specific context of a DiagnosticReport read from a bulk export.
+type bulkReport struct {
+	resource map[string]interface{}
+	patient  map[string]interface{}
+	studies  []map[string]interface{}
+}
+
+// fhirBulkClient implements the Streamer interface using the FHIR Bulk Data $export operation.
+type fhirBulkClient struct {
+	fhir   *fhirClient  // used for report mapping and attachment downloads
+	client *http.Client // without timeout, since NDJSON downloads may be large
+	since  string       // transactionTime of the last completed export
+	mu     sync.Mutex
+}
+
+func init() {
+	Register("fhir-bulk", func(name, url string, opts Options) (Client, error) {
+		if err := opts.Validate(); err != nil {
+			return nil, err
+		}
+		return NewFHIRBulkClient(name, url), nil
+	})
+}
+
+// NewFHIRBulkClient creates a new FHIR Bulk Data client.
+func NewFHIRBulkClient(name, base string) Client {
+	return &fhirBulkClient{
+		fhir: &fhirClient{
+			name:   name,
+			base:   strings.TrimSuffix(base, "/"),
+			client: &http.Client{Timeout: 10 * time.Second},
+		},
+		client: &http.Client{},
+	}
+}
+
+func (b *fhirBulkClient) Name() string {
+	return elasticutil.EscapeQueryString(b.fhir.name)
+}
+
+// Link adds the URL of a DiagnosticReport on the exporting server.
+func (b *fhirBulkClient) Link(src *Source) {
+	b.fhir.Link(src)
+}
+
+// Capabilities reports that exports after the first one only contain changed resources.
+func (b *fhirBulkClient) Capabilities() Capabilities {
+	return Capabilities{Incremental: true}
+}
+
+// State returns the transaction time of the last completed export.
+func (b *fhirBulkClient) State() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.since
+}
+
+// Restore sets the transaction time that the next export requests changes since.
+func (b *fhirBulkClient) Restore(state string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.since = state
+}
+
+// Stream runs a bulk export and emits a summary for each exported DiagnosticReport. After the first
+// completed export, subsequent calls only request resources changed since its transaction time.
+// References to resources that were not exported again are resolved by Fetch.
+func (b *fhirBulkClient) Stream(ctx context.Context, _ int) (<-chan DataSummary, error) {
+	b.mu.Lock()
+	since := b.since
+	b.mu.Unlock()
+
+	statusURL, err := b.kickoff(ctx, since)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan DataSummary, 100)
+
+	go func() {
+		defer close(out)
+		defer b.cleanup(statusURL)
+
+		manifest, err := b.poll(ctx, statusURL)
+		if err != nil {
+			log.Printf("[%s] Bulk export failed: %v", b.fhir.name, err)
+			return
+		}
+		for _, e := range manifest.Error {
+			log.Printf("[%s] Bulk export reported errors in %s", b.fhir.name, e.URL)
+		}
+
+		patients := make(map[string]map[string]interface{})
+		studies := make(map[string]map[string]interface{})
+		for _, file := range manifest.filesOf("Patient") {
+			err = b.download(ctx, file, func(res map[string]interface{}) {
+				if id, ok := res["id"].(string); ok {
+					patients[id] = res
+				}
+			})
+			if err != nil {
+				log.Printf("[%s] Download of %s failed: %v", b.fhir.name, file, err)
+				return
+			}
+		}
+		for _, file := range manifest.filesOf("ImagingStudy") {
+			err = b.download(ctx, file, func(res map[string]interface{}) {
+				if id, ok := res["id"].(string); ok {
+					studies[id] = res
+				}
+			})
+			if err != nil {
+				log.Printf("[%s] Download of %s failed: %v", b.fhir.name, file, err)
+				return
+			}
+		}
+
+		for _, file := range manifest.filesOf("DiagnosticReport") {
+			err = b.download(ctx, file, func(res map[string]interface{}) {
+				id, ok := res["id"].(string)
+				if !ok {
+					return
+				}
+				raw := &bulkReport{resource: res}
+				if ref := referenceID(res["subject"], "Patient"); ref != "" {
+					raw.patient = patients[ref]
+				}
+				if refs, ok := res["imagingStudy"].([]interface{}); ok {
+					for _, r := range refs {
+						if study, ok := studies[referenceID(r, "ImagingStudy")]; ok {
+							raw.studies = append(raw.studies, study)
+						}
+					}
+				}
+				select {
+				case <-ctx.Done():
+				case out <- DataSummary{ID: id, Source: b.Name(), Type: "report", Raw: raw}:
+				}
+			})
+			if err != nil {
+				log.Printf("[%s] Download of %s failed: %v", b.fhir.name, file, err)
+				return
+			}
+		}
+
+		if ctx.Err() != nil {
+			return
+		}
+		b.mu.Lock()
+		b.since = manifest.TransactionTime
+		b.mu.Unlock()
+	}()
+
+	return out, nil
+}
+
+func (b *fhirBulkClient) Fetch(ctx context.Context, summary DataSummary) (*search.Document, error) {
+	raw, ok := summary.Raw.(*bulkReport)
+	if !ok {
+		return nil, fmt.Errorf("report %s was not read from a bulk export", summary.ID)
+	}
+	if err := b.resolveReferences(ctx, raw); err != nil {
+		return nil, err
+	}
+
+	doc := b.fhir.mapReport(ctx, summary.DocID(), raw.resource)
+	if raw.patient != nil {
+		applyPatient(doc, raw.patient)
+	}
+	for _, study := range raw.studies {
+		applyImagingStudy(doc, study)
+	}
+
+	return doc, nil
+}
+
+// resolveReferences reads the patient and imaging studies referenced by a report that were not
+// part of its export. Exports with _since only contain resources changed since the previous
+// export, so the patient and studies of a changed report are usually missing.
+func (b *fhirBulkClient) resolveReferences(ctx context.Context, raw *bulkReport) error {
+	if raw.patient == nil {
+		if id := referenceID(raw.resource["subject"], "Patient"); id != "" {
+			patient, err := b.fhir.getResource(ctx, "Patient", id)
+			if err != nil {
+				return fmt.Errorf("resolve patient: %w", err)
+			}
+			raw.patient = patient
+		}
+	}
+
+	joined := make(map[string]bool, len(raw.studies))
+	for _, study := range raw.studies {
+		if id, ok := study["id"].(string); ok {
+			joined[id] = true
+		}
+	}
+	refs, _ := raw.resource["imagingStudy"].([]interface{})
+	for _, ref := range refs {
+		id := referenceID(ref, "ImagingStudy")
+		if id == "" || joined[id] {
+			continue
+		}
+		study, err := b.fhir.getResource(ctx, "ImagingStudy", id)
+		if err != nil {
+			return fmt.Errorf("resolve imaging study: %w", err)
+		}
+		if study != nil {
+			raw.studies = append(raw.studies, study)
+			joined[id] = true
+		}
+	}
+	return nil
+}
+
+// storedBulkReport is the serialized form of a bulkReport.
+type storedBulkReport struct {
+	Resource map[string]interface{}   `json:"resource"`
+	Patient  map[string]interface{}   `json:"patient,omitempty"`
+	Studies  []map[string]interface{} `json:"studies,omitempty"`
+}
+
+// EncodeRaw serializes a report with the patient and studies it was joined with.
+func (b *fhirBulkClient) EncodeRaw(raw any) (json.RawMessage, error) {
+	report, ok := raw.(*bulkReport)
+	if !ok {
+		return nil, fmt.Errorf("unexpected raw type %T", raw)
+	}
+	return json.Marshal(storedBulkReport{Resource: report.resource, Patient: report.patient, Studies: report.studies})
+}
+
+// DecodeRaw restores a report serialized by EncodeRaw.
+func (b *fhirBulkClient) DecodeRaw(data json.RawMessage) (any, error) {
+	var stored storedBulkReport
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, err
+	}
+	return &bulkReport{resource: stored.Resource, patient: stored.Patient, studies: stored.Studies}, nil
+}
+
+// bulkManifest is the completion response of a bulk export status request.
+type bulkManifest struct {
+	TransactionTime string `json:"transactionTime"`
+	Output          []struct {
+		Type string `json:"type"`
+		URL  string `json:"url"`
+	} `json:"output"`
+	Error []struct {
+		Type string `json:"type"`
+		URL  string `json:"url"`
+	} `json:"error"`
+}
+
+// filesOf returns the URLs of the output files containing resources of the given type.
+func (m *bulkManifest) filesOf(typ string) []string {
+	var urls []string
+	for _, o := range m.Output {
+		if o.Type == typ {
+			urls = append(urls, o.URL)
+		}
+	}
+	return urls
+}
+
+// kickoff starts a system-level bulk export and returns the status URL to poll.
+func (b *fhirBulkClient) kickoff(ctx context.Context, since string) (string, error) {
+	params := url.Values{}
+	params.Set("_type", strings.Join(bulkExportTypes, ","))
+	if since != "" {
+		params.Set("_since", since)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", b.fhir.base+"/$export?"+params.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/fhir+json")
+	req.Header.Set("Prefer", "respond-async")
+
+	resp, err := b.fhir.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusAccepted {
+		return "", fmt.Errorf("export kickoff: bad status: %s", resp.Status)
+	}
+
+	location := resp.Header.Get("Content-Location")
+	if location == "" {
+		return "", fmt.Errorf("export kickoff: missing Content-Location header")
+	}
+	return location, nil
+}
+
+// poll waits for the export to complete and returns its manifest. The Retry-After header of
+// in-progress responses is honored.
+func (b *fhirBulkClient) poll(ctx context.Context, statusURL string) (*bulkManifest, error) {
+	for {
+		req, err := http.NewRequestWithContext(ctx, "GET", statusURL, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Accept", "application/json")
+
+		resp, err := b.fhir.client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+
+		switch resp.StatusCode {
+		case http.StatusOK:
+			var manifest bulkManifest
+			err := json.NewDecoder(resp.Body).Decode(&manifest)
+			resp.Body.Close()
+			if err != nil {
+				return nil, fmt.Errorf("decode export manifest: %w", err)
+			}
+			return &manifest, nil
+		case http.StatusAccepted:
+			resp.Body.Close()
+			wait := 5 * time.Second
+			if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs > 0 {
+				wait = time.Duration(secs) * time.Second
+			}
+			log.Printf("[%s] Bulk export in progress %s", b.fhir.name, resp.Header.Get("X-Progress"))
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(wait):
+			}
+		default:
+			resp.Body.Close()
+			return nil, fmt.Errorf("export status: bad status: %s", resp.Status)
+		}
+	}
+}
+
+// download reads an NDJSON output file and calls fn for every resource in it.
+func (b *fhirBulkClient) download(ctx context.Context, fileURL string, fn func(map[string]interface{})) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", fileURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/fhir+ndjson")
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("bad status: %s", resp.Status)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxAttachmentSize)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var res map[string]interface{}
+		if err := json.Unmarshal(line, &res); err != nil {
+			return fmt.Errorf("decode resource: %w", err)
+		}
+		fn(res)
+	}
+	return scanner.Err()
+}
+
+// cleanup asks the server to delete the export files once they have been consumed.
+func (b *fhirBulkClient) cleanup(statusURL string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "DELETE", statusURL, nil)
+	if err != nil {
+		return
+	}
+	if resp, err := b.fhir.client.Do(req); err == nil {
+		resp.Body.Close()
+	}
+}
+
+// referenceID returns the ID of a FHIR Reference to a resource of the given type, or "" if the
+// value is not such a reference.
+func referenceID(value interface{}, typ string) string {
+	ref, ok := value.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	s, _ := ref["reference"].(string)
+	if !strings.HasPrefix(s, typ+"/") {
+		return ""
+	}
+	return strings.TrimPrefix(s, typ+"/")
+}
+
+// applyImagingStudy copies the modalities, body parts and series descriptions of an ImagingStudy
+// resource into a report document.
+func applyImagingStudy(doc *search.Document, study map[string]interface{}) {
+	if modalities, ok := study["modality"].([]interface{}); ok {
+		for _, m := range modalities {
+			if code, ok := m.(map[string]interface{})["code"].(string); ok {
+				doc.Modalities = appendUnique(doc.Modalities, code)
+			}
+		}
+	}
+	if desc, ok := study["description"].(string); ok && doc.StudyDescription == "" {
+		doc.StudyDescription = desc
+	}
+
+	if series, ok := study["series"].([]interface{}); ok {
+		for _, s := range series {
+			sm, ok := s.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if modality, ok := sm["modality"].(map[string]interface{}); ok {
+				if code, ok := modality["code"].(string); ok {
+					doc.Modalities = appendUnique(doc.Modalities, code)
+				}
+			}
+			if bodySite, ok := sm["bodySite"].(map[string]interface{}); ok {
+				if display, ok := bodySite["display"].(string); ok {
+					doc.BodyParts = appendUnique(doc.BodyParts, display)
+				}
+			}
+			if desc, ok := sm["description"].(string); ok {
+				doc.SeriesDescriptions = appendUnique(doc.SeriesDescriptions, desc)
+			}
+		}
+	}
+
+	if doc.Modality == "" && len(doc.Modalities) > 0 {
+		doc.Modality = doc.Modalities[0]
+	}
+}
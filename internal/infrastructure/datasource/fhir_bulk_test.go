@@ -0,0 +1,78 @@
+package datasource
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestFHIRBulkFetchResolvesReferences(t *testing.T) {
+	resources := map[string]string{
+		"/Patient/p1":      `{"resourceType":"Patient","id":"p1","gender":"female","name":[{"given":["Jane"],"family":"Doe"}]}`,
+		"/ImagingStudy/s2": `{"resourceType":"ImagingStudy","id":"s2","modality":[{"code":"MR"}]}`,
+	}
+	var requested []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = append(requested, r.URL.Path)
+		body, ok := resources[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/fhir+json")
+		_, _ = w.Write([]byte(body))
+	}))
+	defer srv.Close()
+
+	report := map[string]interface{}{
+		"resourceType": "DiagnosticReport",
+		"id":           "r1",
+		"subject":      map[string]interface{}{"reference": "Patient/p1"},
+		"imagingStudy": []interface{}{
+			map[string]interface{}{"reference": "ImagingStudy/s1"},
+			map[string]interface{}{"reference": "ImagingStudy/s2"},
+			map[string]interface{}{"reference": "ImagingStudy/gone"},
+		},
+	}
+	exported := map[string]interface{}{"resourceType": "ImagingStudy", "id": "s1", "modality": []interface{}{map[string]interface{}{"code": "CT"}}}
+
+	tests := []struct {
+		name          string
+		raw           *bulkReport
+		wantRequested []string
+	}{
+		{
+			name:          "full export",
+			raw:           &bulkReport{resource: report, patient: map[string]interface{}{"id": "p1", "gender": "female", "name": []interface{}{map[string]interface{}{"given": []interface{}{"Jane"}, "family": "Doe"}}}, studies: []map[string]interface{}{exported, {"id": "s2", "modality": []interface{}{map[string]interface{}{"code": "MR"}}}}},
+			wantRequested: []string{"/ImagingStudy/gone"},
+		},
+		{
+			name:          "incremental export without referenced resources",
+			raw:           &bulkReport{resource: report, studies: []map[string]interface{}{exported}},
+			wantRequested: []string{"/Patient/p1", "/ImagingStudy/s2", "/ImagingStudy/gone"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requested = nil
+			client := NewFHIRBulkClient("bulk", srv.URL)
+			doc, err := client.Fetch(context.Background(), DataSummary{ID: "r1", Source: "bulk", Type: "report", Raw: tt.raw})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if doc.PatientName != "Jane Doe" || doc.Gender != "female" {
+				t.Errorf("patient = %q %q, want Jane Doe female", doc.PatientName, doc.Gender)
+			}
+			if want := []string{"CT", "MR"}; !reflect.DeepEqual(doc.Modalities, want) {
+				t.Errorf("modalities = %v, want %v", doc.Modalities, want)
+			}
+			if !reflect.DeepEqual(requested, tt.wantRequested) {
+				t.Errorf("requested = %v, want %v", requested, tt.wantRequested)
+			}
+		})
+	}
+}
@@ -0,0 +1,185 @@
+package datasource
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yangszwei/koala/internal/usecase/importer"
+	"github.com/yangszwei/koala/internal/usecase/search"
+	"github.com/yangszwei/koala/pkg/elasticutil"
+)
+
+// fileClient implements the Streamer interface for CSV and NDJSON export files stored in a directory.
+type fileClient struct {
+	name     string
+	dir      string
+	mapping  map[string]string
+	imported map[string]time.Time // modification times of imported files
+	mu       sync.Mutex
+}
+
+func init() {
+	Register("file", func(name, url string, opts Options) (Client, error) {
+		if err := opts.Validate("mapping"); err != nil {
+			return nil, err
+		}
+		mapping, err := opts.StringMap("mapping")
+		if err != nil {
+			return nil, err
+		}
+		return NewFileClient(name, url, mapping)
+	})
+}
+
+// NewFileClient creates a new client that imports the CSV (.csv) and NDJSON (.ndjson, .jsonl)
+// files in a directory. The mapping maps document fields to columns or keys.
+func NewFileClient(name, dir string, mapping map[string]string) (Client, error) {
+	dir = strings.TrimPrefix(dir, "file://")
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("import directory: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("import directory: %s is not a directory", dir)
+	}
+
+	return &fileClient{
+		name:     name,
+		dir:      dir,
+		mapping:  mapping,
+		imported: make(map[string]time.Time),
+	}, nil
+}
+
+func (f *fileClient) Name() string {
+	return elasticutil.EscapeQueryString(f.name)
+}
+
+// Capabilities reports that only rows of new or modified files are emitted.
+func (f *fileClient) Capabilities() Capabilities {
+	return Capabilities{Incremental: true}
+}
+
+// Stream emits a summary for every valid row of the files that are new or were modified since
+// they were last imported. Invalid rows are logged and skipped.
+func (f *fileClient) Stream(ctx context.Context, _ int) (<-chan DataSummary, error) {
+	entries, err := os.ReadDir(f.dir)
+	if err != nil {
+		return nil, fmt.Errorf("import directory: %w", err)
+	}
+
+	out := make(chan DataSummary, 100)
+
+	go func() {
+		defer close(out)
+		for _, entry := range entries {
+			if entry.IsDir() {
+				continue
+			}
+			format, err := importer.ParseFormat(filepath.Ext(entry.Name()))
+			if err != nil {
+				continue
+			}
+			info, err := entry.Info()
+			if err != nil {
+				continue
+			}
+			path := filepath.Join(f.dir, entry.Name())
+
+			f.mu.Lock()
+			imported := f.imported[path].Equal(info.ModTime())
+			f.mu.Unlock()
+			if imported {
+				continue
+			}
+
+			if err := f.streamFile(ctx, path, format, out); err != nil {
+				log.Printf("[%s] Import of %s failed: %v", f.name, path, err)
+				continue
+			}
+
+			f.mu.Lock()
+			f.imported[path] = info.ModTime()
+			f.mu.Unlock()
+		}
+	}()
+
+	return out, nil
+}
+
+// streamFile decodes a single import file and emits its valid rows.
+func (f *fileClient) streamFile(ctx context.Context, path string, format importer.Format, out chan<- DataSummary) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	decoder, err := importer.NewDecoder(file, format, f.mapping)
+	if err != nil {
+		return err
+	}
+
+	for {
+		row, err := decoder.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		for _, e := range row.Errors {
+			log.Printf("[%s] Skipping row %d of %s: %s: %s", f.name, e.Row, path, e.Field, e.Error)
+		}
+		if len(row.Errors) > 0 {
+			continue
+		}
+
+		doc := row.Document
+		id := doc.ID
+		if id == "" {
+			id = importer.ContentID(doc)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case out <- DataSummary{ID: id, Source: f.Name(), Type: doc.Type, Raw: doc}:
+		}
+	}
+}
+
+func (f *fileClient) Fetch(_ context.Context, summary DataSummary) (*search.Document, error) {
+	doc, ok := summary.Raw.(search.Document)
+	if !ok {
+		return nil, fmt.Errorf("row %s was not read from an import file", summary.ID)
+	}
+	doc.ID = summary.DocID()
+	return &doc, nil
+}
+
+// EncodeRaw serializes the document read from an import file.
+func (f *fileClient) EncodeRaw(raw any) (json.RawMessage, error) {
+	doc, ok := raw.(search.Document)
+	if !ok {
+		return nil, fmt.Errorf("unexpected raw type %T", raw)
+	}
+	return json.Marshal(doc)
+}
+
+// DecodeRaw restores a document serialized by EncodeRaw.
+func (f *fileClient) DecodeRaw(data json.RawMessage) (any, error) {
+	var doc search.Document
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
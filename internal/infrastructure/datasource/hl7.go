@@ -0,0 +1,447 @@
+package datasource
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yangszwei/koala/internal/usecase/search"
+	"github.com/yangszwei/koala/pkg/elasticutil"
+	"github.com/yangszwei/koala/pkg/hl7"
+)
+
+// defaultHL7Mapping maps search.Document fields to the HL7 v2 ORU^R01 locations they are read from.
+// Entries in a datasource mapping override these defaults; an empty path disables a field.
+var defaultHL7Mapping = map[string]string{
+	"patientId":        "PID-3.1",
+	"patientName":      "PID-5",
+	"gender":           "PID-8",
+	"studyDate":        "OBR-7",
+	"modality":         "OBR-24",
+	"accessionNumbers": "OBR-3.1",
+	"codes":            "OBR-4.1",
+	"codeDisplay":      "OBR-4.2",
+	"status":           "OBR-25",
+	"performers":       "OBR-32.1",
+	"reportText":       "OBX-5",
+}
+
+// hl7ResultStatus maps OBR-25 result status codes to FHIR DiagnosticReport status values.
+var hl7ResultStatus = map[string]string{
+	"O": "registered",
+	"I": "registered",
+	"S": "registered",
+	"A": "partial",
+	"P": "preliminary",
+	"R": "preliminary",
+	"F": "final",
+	"C": "corrected",
+	"X": "cancelled",
+}
+
+// errReceiverShutdown is returned for messages that were received while the listener stops.
+var errReceiverShutdown = errors.New("receiver shutting down")
+
+// hl7Client implements the Streamer interface for HL7 v2 ORU^R01 result messages.
+// With an mllp:// URL it listens for messages and acknowledges them once they are indexed; with a
+// file:// URL it replays message logs stored in a directory.
+type hl7Client struct {
+	name    string
+	addr    string // listen address in MLLP mode
+	dir     string // message log directory in file-drop mode
+	mapping map[string]string
+	replay  map[string]time.Time // modification times of replayed files
+	mu      sync.Mutex
+}
+
+func init() {
+	Register("hl7", func(name, url string, opts Options) (Client, error) {
+		if err := opts.Validate("mapping"); err != nil {
+			return nil, err
+		}
+		mapping, err := opts.StringMap("mapping")
+		if err != nil {
+			return nil, err
+		}
+		return NewHL7Client(name, url, mapping)
+	})
+}
+
+// NewHL7Client creates a new HL7 v2 client. The URL selects the mode: "mllp://host:port" to listen
+// for messages, or "file:///path" to replay message logs. The mapping overrides defaultHL7Mapping.
+func NewHL7Client(name, rawURL string, mapping map[string]string) (Client, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid hl7 url: %w", err)
+	}
+
+	// Field names are matched case-insensitively, since configuration keys are lowercased
+	merged := make(map[string]string, len(defaultHL7Mapping))
+	for field, path := range defaultHL7Mapping {
+		merged[strings.ToLower(field)] = path
+	}
+	for field, path := range mapping {
+		if !isMappableField(field) {
+			return nil, fmt.Errorf("unsupported hl7 mapping field: %s", field)
+		}
+		merged[strings.ToLower(field)] = path
+	}
+
+	c := &hl7Client{name: name, mapping: merged, replay: make(map[string]time.Time)}
+	switch u.Scheme {
+	case "mllp", "tcp":
+		c.addr = u.Host
+	case "file":
+		c.dir = u.Path
+	default:
+		return nil, fmt.Errorf("unsupported hl7 url scheme: %s", u.Scheme)
+	}
+
+	return c, nil
+}
+
+func (h *hl7Client) Name() string {
+	return elasticutil.EscapeQueryString(h.name)
+}
+
+// Capabilities reports that every received message is new, so corrected results replace earlier ones.
+func (h *hl7Client) Capabilities() Capabilities {
+	return Capabilities{Incremental: true}
+}
+
+// Stream emits a summary for every accepted ORU^R01 message. In MLLP mode the channel stays open
+// until the context is cancelled; in file-drop mode it is closed once all new files were replayed.
+func (h *hl7Client) Stream(ctx context.Context, _ int) (<-chan DataSummary, error) {
+	if h.dir != "" {
+		return h.replayDir(ctx)
+	}
+	return h.listen(ctx)
+}
+
+func (h *hl7Client) Fetch(_ context.Context, summary DataSummary) (*search.Document, error) {
+	msg, ok := summary.Raw.(*hl7.Message)
+	if !ok {
+		return nil, fmt.Errorf("message %s was not received from this datasource", summary.ID)
+	}
+	return h.mapMessage(summary.DocID(), msg), nil
+}
+
+// EncodeRaw serializes the received message as its HL7 text.
+func (h *hl7Client) EncodeRaw(raw any) (json.RawMessage, error) {
+	msg, ok := raw.(*hl7.Message)
+	if !ok {
+		return nil, fmt.Errorf("unexpected raw type %T", raw)
+	}
+	return json.Marshal(map[string]string{"message": string(msg.Bytes())})
+}
+
+// DecodeRaw parses a message serialized by EncodeRaw.
+func (h *hl7Client) DecodeRaw(data json.RawMessage) (any, error) {
+	var stored struct {
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, err
+	}
+	return hl7.Parse([]byte(stored.Message))
+}
+
+// listen accepts MLLP connections and reads messages from them until the context is cancelled.
+func (h *hl7Client) listen(ctx context.Context) (<-chan DataSummary, error) {
+	ln, err := net.Listen("tcp", h.addr)
+	if err != nil {
+		return nil, fmt.Errorf("mllp listen: %w", err)
+	}
+	log.Printf("[%s] Listening for HL7 messages on %s", h.name, ln.Addr())
+
+	out := make(chan DataSummary, 100)
+	var wg sync.WaitGroup
+
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+
+	go func() {
+		defer close(out)
+		defer wg.Wait()
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Printf("[%s] Accept failed: %v", h.name, err)
+				}
+				return
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				h.serveConn(ctx, conn, out)
+			}()
+		}
+	}()
+
+	return out, nil
+}
+
+// serveConn reads framed messages from a connection and replies with an ACK for each of them. A
+// message is only accepted once its report is indexed, so the sender keeps and resends messages
+// that are lost on shutdown.
+func (h *hl7Client) serveConn(ctx context.Context, conn net.Conn, out chan<- DataSummary) {
+	defer conn.Close()
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	r := bufio.NewReader(conn)
+	for {
+		data, err := hl7.ReadFrame(r)
+		if err != nil {
+			if !errors.Is(err, io.EOF) && ctx.Err() == nil {
+				log.Printf("[%s] Read from %s failed: %v", h.name, conn.RemoteAddr(), err)
+			}
+			return
+		}
+
+		msg, err := hl7.Parse(data)
+		if err != nil {
+			log.Printf("[%s] Discarding unparsable message from %s: %v", h.name, conn.RemoteAddr(), err)
+			continue // no MSH to acknowledge against
+		}
+
+		code, text := hl7.AckAccept, ""
+		if summary, err := h.summarize(msg); err != nil {
+			code, text = hl7.AckReject, err.Error()
+		} else if err := h.deliver(ctx, summary, out); err != nil {
+			code, text = hl7.AckError, err.Error()
+		}
+
+		if err := hl7.WriteFrame(conn, hl7.ACK(msg, code, text)); err != nil {
+			log.Printf("[%s] Write ACK to %s failed: %v", h.name, conn.RemoteAddr(), err)
+			return
+		}
+	}
+}
+
+// deliver emits the summary of a received message and waits until its report is indexed. It
+// returns the error that kept the report from being indexed.
+func (h *hl7Client) deliver(ctx context.Context, summary DataSummary, out chan<- DataSummary) error {
+	done := make(chan error, 1)
+	summary.Done = func(err error) { done <- err }
+
+	select {
+	case <-ctx.Done():
+		return errReceiverShutdown
+	case out <- summary:
+	}
+
+	select {
+	case <-ctx.Done():
+		return errReceiverShutdown
+	case err := <-done:
+		return err
+	}
+}
+
+// replayDir reads message logs from the drop directory. Files are replayed again only when their
+// modification time changes.
+func (h *hl7Client) replayDir(ctx context.Context) (<-chan DataSummary, error) {
+	entries, err := os.ReadDir(h.dir)
+	if err != nil {
+		return nil, fmt.Errorf("hl7 drop directory: %w", err)
+	}
+
+	out := make(chan DataSummary, 100)
+
+	go func() {
+		defer close(out)
+		for _, entry := range entries {
+			if entry.IsDir() {
+				continue
+			}
+			info, err := entry.Info()
+			if err != nil {
+				continue
+			}
+			path := filepath.Join(h.dir, entry.Name())
+
+			h.mu.Lock()
+			replayed := h.replay[path].Equal(info.ModTime())
+			h.mu.Unlock()
+			if replayed {
+				continue
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				log.Printf("[%s] Failed to read %s: %v", h.name, path, err)
+				continue
+			}
+			for _, raw := range splitMessages(data) {
+				msg, err := hl7.Parse(raw)
+				if err != nil {
+					log.Printf("[%s] Skipping message in %s: %v", h.name, path, err)
+					continue
+				}
+				summary, err := h.summarize(msg)
+				if err != nil {
+					log.Printf("[%s] Skipping message %s in %s: %v", h.name, msg.ControlID(), path, err)
+					continue
+				}
+				select {
+				case <-ctx.Done():
+					return
+				case out <- summary:
+				}
+			}
+
+			h.mu.Lock()
+			h.replay[path] = info.ModTime()
+			h.mu.Unlock()
+		}
+	}()
+
+	return out, nil
+}
+
+// summarize validates that msg is an ORU^R01 result and builds its summary. The filler order
+// number identifies the report, falling back to the message control ID.
+func (h *hl7Client) summarize(msg *hl7.Message) (DataSummary, error) {
+	if msg.Type() != "ORU^R01" {
+		return DataSummary{}, fmt.Errorf("unsupported message type %s", msg.Type())
+	}
+
+	id := msg.Get("OBR-3.1")
+	if id == "" {
+		id = msg.ControlID()
+	}
+	if id == "" {
+		return DataSummary{}, fmt.Errorf("message has neither a filler order number nor a control ID")
+	}
+
+	return DataSummary{ID: id, Source: h.Name(), Type: "report", Raw: msg}, nil
+}
+
+// mapMessage converts an ORU^R01 message to a search.Document using the configured field mapping.
+func (h *hl7Client) mapMessage(id string, msg *hl7.Message) *search.Document {
+	doc := &search.Document{
+		ID:         id,
+		Type:       "report",
+		Categories: []string{},
+	}
+
+	first := func(field string) string {
+		if path := h.mapping[strings.ToLower(field)]; path != "" {
+			return msg.Get(path)
+		}
+		return ""
+	}
+	all := func(field string) []string {
+		if path := h.mapping[strings.ToLower(field)]; path != "" {
+			return msg.GetAll(path)
+		}
+		return nil
+	}
+
+	doc.PatientID = first("patientId")
+	doc.PatientName = formatHL7Name(first("patientName"), msg.ComponentSeparator())
+	if gender := first("gender"); gender != "" {
+		doc.Gender = mapDICOMGender(gender)
+	}
+	if ts := first("studyDate"); len(ts) >= 8 {
+		doc.StudyDate = fmt.Sprintf("%s-%s-%s", ts[:4], ts[4:6], ts[6:8])
+	}
+	doc.Modality = first("modality")
+	doc.CodeDisplay = first("codeDisplay")
+	if status := first("status"); status != "" {
+		if mapped, ok := hl7ResultStatus[status]; ok {
+			status = mapped
+		}
+		doc.Status = status
+	}
+	doc.ReportText = strings.Join(all("reportText"), "\n")
+	doc.Impression = strings.Join(all("impression"), "\n")
+
+	for _, v := range all("accessionNumbers") {
+		doc.AccessionNumbers = appendUnique(doc.AccessionNumbers, v)
+	}
+	for _, v := range all("codes") {
+		doc.Codes = appendUnique(doc.Codes, v)
+	}
+	for _, v := range all("performers") {
+		// Interpreters are "ID&family&given" subcomponents of OBR-32
+		parts := strings.SplitN(v, string(msg.SubcomponentSeparator()), 2)
+		if len(parts) == 2 {
+			v = formatHL7Name(parts[1], msg.SubcomponentSeparator())
+		}
+		if v != "" {
+			doc.Performers = appendUnique(doc.Performers, v)
+		}
+	}
+	for _, v := range all("categories") {
+		doc.Categories = appendUnique(doc.Categories, v)
+	}
+
+	return doc
+}
+
+// formatHL7Name formats an HL7 person name "family^given^middle" as "given middle family".
+func formatHL7Name(value string, sep byte) string {
+	parts := strings.Split(value, string(sep))
+	if len(parts) > 1 {
+		parts = append(parts[1:], parts[0])
+	}
+	return strings.Join(strings.Fields(strings.Join(parts, " ")), " ")
+}
+
+// isMappableField reports whether a document field can be populated through an HL7 mapping.
+func isMappableField(field string) bool {
+	switch strings.ToLower(field) {
+	case "patientid", "patientname", "gender", "studydate", "modality", "accessionnumbers", "codes",
+		"codedisplay", "status", "performers", "reporttext", "impression", "categories":
+		return true
+	}
+	return false
+}
+
+// splitMessages splits a message log into individual messages. Both MLLP-framed logs and plain
+// logs, where every message starts with an MSH segment, are supported.
+func splitMessages(data []byte) [][]byte {
+	if bytes.IndexByte(data, 0x0B) >= 0 {
+		var messages [][]byte
+		r := bufio.NewReader(bytes.NewReader(data))
+		for {
+			msg, err := hl7.ReadFrame(r)
+			if err != nil {
+				return messages
+			}
+			messages = append(messages, msg)
+		}
+	}
+
+	var messages [][]byte
+	data = bytes.ReplaceAll(data, []byte("\r\n"), []byte("\r"))
+	data = bytes.ReplaceAll(data, []byte("\n"), []byte("\r"))
+	for i, part := range bytes.Split(data, []byte("\rMSH")) {
+		if i > 0 {
+			part = append([]byte("MSH"), part...)
+		}
+		if len(bytes.TrimSpace(part)) > 0 {
+			messages = append(messages, part)
+		}
+	}
+	return messages
+}
@@ -0,0 +1,92 @@
+package datasource
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/yangszwei/koala/pkg/hl7"
+)
+
+func TestHL7AcknowledgesIndexedMessages(t *testing.T) {
+	const message = "MSH|^~\\&|RIS|HOSP|KOALA|HOSP|20240101120000||ORU^R01|M1|P|2.5\rOBR|1||R1\r"
+
+	tests := []struct {
+		name     string
+		outcome  error // Outcome the summary is finished with, unless the listener stops first
+		shutdown bool
+		want     string
+	}{
+		{name: "indexed", want: hl7.AckAccept},
+		{name: "not indexed", outcome: errors.New("index failed"), want: hl7.AckError},
+		{name: "listener stopped before indexing", shutdown: true, want: hl7.AckError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			addr := ln.Addr().String()
+			ln.Close()
+
+			client, err := NewHL7Client("hl7", "mllp://"+addr, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			out, err := client.(Streamer).Stream(ctx, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			conn, err := net.Dial("tcp", addr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			if err := hl7.WriteFrame(conn, []byte(message)); err != nil {
+				t.Fatal(err)
+			}
+
+			acks := make(chan *hl7.Message, 1)
+			go func() {
+				defer close(acks)
+				data, err := hl7.ReadFrame(bufio.NewReader(conn))
+				if err != nil {
+					return
+				}
+				if ack, err := hl7.Parse(data); err == nil {
+					acks <- ack
+				}
+			}()
+
+			summary := <-out
+			select {
+			case <-acks:
+				t.Fatal("message acknowledged before it was indexed")
+			case <-time.After(50 * time.Millisecond):
+			}
+
+			if tt.shutdown {
+				cancel()
+			} else {
+				summary.Finish(tt.outcome)
+			}
+
+			got := ""
+			if ack, ok := <-acks; ok {
+				got = ack.Get("MSA-1")
+			}
+			// The listener may close the connection before it acknowledges the error
+			if got != tt.want && !(tt.shutdown && got == "") {
+				t.Errorf("acknowledgement code = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
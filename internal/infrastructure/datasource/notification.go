@@ -0,0 +1,217 @@
+package datasource
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Notification lists the items a data source reported as changed in a push notification.
+// Summaries carry no Source; it is set when the items are enqueued for a data source.
+type Notification struct {
+	Updated []DataSummary // Items to fetch and index; Raw is nil if only the ID was sent
+	Deleted []DataSummary // Items whose documents should be removed
+}
+
+// ParseFHIRNotification parses a FHIR rest-hook notification for DiagnosticReport resources.
+//
+// It accepts R4B and R5 notification bundles (type "history" or "subscription-notification"),
+// whose status entry is a SubscriptionStatus or, with the R4 backport, a Parameters resource.
+// Reports included in the bundle are indexed as sent, reports only referenced as notification
+// focus are fetched by ID, and entries with a DELETE request are removed. A bare DiagnosticReport
+// resource, as posted by R4 rest-hook subscriptions, is accepted as well. Heartbeat and handshake
+// notifications yield an empty result.
+func ParseFHIRNotification(body []byte) (Notification, error) {
+	var n Notification
+	if len(strings.TrimSpace(string(body))) == 0 {
+		return n, nil // R4 empty payload ping
+	}
+
+	var res map[string]interface{}
+	if err := json.Unmarshal(body, &res); err != nil {
+		return n, fmt.Errorf("decode notification: %w", err)
+	}
+
+	switch res["resourceType"] {
+	case "DiagnosticReport":
+		id, _ := res["id"].(string)
+		if id == "" {
+			return n, fmt.Errorf("diagnostic report without id")
+		}
+		n.Updated = append(n.Updated, DataSummary{ID: id, Type: "report", Raw: res})
+		return n, nil
+	case "Bundle":
+	default:
+		return n, fmt.Errorf("unsupported resource type %v", res["resourceType"])
+	}
+
+	entries, _ := res["entry"].([]interface{})
+	var focus []string
+	included := make(map[string]bool)
+
+	for _, e := range entries {
+		entry, ok := e.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		resource, _ := entry["resource"].(map[string]interface{})
+
+		switch resource["resourceType"] {
+		case "SubscriptionStatus":
+			if typ, _ := resource["type"].(string); typ == "heartbeat" || typ == "handshake" {
+				return Notification{}, nil
+			}
+			focus = append(focus, statusFocus(resource)...)
+			continue
+		case "Parameters":
+			if typ := parametersType(resource); typ == "heartbeat" || typ == "handshake" {
+				return Notification{}, nil
+			}
+			focus = append(focus, parametersFocus(resource)...)
+			continue
+		}
+
+		request, _ := entry["request"].(map[string]interface{})
+		if method, _ := request["method"].(string); strings.EqualFold(method, "DELETE") {
+			ref, _ := request["url"].(string)
+			if ref == "" {
+				ref, _ = entry["fullUrl"].(string)
+			}
+			if id := reportID(ref); id != "" {
+				n.Deleted = append(n.Deleted, DataSummary{ID: id, Type: "report"})
+				included[id] = true
+			}
+			continue
+		}
+
+		if resource["resourceType"] != "DiagnosticReport" {
+			continue
+		}
+		if id, _ := resource["id"].(string); id != "" {
+			n.Updated = append(n.Updated, DataSummary{ID: id, Type: "report", Raw: resource})
+			included[id] = true
+		}
+	}
+
+	// Notifications with an "id-only" payload reference the reports without including them.
+	for _, ref := range focus {
+		if id := reportID(ref); id != "" && !included[id] {
+			n.Updated = append(n.Updated, DataSummary{ID: id, Type: "report"})
+			included[id] = true
+		}
+	}
+
+	return n, nil
+}
+
+// statusFocus returns the focus references of the notification events of a SubscriptionStatus.
+func statusFocus(status map[string]interface{}) []string {
+	var refs []string
+	events, _ := status["notificationEvent"].([]interface{})
+	for _, e := range events {
+		event, _ := e.(map[string]interface{})
+		if focus, ok := event["focus"].(map[string]interface{}); ok {
+			if ref, _ := focus["reference"].(string); ref != "" {
+				refs = append(refs, ref)
+			}
+		}
+	}
+	return refs
+}
+
+// parametersType returns the notification type of an R4 backport subscription status.
+func parametersType(params map[string]interface{}) string {
+	parameters, _ := params["parameter"].([]interface{})
+	for _, p := range parameters {
+		param, _ := p.(map[string]interface{})
+		if param["name"] == "type" {
+			typ, _ := param["valueCode"].(string)
+			return typ
+		}
+	}
+	return ""
+}
+
+// parametersFocus returns the focus references of the notification events of an R4 backport
+// subscription status.
+func parametersFocus(params map[string]interface{}) []string {
+	var refs []string
+	parameters, _ := params["parameter"].([]interface{})
+	for _, p := range parameters {
+		param, _ := p.(map[string]interface{})
+		if param["name"] != "notification-event" {
+			continue
+		}
+		parts, _ := param["part"].([]interface{})
+		for _, pt := range parts {
+			part, _ := pt.(map[string]interface{})
+			if part["name"] != "focus" {
+				continue
+			}
+			if value, ok := part["valueReference"].(map[string]interface{}); ok {
+				if ref, _ := value["reference"].(string); ref != "" {
+					refs = append(refs, ref)
+				}
+			}
+		}
+	}
+	return refs
+}
+
+// reportID returns the ID of a relative or absolute DiagnosticReport reference, ignoring any
+// version suffix. It returns "" for references to other resource types.
+func reportID(ref string) string {
+	ref, _, _ = strings.Cut(ref, "?")
+	ref, _, _ = strings.Cut(ref, "/_history/")
+	parts := strings.Split(strings.TrimSuffix(ref, "/"), "/")
+	if len(parts) < 2 || parts[len(parts)-2] != "DiagnosticReport" {
+		return ""
+	}
+	return parts[len(parts)-1]
+}
+
+// ParseOrthancChange parses an Orthanc change callback, as posted by an OnStableStudy or OnChange
+// script. Stable studies are indexed and deleted studies removed; other changes are ignored.
+//
+// The StudyInstanceUID is read from a top-level "StudyInstanceUID" field or from the
+// "MainDicomTags" or "Tags" objects, since Orthanc's own resource IDs cannot be used with DICOMweb.
+func ParseOrthancChange(body []byte) (Notification, error) {
+	var n Notification
+
+	var change struct {
+		ChangeType       string            `json:"ChangeType"`
+		ResourceType     string            `json:"ResourceType"`
+		StudyInstanceUID string            `json:"StudyInstanceUID"`
+		MainDicomTags    map[string]string `json:"MainDicomTags"`
+		Tags             map[string]string `json:"Tags"`
+	}
+	if err := json.Unmarshal(body, &change); err != nil {
+		return n, fmt.Errorf("decode change: %w", err)
+	}
+	if change.ResourceType != "" && !strings.EqualFold(change.ResourceType, "Study") {
+		return n, nil
+	}
+
+	uid := change.StudyInstanceUID
+	if uid == "" {
+		uid = change.MainDicomTags["StudyInstanceUID"]
+	}
+	if uid == "" {
+		uid = change.Tags["StudyInstanceUID"]
+	}
+
+	switch change.ChangeType {
+	case "StableStudy", "":
+		if uid == "" {
+			return n, fmt.Errorf("change without StudyInstanceUID")
+		}
+		n.Updated = append(n.Updated, DataSummary{ID: uid, Type: "study"})
+	case "Deleted":
+		if uid == "" {
+			return n, fmt.Errorf("change without StudyInstanceUID")
+		}
+		n.Deleted = append(n.Deleted, DataSummary{ID: uid, Type: "study"})
+	}
+
+	return n, nil
+}
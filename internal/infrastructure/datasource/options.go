@@ -0,0 +1,133 @@
+package datasource
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Options holds the type-specific options of a datasource configuration. Keys are matched
+// case-insensitively, since configuration keys are lowercased when loaded.
+type Options map[string]interface{}
+
+// lookup returns the value stored under key, ignoring case.
+func (o Options) lookup(key string) (interface{}, bool) {
+	if v, ok := o[key]; ok {
+		return v, true
+	}
+	for k, v := range o {
+		if strings.EqualFold(k, key) {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// Validate returns an error if the options contain keys other than the allowed ones.
+func (o Options) Validate(allowed ...string) error {
+	for k := range o {
+		known := false
+		for _, a := range allowed {
+			if strings.EqualFold(k, a) {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return fmt.Errorf("unknown option %q", k)
+		}
+	}
+	return nil
+}
+
+// String returns the string option under key, or "" if it is not set.
+func (o Options) String(key string) (string, error) {
+	v, ok := o.lookup(key)
+	if !ok || v == nil {
+		return "", nil
+	}
+	s, ok := v.(string)
+	if !ok {
+		return "", fmt.Errorf("option %q must be a string", key)
+	}
+	return s, nil
+}
+
+// Bool returns the boolean option under key, or false if it is not set.
+func (o Options) Bool(key string) (bool, error) {
+	v, ok := o.lookup(key)
+	if !ok || v == nil {
+		return false, nil
+	}
+	switch b := v.(type) {
+	case bool:
+		return b, nil
+	case string:
+		parsed, err := strconv.ParseBool(b)
+		if err != nil {
+			return false, fmt.Errorf("option %q must be a boolean", key)
+		}
+		return parsed, nil
+	default:
+		return false, fmt.Errorf("option %q must be a boolean", key)
+	}
+}
+
+// Int returns the integer option under key, or 0 if it is not set.
+func (o Options) Int(key string) (int, error) {
+	v, ok := o.lookup(key)
+	if !ok || v == nil {
+		return 0, nil
+	}
+	switch n := v.(type) {
+	case int:
+		return n, nil
+	case int64:
+		return int(n), nil
+	case float64:
+		return int(n), nil
+	case string:
+		parsed, err := strconv.Atoi(n)
+		if err != nil {
+			return 0, fmt.Errorf("option %q must be an integer", key)
+		}
+		return parsed, nil
+	default:
+		return 0, fmt.Errorf("option %q must be an integer", key)
+	}
+}
+
+// Duration returns the duration option under key (e.g., "30s"), or 0 if it is not set.
+func (o Options) Duration(key string) (time.Duration, error) {
+	s, err := o.String(key)
+	if err != nil || s == "" {
+		return 0, err
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("option %q must be a duration: %w", key, err)
+	}
+	return d, nil
+}
+
+// StringMap returns the string map option under key, or nil if it is not set.
+func (o Options) StringMap(key string) (map[string]string, error) {
+	v, ok := o.lookup(key)
+	if !ok || v == nil {
+		return nil, nil
+	}
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("option %q must be a map", key)
+	}
+	out := make(map[string]string, len(m))
+	for k, val := range m {
+		s, ok := val.(string)
+		if !ok {
+			return nil, fmt.Errorf("option %q: value of %q must be a string", key, k)
+		}
+		out[k] = s
+	}
+	return out, nil
+}
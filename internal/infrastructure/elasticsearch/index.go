@@ -13,6 +13,7 @@ var indexFS embed.FS
 
 // EnsureIndices loads embedded index definition files and ensures each index exists in Elasticsearch.
 // If an index does not exist, it will be created based on its corresponding JSON definition.
+// Existing indices have their mappings updated so that newly added fields become available.
 func (c *Client) EnsureIndices() error {
 	entries, err := indexFS.ReadDir("indices")
 	if err != nil {
@@ -25,15 +26,6 @@ func (c *Client) EnsureIndices() error {
 		}
 		name := strings.TrimSuffix(entry.Name(), ".json")
 
-		// Check if index already exists
-		exists, err := c.Client.Indices.Exists([]string{name})
-		if err != nil {
-			return fmt.Errorf("checking if index %q exists: %w", name, err)
-		}
-		if exists.StatusCode == 200 {
-			continue
-		}
-
 		defBytes, err := indexFS.ReadFile("indices/" + entry.Name())
 		if err != nil {
 			return fmt.Errorf("failed to read index definition %q: %w", entry.Name(), err)
@@ -44,6 +36,21 @@ func (c *Client) EnsureIndices() error {
 			return fmt.Errorf("invalid JSON in %q: %w", entry.Name(), err)
 		}
 
+		// Check if index already exists
+		exists, err := c.Client.Indices.Exists([]string{name})
+		if err != nil {
+			return fmt.Errorf("checking if index %q exists: %w", name, err)
+		}
+		if exists.StatusCode == 200 {
+			// Add any fields introduced since the index was created
+			if mappings, ok := def["mappings"].(map[string]interface{}); ok {
+				if err := c.PutMapping(name, mappings); err != nil {
+					return fmt.Errorf("updating mapping of index %q: %w", name, err)
+				}
+			}
+			continue
+		}
+
 		if err := c.CreateIndex(name, def); err != nil {
 			return fmt.Errorf("creating index %q: %w", name, err)
 		}
@@ -68,3 +75,20 @@ func (c *Client) CreateIndex(name string, body map[string]interface{}) error {
 	}
 	return nil
 }
+
+// PutMapping adds the given mappings to an existing index. Existing fields cannot be changed.
+func (c *Client) PutMapping(name string, mappings map[string]interface{}) error {
+	data, err := json.Marshal(mappings)
+	if err != nil {
+		return err
+	}
+	res, err := c.Client.Indices.PutMapping([]string{name}, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return fmt.Errorf("error updating mapping of %s: %s", name, res.String())
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yangszwei/koala/internal/interface/worker"
+	"github.com/yangszwei/koala/internal/usecase/checkpoint"
+)
+
+// CheckpointManager exposes the scan progress of the data sources.
+type CheckpointManager interface {
+	// Checkpoints returns the saved scan progress of every data source.
+	Checkpoints(ctx context.Context) ([]checkpoint.Checkpoint, error)
+	// ResetCheckpoint discards the scan progress of a data source so that it is scanned from the beginning.
+	ResetCheckpoint(ctx context.Context, name string) error
+}
+
+// CheckpointHandler handles HTTP requests related to scan checkpoints.
+type CheckpointHandler struct {
+	mgr CheckpointManager
+}
+
+// RegisterCheckpointHandler creates a new handler and registers routes.
+func RegisterCheckpointHandler(r gin.IRouter, mgr CheckpointManager) {
+	h := &CheckpointHandler{mgr: mgr}
+
+	management := r.Group("/manage/checkpoints")
+	{
+		management.GET("", h.List)
+		management.DELETE("/:source", h.Reset)
+	}
+}
+
+// List handles GET /manage/checkpoints
+func (h *CheckpointHandler) List(c *gin.Context) {
+	checkpoints, err := h.mgr.Checkpoints(c.Request.Context())
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load checkpoints"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"results": checkpoints})
+}
+
+// Reset handles DELETE /manage/checkpoints/:source
+func (h *CheckpointHandler) Reset(c *gin.Context) {
+	err := h.mgr.ResetCheckpoint(c.Request.Context(), c.Param("source"))
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	case errors.Is(err, worker.ErrUnknownSource):
+		c.JSON(http.StatusNotFound, gin.H{"error": "unknown data source"})
+	case errors.Is(err, worker.ErrScanRunning):
+		c.JSON(http.StatusConflict, gin.H{"error": "scan is running, try again once it has finished"})
+	default:
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to reset checkpoint"})
+	}
+}
@@ -1,9 +1,12 @@
 package http
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/yangszwei/koala/internal/domain"
 	"github.com/yangszwei/koala/internal/usecase/completion"
 )
 
@@ -22,13 +25,86 @@ func RegisterCompletionHandler(r gin.IRouter, svc completion.Service) {
 	// Management routes
 	management := r.Group("/manage/completion-terms")
 	{
+		management.GET("", h.List)
+		management.GET("/export", h.Export)
+		management.GET("/:id", h.Get)
 		management.POST("", h.Add)
+		management.PUT("/:id", h.Update)
 		management.DELETE("/:id", h.Remove)
 	}
 }
 
+// List handles GET /manage/completion-terms?q=&category=&source=&offset=0&limit=50
+func (h *CompletionHandler) List(c *gin.Context) {
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid offset"})
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 || limit > 1000 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+		return
+	}
+
+	terms, total, err := h.svc.List(c.Request.Context(), completion.Filter{
+		Query:    c.Query("q"),
+		Category: c.Query("category"),
+		Source:   c.Query("source"),
+		Offset:   offset,
+		Limit:    limit,
+	})
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list terms"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"results": terms, "total": total})
+}
+
+// Export handles GET /manage/completion-terms/export
+func (h *CompletionHandler) Export(c *gin.Context) {
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", `attachment; filename="completion-terms.csv"`)
+	c.Status(http.StatusOK)
+
+	// The status has been sent by the time an error occurs, so it can only be logged.
+	if err := h.svc.Export(c.Request.Context(), c.Writer); err != nil {
+		c.Error(err)
+	}
+}
+
+// Get handles GET /manage/completion-terms/:id
+func (h *CompletionHandler) Get(c *gin.Context) {
+	term, err := h.svc.Get(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, completion.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "term not found"})
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get term"})
+		return
+	}
+
+	c.JSON(http.StatusOK, term)
+}
+
 // Add handles POST /manage/completion-terms
+//
+// A multipart request uploads a CSV file of terms; a JSON body adds a single term.
 func (h *CompletionHandler) Add(c *gin.Context) {
+	if c.ContentType() == "application/json" {
+		var term domain.CompletionTerm
+		if err := c.ShouldBindJSON(&term); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid term"})
+			return
+		}
+		h.save(c, term, http.StatusCreated)
+		return
+	}
+
 	file, err := c.FormFile("file")
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
@@ -43,6 +119,7 @@ func (h *CompletionHandler) Add(c *gin.Context) {
 	defer f.Close()
 
 	if err := h.svc.Upload(c.Request.Context(), f); err != nil {
+		c.Error(err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to upload terms"})
 		return
 	}
@@ -50,6 +127,36 @@ func (h *CompletionHandler) Add(c *gin.Context) {
 	c.JSON(http.StatusCreated, gin.H{"status": "ok"})
 }
 
+// Update handles PUT /manage/completion-terms/:id
+func (h *CompletionHandler) Update(c *gin.Context) {
+	var term domain.CompletionTerm
+	if err := c.ShouldBindJSON(&term); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid term"})
+		return
+	}
+	term.ID = c.Param("id")
+
+	h.save(c, term, http.StatusOK)
+}
+
+// save stores a single term and responds with it.
+func (h *CompletionHandler) save(c *gin.Context, term domain.CompletionTerm, status int) {
+	term.Stats = nil // Stats are computed and cannot be edited
+	term.Normalize()
+	if term.Term == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "term is required"})
+		return
+	}
+
+	if err := h.svc.Save(c.Request.Context(), term); err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save term"})
+		return
+	}
+
+	c.JSON(status, term)
+}
+
 // Remove handles DELETE /manage/completion-terms/:id
 func (h *CompletionHandler) Remove(c *gin.Context) {
 	id := c.Param("id")
@@ -66,7 +173,7 @@ func (h *CompletionHandler) Remove(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"status": "ok"})
 }
 
-// Suggest handles GET /terms/suggest?q=
+// Suggest handles GET /terms/suggest?q=&modality=&category=
 func (h *CompletionHandler) Suggest(c *gin.Context) {
 	q := c.Query("q")
 	if q == "" {
@@ -74,7 +181,8 @@ func (h *CompletionHandler) Suggest(c *gin.Context) {
 		return
 	}
 
-	suggestions, err := h.svc.Suggest(c.Request.Context(), q, 10)
+	scope := completion.Scope{Modality: c.Query("modality"), Category: c.Query("category")}
+	suggestions, err := h.svc.Suggest(c.Request.Context(), q, scope, 10)
 	if err != nil {
 		c.Error(err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "suggestion failed"})
@@ -0,0 +1,90 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yangszwei/koala/internal/infrastructure/datasource"
+	"github.com/yangszwei/koala/internal/usecase/search"
+	"github.com/yangszwei/koala/internal/usecase/thumbnail"
+)
+
+// SourceDirectory looks up the configured data sources, e.g., the ones indexed documents were
+// fetched from.
+type SourceDirectory interface {
+	// Source returns where a document was fetched from, or false if its data source is unknown.
+	Source(doc search.Document) (*datasource.Source, bool)
+	// Type returns the type of the named data source, or false if it is not configured.
+	Type(name string) (string, bool)
+}
+
+// DocumentHandler handles HTTP requests related to single indexed documents.
+type DocumentHandler struct {
+	svc        search.Service
+	sources    SourceDirectory
+	thumbnails thumbnail.Service
+}
+
+// RegisterDocumentHandler creates a new handler and registers routes.
+func RegisterDocumentHandler(r gin.IRouter, svc search.Service, sources SourceDirectory, thumbnails thumbnail.Service) {
+	h := &DocumentHandler{svc: svc, sources: sources, thumbnails: thumbnails}
+
+	r.GET("/documents/:id", h.Get)
+	r.GET("/documents/:id/thumbnail", h.Thumbnail)
+}
+
+// Get handles GET /documents/:id
+//
+// The document is returned with its source and links back to the originating system, unless it
+// was imported or indexed through the API, or its data source is no longer configured.
+func (h *DocumentHandler) Get(c *gin.Context) {
+	doc, err := h.svc.Get(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, search.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "document not found"})
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get document"})
+		return
+	}
+
+	resp := gin.H{"document": doc}
+	if src, ok := h.sources.Source(*doc); ok {
+		resp["source"] = src
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// Thumbnail handles GET /documents/:id/thumbnail?size=128
+//
+// The image fits in size × size pixels; sizes are rounded down to the few sizes thumbnails are
+// cached in. Thumbnails are only available for imaging documents whose
+// study is stored in a DICOMweb data source.
+func (h *DocumentHandler) Thumbnail(c *gin.Context) {
+	size, err := strconv.Atoi(c.DefaultQuery("size", "128"))
+	if err != nil || size < thumbnail.MinSize || size > thumbnail.MaxSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid size"})
+		return
+	}
+
+	data, err := h.thumbnails.Get(c.Request.Context(), c.Param("id"), size)
+	if errors.Is(err, thumbnail.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "document not found"})
+		return
+	}
+	if errors.Is(err, thumbnail.ErrNoImage) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no thumbnail available"})
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to render thumbnail"})
+		return
+	}
+
+	c.Header("Cache-Control", "private, max-age=86400")
+	c.Data(http.StatusOK, http.DetectContentType(data), data)
+}
@@ -0,0 +1,103 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yangszwei/koala/internal/domain"
+	"github.com/yangszwei/koala/internal/usecase/harvest"
+)
+
+// HarvestHandler handles HTTP requests related to the review of harvested candidate terms.
+type HarvestHandler struct {
+	svc harvest.Service
+}
+
+// RegisterHarvestHandler creates a new handler and registers routes.
+func RegisterHarvestHandler(r gin.IRouter, svc harvest.Service) {
+	h := &HarvestHandler{svc: svc}
+
+	management := r.Group("/manage/term-candidates")
+	{
+		management.GET("", h.List)
+		management.POST("/:id/approve", h.Approve)
+		management.POST("/:id/reject", h.Reject)
+	}
+}
+
+// List handles GET /manage/term-candidates?state=pending&offset=0&limit=50
+func (h *HarvestHandler) List(c *gin.Context) {
+	state := harvest.State(c.DefaultQuery("state", string(harvest.StatePending)))
+	switch state {
+	case "all":
+		state = ""
+	case harvest.StatePending, harvest.StateApproved, harvest.StateAdded, harvest.StateRejected:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "state must be pending, approved, added, rejected or all"})
+		return
+	}
+
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid offset"})
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 || limit > 1000 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+		return
+	}
+
+	candidates, total, err := h.svc.List(c.Request.Context(), state, offset, limit)
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list candidates"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"results": candidates, "total": total})
+}
+
+// Approve handles POST /manage/term-candidates/:id/approve
+//
+// The optional JSON body is a completion term whose fields override those derived from the
+// candidate, e.g., to add a label or category.
+func (h *HarvestHandler) Approve(c *gin.Context) {
+	var term domain.CompletionTerm
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&term); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid term"})
+			return
+		}
+	}
+
+	term, err := h.svc.Approve(c.Request.Context(), c.Param("id"), term)
+	if err != nil {
+		h.error(c, err, "failed to approve candidate")
+		return
+	}
+
+	c.JSON(http.StatusCreated, term)
+}
+
+// Reject handles POST /manage/term-candidates/:id/reject
+func (h *HarvestHandler) Reject(c *gin.Context) {
+	if err := h.svc.Reject(c.Request.Context(), c.Param("id")); err != nil {
+		h.error(c, err, "failed to reject candidate")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// error responds with 404 for missing candidates and 500 otherwise.
+func (h *HarvestHandler) error(c *gin.Context, err error, message string) {
+	if errors.Is(err, harvest.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "candidate not found"})
+		return
+	}
+	c.Error(err)
+	c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+}
@@ -0,0 +1,65 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"path/filepath"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yangszwei/koala/internal/usecase/importer"
+)
+
+// ImportHandler handles HTTP requests related to bulk document imports.
+type ImportHandler struct {
+	svc importer.Service
+}
+
+// RegisterImportHandler creates a new handler and registers routes.
+func RegisterImportHandler(r gin.IRouter, svc importer.Service) {
+	h := &ImportHandler{svc: svc}
+
+	r.POST("/manage/import", h.Import)
+}
+
+// Import handles POST /manage/import
+//
+// The request is a multipart form with a "file" in CSV or NDJSON format. The format is taken
+// from the "format" field or the file extension, and "mapping[field]=column" fields map
+// document fields to columns.
+func (h *ImportHandler) Import(c *gin.Context) {
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+		return
+	}
+
+	name := c.PostForm("format")
+	if name == "" {
+		name = filepath.Ext(file.Filename)
+	}
+	format, err := importer.ParseFormat(name)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to open file"})
+		return
+	}
+	defer f.Close()
+
+	report, err := h.svc.Import(c.Request.Context(), f, format, c.PostFormMap("mapping"))
+	if err != nil {
+		c.Error(err)
+		if errors.Is(err, importer.ErrInvalidInput) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to import file"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,88 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yangszwei/koala/internal/usecase/jobqueue"
+)
+
+// JobHandler handles HTTP requests related to failed indexing jobs.
+type JobHandler struct {
+	svc jobqueue.Service
+}
+
+// RegisterJobHandler creates a new handler and registers routes.
+func RegisterJobHandler(r gin.IRouter, svc jobqueue.Service) {
+	h := &JobHandler{svc: svc}
+
+	management := r.Group("/manage/jobs")
+	{
+		management.GET("", h.List)
+		management.POST("/:id/retry", h.Retry)
+		management.DELETE("/:id", h.Discard)
+	}
+}
+
+// List handles GET /manage/jobs?state=dead&offset=0&limit=50
+func (h *JobHandler) List(c *gin.Context) {
+	state := jobqueue.State(c.DefaultQuery("state", string(jobqueue.StateDead)))
+	if state == "all" {
+		state = ""
+	} else if state != jobqueue.StatePending && state != jobqueue.StateDead {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "state must be pending, dead or all"})
+		return
+	}
+
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid offset"})
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 || limit > 1000 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+		return
+	}
+
+	jobs, total, err := h.svc.List(c.Request.Context(), state, offset, limit)
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list jobs"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"results": jobs, "total": total})
+}
+
+// Retry handles POST /manage/jobs/:id/retry
+func (h *JobHandler) Retry(c *gin.Context) {
+	if err := h.svc.Retry(c.Request.Context(), c.Param("id")); err != nil {
+		h.error(c, err, "failed to retry job")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// Discard handles DELETE /manage/jobs/:id
+func (h *JobHandler) Discard(c *gin.Context) {
+	if err := h.svc.Discard(c.Request.Context(), c.Param("id")); err != nil {
+		h.error(c, err, "failed to discard job")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// error responds with 404 for missing jobs and 500 otherwise.
+func (h *JobHandler) error(c *gin.Context, err error, message string) {
+	if errors.Is(err, jobqueue.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "job not found"})
+		return
+	}
+	c.Error(err)
+	c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+}
@@ -0,0 +1,64 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yangszwei/koala/internal/usecase/patient"
+)
+
+// PatientHandler handles HTTP requests related to patients.
+type PatientHandler struct {
+	svc patient.Service
+}
+
+// RegisterPatientHandler creates a new handler and registers routes.
+func RegisterPatientHandler(r gin.IRouter, svc patient.Service) {
+	h := &PatientHandler{svc: svc}
+
+	r.GET("/patients", h.List)
+	r.GET("/patients/:id", h.Get)
+}
+
+// List handles GET /patients?q=&offset=0&limit=50
+//
+// The query matches patient IDs and names; without a query, all patients are listed.
+func (h *PatientHandler) List(c *gin.Context) {
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 || offset > 10000 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid offset"})
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 || limit > 1000 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+		return
+	}
+
+	patients, total, err := h.svc.List(c.Request.Context(), c.Query("q"), offset, limit)
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list patients"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"results": patients, "total": total})
+}
+
+// Get handles GET /patients/:id
+func (h *PatientHandler) Get(c *gin.Context) {
+	timeline, err := h.svc.Get(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, patient.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "patient not found"})
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get patient"})
+		return
+	}
+
+	c.JSON(http.StatusOK, timeline)
+}
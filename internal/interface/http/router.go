@@ -5,14 +5,30 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/yangszwei/koala/internal/usecase/completion"
+	"github.com/yangszwei/koala/internal/usecase/harvest"
+	"github.com/yangszwei/koala/internal/usecase/importer"
+	"github.com/yangszwei/koala/internal/usecase/jobqueue"
+	"github.com/yangszwei/koala/internal/usecase/patient"
 	"github.com/yangszwei/koala/internal/usecase/search"
+	"github.com/yangszwei/koala/internal/usecase/terminology"
+	"github.com/yangszwei/koala/internal/usecase/thumbnail"
 	"github.com/yangszwei/koala/web"
 )
 
 // RoutesDeps defines the dependencies required to register HTTP routes.
 type RoutesDeps struct {
-	CompletionService completion.Service
-	SearchService     search.Service
+	Checkpoints        CheckpointManager
+	CompletionService  completion.Service
+	Sources            SourceDirectory
+	HarvestService     harvest.Service
+	ImportService      importer.Service
+	JobService         jobqueue.Service
+	PatientService     patient.Service
+	SearchService      search.Service
+	TerminologyService terminology.Service
+	ThumbnailService   thumbnail.Service
+	IndexQueue         IndexQueue
+	WebhookSecret      string
 }
 
 // RegisterRoutes sets up all HTTP routes, including static file serving and API endpoints.
@@ -28,8 +44,16 @@ func (s *Server) RegisterRoutes(deps RoutesDeps) {
 
 	// API routes
 	api := group.Group(apiBase)
+	RegisterCheckpointHandler(api, deps.Checkpoints)
 	RegisterCompletionHandler(api, deps.CompletionService)
-	RegisterSearchHandler(api, deps.SearchService)
+	RegisterDocumentHandler(api, deps.SearchService, deps.Sources, deps.ThumbnailService)
+	RegisterHarvestHandler(api, deps.HarvestService)
+	RegisterImportHandler(api, deps.ImportService)
+	RegisterJobHandler(api, deps.JobService)
+	RegisterPatientHandler(api, deps.PatientService)
+	RegisterSearchHandler(api, deps.SearchService, deps.CompletionService)
+	RegisterTerminologyHandler(api, deps.TerminologyService)
+	RegisterWebhookHandler(api, deps.IndexQueue, deps.Sources, deps.WebhookSecret)
 }
 
 // NewWebHandler returns a handler that serves static web content, excluding API routes.
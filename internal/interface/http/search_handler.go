@@ -1,24 +1,36 @@
 package http
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 	"github.com/yangszwei/koala/internal/usecase/search"
 )
 
+// QueryRecorder counts the searches made, to rank suggestions by popularity.
+type QueryRecorder interface {
+	// RecordQuery counts a search for the query.
+	RecordQuery(query string)
+}
+
 // SearchHandler handles HTTP requests related to search operations.
 type SearchHandler struct {
-	svc search.Service
+	svc      search.Service
+	recorder QueryRecorder
 }
 
 // RegisterSearchHandler creates a new handler and registers routes.
-func RegisterSearchHandler(r gin.IRouter, svc search.Service) {
-	h := &SearchHandler{svc: svc}
+func RegisterSearchHandler(r gin.IRouter, svc search.Service, recorder QueryRecorder) {
+	h := &SearchHandler{svc: svc, recorder: recorder}
 
 	r.POST("/search/index", h.Index)
 	r.GET("/search", h.Search)
 	r.GET("/search/categories", h.ListCategories) // New route for category listing
+	r.GET("/search/facets", h.Facets)
+	r.GET("/search/similar", h.Similar)
+	r.POST("/search/similar", h.Similar)
 }
 
 // Index handles POST /search/index to add a document.
@@ -38,6 +50,11 @@ func (h *SearchHandler) Index(c *gin.Context) {
 }
 
 // Search handles GET /search to perform a search.
+//
+// A "concept" parameter with a "system|code" token searches for the concept instead of the
+// "search" text; the response explains which concepts the search was expanded to. A misspelled
+// search is answered with "didYouMean", and if it matched nothing, with the results of the
+// correction instead, unless "autocorrect=false" is given.
 func (h *SearchHandler) Search(c *gin.Context) {
 	var q search.Query
 	if err := c.ShouldBindQuery(&q); err != nil {
@@ -45,12 +62,24 @@ func (h *SearchHandler) Search(c *gin.Context) {
 		return
 	}
 
-	results, err := h.svc.Search(c.Request.Context(), q)
+	response, err := h.svc.Search(c.Request.Context(), q)
+	if errors.Is(err, search.ErrInvalidQuery) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, results)
+	// Only the first page counts, so that paging through results is a single search
+	if q.Search != "" && q.Offset == 0 {
+		if response.Corrected {
+			h.recorder.RecordQuery(response.DidYouMean)
+		} else {
+			h.recorder.RecordQuery(q.Search)
+		}
+	}
+	c.JSON(http.StatusOK, response)
 }
 
 // ListCategories handles GET /search/categories to return all categories and their counts.
@@ -62,3 +91,51 @@ func (h *SearchHandler) ListCategories(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, categories)
 }
+
+// Facets handles GET /search/facets to return facet value counts for documents matching a query.
+func (h *SearchHandler) Facets(c *gin.Context) {
+	var q search.Query
+	if err := c.ShouldBindQuery(&q); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid query parameters"})
+		return
+	}
+
+	facets, err := h.svc.Facets(c.Request.Context(), q)
+	if errors.Is(err, search.ErrInvalidQuery) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, facets)
+}
+
+// Similar handles GET and POST /search/similar to find reports similar to a document or text.
+//
+// The "documentId" or "text" parameter gives what to compare with, and "excludePatient=true"
+// leaves out the reports of the document's patient. The filter parameters of /search narrow
+// the results. Long report text can be posted as a form field.
+func (h *SearchHandler) Similar(c *gin.Context) {
+	var q search.SimilarQuery
+	if err := c.ShouldBindWith(&q, binding.Form); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid query parameters"})
+		return
+	}
+
+	response, err := h.svc.Similar(c.Request.Context(), q)
+	if errors.Is(err, search.ErrInvalidQuery) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, search.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "document not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
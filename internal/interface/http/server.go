@@ -5,6 +5,7 @@ package http
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"sync"
@@ -42,18 +43,21 @@ func (s *Server) Run() error {
 	return s.httpServer.ListenAndServe()
 }
 
-// Shutdown gracefully shuts down the HTTP server and calls any registered shutdown hooks.
+// Shutdown gracefully shuts down the HTTP server and then calls the registered shutdown hooks,
+// so that no new requests reach the components being stopped. All hooks are called even if one
+// fails; the errors are joined.
 func (s *Server) Shutdown(ctx context.Context) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+
+	fmt.Println("Shutting down HTTP server...")
+	errs := []error{s.httpServer.Shutdown(ctx)}
+
 	for _, hook := range s.shutdownHooks {
-		if err := hook(ctx); err != nil {
-			return err
-		}
+		errs = append(errs, hook(ctx))
 	}
 
-	fmt.Println("Shutting down HTTP server...")
-	return s.httpServer.Shutdown(ctx)
+	return errors.Join(errs...)
 }
 
 // RegisterShutdownHook adds a function to be called during server shutdown.
@@ -0,0 +1,85 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yangszwei/koala/internal/usecase/terminology"
+)
+
+// TerminologyHandler handles HTTP requests related to standard vocabulary imports.
+type TerminologyHandler struct {
+	svc terminology.Service
+}
+
+// RegisterTerminologyHandler creates a new handler and registers routes.
+func RegisterTerminologyHandler(r gin.IRouter, svc terminology.Service) {
+	h := &TerminologyHandler{svc: svc}
+
+	r.POST("/manage/completion-terms/import", h.Import)
+}
+
+// Import handles POST /manage/completion-terms/import
+//
+// The request is a multipart form with a release "file" and its "format": radlex-owl, radlex-csv,
+// snomed-descriptions, snomed-relationships or loinc. SNOMED CT relationships only set the parents
+// of imported concepts, so they must be imported after the descriptions. The optional "language"
+// field selects the SNOMED CT descriptions, and an optional "refset" file, an RF2 simple reference
+// set, limits them to its member concepts. "classes" is a comma-separated list of LOINC class
+// prefixes to import.
+func (h *TerminologyHandler) Import(c *gin.Context) {
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+		return
+	}
+
+	format, err := terminology.ParseFormat(c.PostForm("format"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	opts := terminology.Options{Language: c.PostForm("language")}
+	for _, class := range strings.Split(c.PostForm("classes"), ",") {
+		if class = strings.TrimSpace(class); class != "" {
+			opts.Classes = append(opts.Classes, class)
+		}
+	}
+
+	if refset, err := c.FormFile("refset"); err == nil {
+		f, err := refset.Open()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to open refset"})
+			return
+		}
+		opts.Concepts, err = terminology.ReadRefset(f)
+		f.Close()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to open file"})
+		return
+	}
+	defer f.Close()
+
+	report, err := h.svc.Import(c.Request.Context(), f, format, opts)
+	if err != nil {
+		c.Error(err)
+		if errors.Is(err, terminology.ErrInvalidInput) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to import terminology"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
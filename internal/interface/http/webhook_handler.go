@@ -0,0 +1,176 @@
+package http
+
+import (
+	"crypto/subtle"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yangszwei/koala/internal/infrastructure/datasource"
+	"github.com/yangszwei/koala/internal/interface/worker"
+)
+
+// maxNotificationSize limits the size of a push notification body.
+const maxNotificationSize = 16 << 20
+
+// IndexQueue accepts targeted index and delete requests for single items of a data source.
+type IndexQueue interface {
+	// Enqueue schedules a fetch and index of a single item of the named data source.
+	Enqueue(name string, summary datasource.DataSummary) error
+	// EnqueueDelete schedules the removal of the document of a single item of the named data source.
+	EnqueueDelete(name string, summary datasource.DataSummary) error
+}
+
+// WebhookHandler handles push notifications from data sources.
+type WebhookHandler struct {
+	queue   IndexQueue
+	sources SourceDirectory
+	secret  string
+}
+
+// RegisterWebhookHandler creates a new handler and registers routes. Notifications are refused
+// unless a secret is configured.
+func RegisterWebhookHandler(r gin.IRouter, queue IndexQueue, sources SourceDirectory, secret string) {
+	h := &WebhookHandler{queue: queue, sources: sources, secret: secret}
+
+	g := r.Group("/webhooks", h.authorize)
+	g.POST("/fhir/:source", h.sourceType("fhir"), h.FHIR)
+	g.PUT("/fhir/:source/DiagnosticReport/:id", h.sourceType("fhir"), h.FHIRResource)
+	g.DELETE("/fhir/:source/DiagnosticReport/:id", h.sourceType("fhir"), h.FHIRResource)
+	g.POST("/orthanc/:source", h.sourceType("dicomweb"), h.Orthanc)
+}
+
+// authorize rejects notifications without the configured bearer token, and all notifications if
+// no secret is configured.
+func (h *WebhookHandler) authorize(c *gin.Context) {
+	if h.secret == "" {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "webhooks are disabled; configure a webhook secret"})
+		return
+	}
+	token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(h.secret)) != 1 {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid webhook token"})
+	}
+}
+
+// sourceType rejects notifications for data sources that are not of the type the endpoint
+// parses notifications of.
+func (h *WebhookHandler) sourceType(typ string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		actual, ok := h.sources.Type(c.Param("source"))
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "unknown data source"})
+			return
+		}
+		if actual != typ {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "data source is not of type " + typ})
+		}
+	}
+}
+
+// FHIR handles POST /webhooks/fhir/:source
+//
+// The body is a FHIR Subscription rest-hook notification for DiagnosticReport resources of the
+// named FHIR data source.
+func (h *WebhookHandler) FHIR(c *gin.Context) {
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxNotificationSize))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read notification"})
+		return
+	}
+
+	n, err := datasource.ParseFHIRNotification(body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.enqueue(c, n)
+}
+
+// FHIRResource handles PUT and DELETE /webhooks/fhir/:source/DiagnosticReport/:id
+//
+// R4 rest-hook subscriptions append the resource path to the endpoint. The report is indexed
+// from the body if present, or fetched by ID otherwise.
+func (h *WebhookHandler) FHIRResource(c *gin.Context) {
+	summary := datasource.DataSummary{ID: c.Param("id"), Type: "report"}
+	if c.Request.Method == http.MethodDelete {
+		h.enqueue(c, datasource.Notification{Deleted: []datasource.DataSummary{summary}})
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxNotificationSize))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read notification"})
+		return
+	}
+
+	n, err := datasource.ParseFHIRNotification(body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(n.Updated) == 0 {
+		n.Updated = append(n.Updated, summary)
+	}
+
+	h.enqueue(c, n)
+}
+
+// Orthanc handles POST /webhooks/orthanc/:source
+//
+// The body is an Orthanc change callback for a study of the named DICOMweb data source.
+func (h *WebhookHandler) Orthanc(c *gin.Context) {
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxNotificationSize))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read notification"})
+		return
+	}
+
+	n, err := datasource.ParseOrthancChange(body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.enqueue(c, n)
+}
+
+// enqueue adds the items of a notification to the index queue and responds with their count.
+func (h *WebhookHandler) enqueue(c *gin.Context, n datasource.Notification) {
+	source := c.Param("source")
+	queued := 0
+
+	for _, summary := range n.Updated {
+		if err := h.queue.Enqueue(source, summary); err != nil {
+			h.queueError(c, err)
+			return
+		}
+		queued++
+	}
+	for _, summary := range n.Deleted {
+		if err := h.queue.EnqueueDelete(source, summary); err != nil {
+			h.queueError(c, err)
+			return
+		}
+		queued++
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"queued": queued})
+}
+
+// queueError responds with the status matching an enqueue error.
+func (h *WebhookHandler) queueError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, worker.ErrUnknownSource):
+		c.JSON(http.StatusNotFound, gin.H{"error": "unknown data source"})
+	case errors.Is(err, worker.ErrQueueFull), errors.Is(err, worker.ErrStopped):
+		c.Header("Retry-After", "30")
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+	default:
+		c.Error(err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to enqueue notification"})
+	}
+}
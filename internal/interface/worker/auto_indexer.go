@@ -2,14 +2,62 @@ package worker
 
 import (
 	"context"
+	"errors"
 	"log"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/yangszwei/koala/internal/infrastructure/datasource"
+	"github.com/yangszwei/koala/internal/usecase/checkpoint"
+	"github.com/yangszwei/koala/internal/usecase/jobqueue"
+	"github.com/yangszwei/koala/internal/usecase/lease"
 	"github.com/yangszwei/koala/internal/usecase/search"
+	"github.com/yangszwei/koala/pkg/elasticutil"
 )
 
+var (
+	// ErrUnknownSource is returned when an item is enqueued for a data source that is not registered.
+	ErrUnknownSource = errors.New("unknown data source")
+	// ErrQueueFull is returned when the targeted index queue cannot accept more items.
+	ErrQueueFull = errors.New("index queue is full")
+	// ErrStopped is returned when an item is enqueued while the indexer is not running.
+	ErrStopped = errors.New("indexer is stopped")
+	// ErrScanRunning is returned when a checkpoint is reset while the data source is being scanned.
+	ErrScanRunning = errors.New("scan is running")
+
+	// errInterrupted is recorded for queued and streamed items that were not processed before
+	// shutdown or before their scan stopped.
+	errInterrupted = errors.New("interrupted by shutdown")
+)
+
+// queueSize is the number of targeted index requests that may wait for a worker.
+const queueSize = 1000
+
+// checkpointInterval is how often the progress of a running scan is saved.
+const checkpointInterval = 30 * time.Second
+
+// recordTimeout bounds the writes of jobs and checkpoints, which outlive cancelled work.
+const recordTimeout = 5 * time.Second
+
+// stopGracePeriod is how long Stop waits for cancelled work to record its jobs and checkpoints.
+const stopGracePeriod = 2 * recordTimeout
+
+// retryLease is the lease resource of the retry loop; data sources use "datasource:<name>".
+const retryLease = "jobs"
+
+// Options configures how the workers coordinate with other replicas.
+type Options struct {
+	// APIOnly disables scans, retries and term stats updates, so the replica only indexes items
+	// pushed through Enqueue.
+	APIOnly bool
+	// HolderID identifies this replica in leases. A random ID based on the host name is used if empty.
+	HolderID string
+	// LeaseTTL is how long a lease stays valid without renewal. Leases are renewed every 5 seconds,
+	// so it should be well above that. Defaults to 30 seconds.
+	LeaseTTL time.Duration
+}
+
 // ScanPolicy defines the configuration for how and when a data source should be scanned for indexing.
 type ScanPolicy struct {
 	FullScanInterval time.Duration
@@ -18,28 +66,67 @@ type ScanPolicy struct {
 }
 
 // AutoIndexer manages scheduled background indexing of multiple data sources based on their respective scan policies.
+//
+// Replicas sharing an Elasticsearch cluster coordinate through leases: a data source is only scanned by
+// the replica holding its lease, and failed jobs are only retried by the holder of the retry lease.
+// A lease lapses when its holder stops renewing it, so another replica takes over if the holder dies.
+//
+// Scans and queue workers stop when Stop is called. Fetches and indexing already in progress
+// run on a separate context that is only cancelled if they do not finish before Stop's deadline.
 type AutoIndexer struct {
-	clients  map[string]datasource.Client
-	svc      search.Service
-	policies map[string]ScanPolicy
-	state    map[string]*indexerState
-	running  map[string]bool
-	mu       sync.Mutex
+	clients     map[string]datasource.Client
+	svc         search.Service
+	jobs        jobqueue.Service
+	checkpoints checkpoint.Service
+	leases      lease.Service
+	opts        Options
+	policies    map[string]ScanPolicy
+	caps        map[string]datasource.Capabilities
+	state       map[string]*indexerState
+	running     map[string]bool
+	queue       chan queuedItem
+	mu          sync.Mutex
+
+	work       context.Context    // Context of in-flight fetches and indexing
+	cancel     context.CancelFunc // Stops scans and workers from picking up new work
+	cancelWork context.CancelFunc // Aborts in-flight fetches and indexing
+	wg         sync.WaitGroup     // Tracks all goroutines started by Start, and the drains of stopped scans
+}
+
+// queuedItem is a targeted request to index or delete a single item, received outside the scan schedule.
+type queuedItem struct {
+	name    string
+	summary datasource.DataSummary
+	delete  bool
 }
 
 // indexerState stores runtime information for a data source, such as when the last full scan occurred.
 type indexerState struct {
 	lastFullScan time.Time
+	offset       int    // Pager offset to resume an interrupted scan from
+	cursor       string // Stream cursor to resume an interrupted scan from
+	position     string // State of a datasource.Stateful client after the last completed scan
 }
 
 // NewAutoIndexer returns an initialized AutoIndexer with default state and client mappings.
-func NewAutoIndexer(svc search.Service) *AutoIndexer {
+// Items that fail to be fetched or indexed are recorded in jobs and retried with backoff, and
+// scan progress is saved to checkpoints so that interrupted scans resume after a restart, or on
+// another replica.
+func NewAutoIndexer(svc search.Service, jobs jobqueue.Service, checkpoints checkpoint.Service, leases lease.Service, opts Options) *AutoIndexer {
+	opts = opts.withDefaults()
+
 	return &AutoIndexer{
-		clients:  make(map[string]datasource.Client),
-		svc:      svc,
-		policies: make(map[string]ScanPolicy),
-		state:    make(map[string]*indexerState),
-		running:  make(map[string]bool),
+		clients:     make(map[string]datasource.Client),
+		svc:         svc,
+		jobs:        jobs,
+		checkpoints: checkpoints,
+		leases:      leases,
+		opts:        opts,
+		policies:    make(map[string]ScanPolicy),
+		caps:        make(map[string]datasource.Capabilities),
+		state:       make(map[string]*indexerState),
+		running:     make(map[string]bool),
+		queue:       make(chan queuedItem, queueSize),
 	}
 }
 
@@ -49,7 +136,9 @@ func (ai *AutoIndexer) Register(client datasource.Client, policy ScanPolicy) {
 	defer ai.mu.Unlock()
 	ai.clients[client.Name()] = client
 	ai.policies[client.Name()] = policy
+	ai.caps[client.Name()] = datasource.CapabilitiesOf(client)
 	ai.state[client.Name()] = &indexerState{}
+	log.Printf("[%s] Registered with capabilities %+v", client.Name(), ai.caps[client.Name()])
 }
 
 // markRunning marks a client as currently running if it's not already.
@@ -71,27 +160,327 @@ func (ai *AutoIndexer) markDone(name string) {
 	ai.running[name] = false
 }
 
-// Start launches background goroutines that perform periodic indexing for each registered client.
+// Start launches background goroutines that perform periodic indexing for each registered client,
+// the workers that process targeted requests from Enqueue and EnqueueDelete, and the retry loop.
+// In API-only mode, only the workers for targeted requests are started.
 func (ai *AutoIndexer) Start(ctx context.Context) {
+	work, cancelWork := context.WithCancel(context.WithoutCancel(ctx))
+	ctx, cancel := context.WithCancel(ctx)
+
+	ai.mu.Lock()
+	ai.work, ai.cancel, ai.cancelWork = work, cancel, cancelWork
+	ai.mu.Unlock()
+
+	ai.runQueue(ctx)
+	if ai.opts.APIOnly {
+		log.Printf("[indexer] Running API-only, scans and retries are disabled")
+		return
+	}
+	log.Printf("[indexer] Running as %s", ai.opts.HolderID)
+
 	for name, client := range ai.clients {
-		go ai.runClient(ctx, name, client)
+		ai.wg.Add(1)
+		go func() {
+			defer ai.wg.Done()
+			ai.runClient(ctx, name, client)
+		}()
+	}
+	ai.wg.Add(1)
+	go func() {
+		defer ai.wg.Done()
+		ai.runRetries(ctx)
+	}()
+}
+
+// Stop stops scheduling scans and waits for in-flight work to finish. If ctx expires first, the
+// in-flight fetches and indexing are cancelled, given stopGracePeriod to wind down, and ctx's
+// error is returned. Scan progress is saved to the checkpoints, and queued requests that were not
+// processed are recorded as jobs so they are retried after the next start.
+func (ai *AutoIndexer) Stop(ctx context.Context) error {
+	ai.mu.Lock()
+	cancel, cancelWork := ai.cancel, ai.cancelWork
+	ai.cancel = nil
+	ai.mu.Unlock()
+	if cancel == nil {
+		return nil
+	}
+
+	log.Printf("[indexer] Stopping...")
+	cancel()
+
+	done := make(chan struct{})
+	go func() {
+		ai.wg.Wait()
+		close(done)
+	}()
+
+	var err error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+		log.Printf("[indexer] In-flight work did not finish in time, cancelling")
+		cancelWork()
+
+		// Cancelled work still records its jobs and saves its checkpoints
+		select {
+		case <-done:
+		case <-time.After(stopGracePeriod):
+			log.Printf("[indexer] In-flight work did not stop within %s", stopGracePeriod)
+		}
+	}
+
+	ai.persistQueue()
+	cancelWork()
+
+	return err
+}
+
+// loadCheckpoint restores the last full scan time and resume position of a client from its saved
+// checkpoint, which may have been written by another replica, and the state of stateful clients.
+// The in-memory state is kept if the checkpoint cannot be loaded.
+func (ai *AutoIndexer) loadCheckpoint(ctx context.Context, name string, client datasource.Client, state *indexerState) {
+	cp, err := ai.checkpoints.Load(ctx, name)
+	if err != nil {
+		log.Printf("[%s] Failed to load checkpoint: %v", name, err)
+		return
+	}
+
+	ai.mu.Lock()
+	defer ai.mu.Unlock()
+	state.lastFullScan = cp.LastFullScan
+	state.offset = cp.Offset
+	state.cursor = cp.Cursor
+	state.position = cp.State
+	if stateful, ok := client.(datasource.Stateful); ok {
+		stateful.Restore(cp.State)
+	}
+}
+
+// saveCheckpoint persists the scan progress of a client. It uses its own timeout, so progress is
+// saved even while the indexer is being stopped. The lease of keeper is renewed first, and the
+// checkpoint is only saved if it is still held, so that a replica that lost the lease does not
+// overwrite the progress of the new holder.
+func (ai *AutoIndexer) saveCheckpoint(keeper *leaseKeeper, cp checkpoint.Checkpoint) {
+	ctx, cancel := context.WithTimeout(context.Background(), recordTimeout)
+	defer cancel()
+
+	if !keeper.renew(ctx) {
+		log.Printf("[%s] Not saving checkpoint, the datasource lease is held by another replica", cp.Source)
+		return
+	}
+	if err := ai.checkpoints.Save(ctx, cp); err != nil {
+		log.Printf("[%s] Failed to save checkpoint: %v", cp.Source, err)
+	}
+}
+
+// Checkpoints returns the saved scan progress of every registered client. Checkpoints are read from
+// the store, so they include scans running on other replicas.
+func (ai *AutoIndexer) Checkpoints(ctx context.Context) ([]checkpoint.Checkpoint, error) {
+	ai.mu.Lock()
+	names := make([]string, 0, len(ai.state))
+	for name := range ai.state {
+		names = append(names, name)
+	}
+	ai.mu.Unlock()
+	sort.Strings(names)
+
+	cps := make([]checkpoint.Checkpoint, 0, len(names))
+	for _, name := range names {
+		cp, err := ai.checkpoints.Load(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		cps = append(cps, cp)
+	}
+	return cps, nil
+}
+
+// ResetCheckpoint discards the scan progress of the named client, so that its next scan is a full
+// scan from the beginning, started as soon as possible. It fails with ErrScanRunning while this
+// replica is scanning the client; a scan running on another replica overwrites the reset checkpoint.
+func (ai *AutoIndexer) ResetCheckpoint(ctx context.Context, name string) error {
+	ai.mu.Lock()
+	state, ok := ai.state[name]
+	if !ok {
+		state, ok = ai.state[elasticutil.EscapeQueryString(name)]
+		name = elasticutil.EscapeQueryString(name)
+	}
+	if !ok {
+		ai.mu.Unlock()
+		return ErrUnknownSource
+	}
+	if ai.running[name] {
+		ai.mu.Unlock()
+		return ErrScanRunning
+	}
+	*state = indexerState{}
+	ai.mu.Unlock()
+
+	return ai.checkpoints.Save(ctx, checkpoint.Checkpoint{Source: name})
+}
+
+// persistQueue records the queued index and delete requests that were not processed as jobs.
+func (ai *AutoIndexer) persistQueue() {
+	ctx, cancel := context.WithTimeout(context.Background(), recordTimeout)
+	defer cancel()
+
+	for {
+		select {
+		case item := <-ai.queue:
+			if item.delete {
+				ai.recordDeleteFailure(ctx, item.summary, errInterrupted)
+				continue
+			}
+			ai.recordFailure(ctx, item.summary, errInterrupted)
+		default:
+			return
+		}
+	}
+}
+
+// Enqueue schedules a targeted fetch and index of a single item of the named data source, so that
+// a change pushed by the source becomes searchable without waiting for the next scan. The summary
+// needs an ID and type; Raw may be nil if the client can fetch the item by ID.
+func (ai *AutoIndexer) Enqueue(name string, summary datasource.DataSummary) error {
+	return ai.enqueue(queuedItem{name: name, summary: summary})
+}
+
+// EnqueueDelete schedules the removal of the document of a single item of the named data source.
+func (ai *AutoIndexer) EnqueueDelete(name string, summary datasource.DataSummary) error {
+	return ai.enqueue(queuedItem{name: name, summary: summary, delete: true})
+}
+
+// enqueue resolves the data source of the item and adds it to the queue without blocking.
+func (ai *AutoIndexer) enqueue(item queuedItem) error {
+	ai.mu.Lock()
+	client, ok := ai.clients[item.name]
+	if !ok {
+		client, ok = ai.clients[elasticutil.EscapeQueryString(item.name)]
+	}
+	running := ai.cancel != nil
+	ai.mu.Unlock()
+	if !ok {
+		return ErrUnknownSource
+	}
+	if !running {
+		return ErrStopped
+	}
+
+	item.name = client.Name()
+	item.summary.Source = client.Name()
+
+	select {
+	case ai.queue <- item:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// runQueue starts the workers that process targeted requests until the context is cancelled.
+func (ai *AutoIndexer) runQueue(ctx context.Context) {
+	const maxWorkers = 5
+
+	for i := 0; i < maxWorkers; i++ {
+		ai.wg.Add(1)
+		go func() {
+			defer ai.wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case item := <-ai.queue:
+					ai.processItem(ai.work, item)
+				}
+			}
+		}()
+	}
+}
+
+// processItem indexes or deletes the document of a single queued item. ctx is the work context.
+func (ai *AutoIndexer) processItem(ctx context.Context, item queuedItem) {
+	docID := item.summary.DocID()
+
+	if item.delete {
+		if err := ai.svc.Delete(ctx, docID); err != nil {
+			log.Printf("[%s] Delete failed for ID %s: %v", item.name, docID, err)
+			ai.recordDeleteFailure(ctx, item.summary, err)
+		} else {
+			log.Printf("[%s] Successfully deleted ID %s", item.name, docID)
+			ai.resolveJob(ctx, item.summary)
+		}
+		return
+	}
+
+	ai.mu.Lock()
+	client := ai.clients[item.name]
+	ai.mu.Unlock()
+
+	doc, err := client.Fetch(ctx, item.summary)
+	if err != nil {
+		log.Printf("[%s] Fetch failed for ID %s: %v", item.name, docID, err)
+		ai.recordFailure(ctx, item.summary, err)
+		return
+	}
+	if err := ai.svc.Index(ctx, *doc); err != nil {
+		log.Printf("[%s] Index failed for ID %s: %v", item.name, doc.ID, err)
+		ai.recordFailure(ctx, item.summary, err)
+	} else {
+		log.Printf("[%s] Successfully indexed ID %s", item.name, doc.ID)
+		ai.resolveJob(ctx, item.summary)
 	}
 }
 
 // runClient periodically attempts to trigger a scan for the given client, ensuring only one concurrent run.
+// Scans only run while this replica holds the lease of the client; a running scan is stopped if the
+// lease is taken by another replica, or cannot be renewed before it expires. The lease is released once the last scan has finished after ctx is cancelled.
 func (ai *AutoIndexer) runClient(ctx context.Context, name string, client datasource.Client) {
 	ticker := time.NewTicker(5 * time.Second)
 	defer ticker.Stop()
 
+	keeper := newLeaseKeeper(ai.leases, ai.opts, "datasource:"+name)
+	leader := false
+	cancelScan := context.CancelFunc(func() {})
+	scanDone := make(chan struct{})
+	close(scanDone)
+
+	defer func() {
+		cancelScan()
+		<-scanDone
+		keeper.release()
+	}()
+
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		case <-ticker.C:
+			if !keeper.renew(ctx) {
+				if leader {
+					log.Printf("[%s] Lost the datasource lease, stopping scan", name)
+					cancelScan()
+					leader = false
+				}
+				continue
+			}
+			if !leader {
+				log.Printf("[%s] Acquired the datasource lease", name)
+				leader = true
+			}
+
 			if ai.markRunning(name) {
+				scanCtx, cancel := context.WithCancel(ctx)
+				done := make(chan struct{})
+				cancelScan, scanDone = cancel, done
+
+				ai.wg.Add(1)
 				go func() {
+					defer ai.wg.Done()
+					defer close(done)
 					defer ai.markDone(name)
-					ai.runOnce(ctx, name, client)
+					defer cancel()
+					ai.runOnce(scanCtx, name, client, keeper)
 				}()
 			}
 		}
@@ -99,53 +488,141 @@ func (ai *AutoIndexer) runClient(ctx context.Context, name string, client dataso
 }
 
 // runOnce checks if a full scan should be performed for a data source and executes it if necessary.
-func (ai *AutoIndexer) runOnce(ctx context.Context, name string, client datasource.Client) {
+// An interrupted scan is resumed immediately. The progress is saved periodically while the scan runs,
+// and once it completes or stops, as long as this replica holds the lease of keeper.
+func (ai *AutoIndexer) runOnce(ctx context.Context, name string, client datasource.Client, keeper *leaseKeeper) {
 	ai.mu.Lock()
 	state := ai.state[name]
 	policy := ai.policies[name]
 	ai.mu.Unlock()
 
+	// Another replica may have scanned the client, or reset its checkpoint, since the last run
+	ai.loadCheckpoint(ctx, name, client, state)
+	if ctx.Err() != nil {
+		return
+	}
+
 	now := time.Now()
-	if now.Sub(state.lastFullScan) > policy.FullScanInterval {
+	if now.Sub(state.lastFullScan) > policy.FullScanInterval || state.offset > 0 || state.cursor != "" {
 		log.Printf("[%s] Running full scan...", name)
-		ai.runScanAll(ctx, name, client)
-		state.lastFullScan = now
+
+		prog := &progress{base: state.offset, cursor: state.cursor}
+		stopSaving := ai.saveProgress(keeper, name, state.lastFullScan, state.position, prog)
+		ai.runScanAll(ctx, name, client, prog)
+		stopSaving()
+
+		ai.mu.Lock()
+		if ctx.Err() != nil {
+			state.offset, state.cursor = prog.checkpoint()
+			log.Printf("[%s] Scan interrupted at offset %d, cursor %q", name, state.offset, state.cursor)
+		} else {
+			state.lastFullScan = now
+			state.offset, state.cursor = 0, ""
+			if stateful, ok := client.(datasource.Stateful); ok {
+				state.position = stateful.State()
+			}
+		}
+		cp := checkpoint.Checkpoint{
+			Source:       name,
+			LastFullScan: state.lastFullScan,
+			InProgress:   ctx.Err() != nil,
+			Offset:       state.offset,
+			Cursor:       state.cursor,
+			State:        state.position,
+		}
+		ai.mu.Unlock()
+
+		ai.saveCheckpoint(keeper, cp)
+	}
+}
+
+// saveProgress periodically saves the progress of a running scan, so that it can be resumed after
+// a crash. The client state of the previous scan is saved until the scan completes. The returned
+// function stops saving.
+func (ai *AutoIndexer) saveProgress(keeper *leaseKeeper, name string, lastFullScan time.Time, position string, prog *progress) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(checkpointInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				offset, cursor := prog.checkpoint()
+				ai.saveCheckpoint(keeper, checkpoint.Checkpoint{
+					Source:       name,
+					LastFullScan: lastFullScan,
+					InProgress:   true,
+					Offset:       offset,
+					Cursor:       cursor,
+					State:        position,
+				})
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
 	}
 }
 
 // runScanAll performs a full scan using either streaming or paginated retrieval, depending on client capabilities.
-func (ai *AutoIndexer) runScanAll(ctx context.Context, name string, client datasource.Client) {
-	if streamable, ok := client.(datasource.Streamer); ok {
-		ai.streamSummaries(ctx, name, streamable)
-	} else if pager, ok := client.(datasource.Pager); ok {
-		ai.pageSummaries(ctx, name, client, pager)
-	} else {
+// Paginated scans start at the base offset of prog, and resumable streams at its cursor.
+func (ai *AutoIndexer) runScanAll(ctx context.Context, name string, client datasource.Client, prog *progress) {
+	ai.mu.Lock()
+	caps := ai.caps[name]
+	ai.mu.Unlock()
+
+	switch {
+	case caps.Streamer:
+		ai.streamSummaries(ctx, name, client.(datasource.Streamer), prog)
+	case caps.Pager:
+		prog.paged = true
+		ai.pageSummaries(ctx, name, client, client.(datasource.Pager), prog)
+	default:
 		log.Printf("[%s] Skipping: no paging or streaming method implemented", name)
 	}
 }
 
 // streamSummaries fetches documents from a streaming data source and processes them for indexing.
-func (ai *AutoIndexer) streamSummaries(ctx context.Context, name string, client datasource.Streamer) {
+// Resumable streams continue from the cursor of prog.
+func (ai *AutoIndexer) streamSummaries(ctx context.Context, name string, client datasource.Streamer, prog *progress) {
 	log.Printf("[%s] Starting indexing...", name)
 
 	ai.mu.Lock()
 	policy := ai.policies[name]
 	ai.mu.Unlock()
 
-	stream, err := client.Stream(ctx, policy.PageSize)
+	var stream <-chan datasource.DataSummary
+	var err error
+	if resumable, ok := client.(datasource.ResumableStreamer); ok && prog.cursor != "" {
+		log.Printf("[%s] Resuming stream from cursor %q", name, prog.cursor)
+		stream, err = resumable.StreamFrom(ctx, policy.PageSize, prog.cursor)
+	} else {
+		prog.cursor = ""
+		stream, err = client.Stream(ctx, policy.PageSize)
+	}
 	if err != nil {
 		log.Printf("[%s] Stream failed: %v", name, err)
 		return
 	}
 
-	ai.processSummaries(ctx, name, client, stream)
+	ai.processSummaries(ctx, name, client, stream, prog)
 
 	log.Printf("[%s] Finished indexing", name)
 }
 
-// pageSummaries fetches documents using offset-based pagination and processes them for indexing.
-func (ai *AutoIndexer) pageSummaries(ctx context.Context, name string, client datasource.Client, pager datasource.Pager) {
-	log.Printf("[%s] Starting indexing...", name)
+// pageSummaries fetches documents using offset-based pagination, starting at the base offset of prog,
+// and processes them for indexing.
+func (ai *AutoIndexer) pageSummaries(ctx context.Context, name string, client datasource.Client, pager datasource.Pager, prog *progress) {
+	offset := prog.base
+	log.Printf("[%s] Starting indexing at offset %d...", name, offset)
 
 	ai.mu.Lock()
 	policy := ai.policies[name]
@@ -158,10 +635,12 @@ func (ai *AutoIndexer) pageSummaries(ctx context.Context, name string, client da
 	go func() {
 		defer close(stream)
 		for policy.MaxPagesPerCycle <= 0 || page < policy.MaxPagesPerCycle {
-			summaries, err := pager.List(ctx, page*policy.PageSize, policy.PageSize)
+			summaries, err := pager.List(ctx, offset+page*policy.PageSize, policy.PageSize)
 			if err != nil {
 				log.Printf("[%s] List failed: %v", name, err)
-				time.Sleep(backoff)
+				if !sleep(ctx, backoff) {
+					return
+				}
 				if backoff < 30*time.Second {
 					backoff *= 2
 				}
@@ -173,24 +652,51 @@ func (ai *AutoIndexer) pageSummaries(ctx context.Context, name string, client da
 				break
 			}
 			for _, s := range summaries {
-				stream <- s
+				select {
+				case stream <- s:
+				case <-ctx.Done():
+					return
+				}
 			}
 			page++
 		}
 	}()
 
-	ai.processSummaries(ctx, name, client, stream)
+	ai.processSummaries(ctx, name, client, stream, prog)
 
 	log.Printf("[%s] Finished indexing", name)
 }
 
 // processSummaries reads document summaries from a channel and concurrently indexes documents that don't already exist.
-func (ai *AutoIndexer) processSummaries(ctx context.Context, name string, client datasource.Client, summaries <-chan datasource.DataSummary) {
+// Summaries from incremental clients are always indexed, since they only report new or changed items.
+// Once ctx is cancelled, in-flight items are finished but no new ones are started; the items that are not
+// started are recorded as jobs, unless the scan resumes from them. The processed summaries are tracked in prog.
+func (ai *AutoIndexer) processSummaries(ctx context.Context, name string, client datasource.Client, summaries <-chan datasource.DataSummary, prog *progress) {
 	const maxWorkers = 5
 	const slowThreshold = 500 * time.Millisecond
 
+	ai.mu.Lock()
+	incremental := ai.caps[name].Incremental
+	resumable := ai.caps[name].Resumable
+	work := ai.work
+	ai.mu.Unlock()
+
+	// interrupt finishes a summary that was not processed because the scan stopped. Paged and
+	// resumable scans emit it again when they resume from the checkpoint; items of other streams
+	// are recorded as jobs, so they are not lost.
+	interrupt := func(summary datasource.DataSummary) {
+		switch {
+		case prog.paged || resumable:
+		case summary.Deleted:
+			ai.recordDeleteFailure(work, summary, errInterrupted)
+		default:
+			ai.recordFailure(work, summary, errInterrupted)
+		}
+		summary.Finish(errInterrupted)
+	}
+
 	var wg sync.WaitGroup
-	tasks := make(chan datasource.DataSummary, 100)
+	tasks := make(chan sequenced, 100)
 
 	for i := 0; i < maxWorkers; i++ {
 		wg.Add(1)
@@ -199,36 +705,64 @@ func (ai *AutoIndexer) processSummaries(ctx context.Context, name string, client
 			// Each worker pulls summaries from the task queue, checks for existence, fetches full document, and indexes it.
 			// If processing is slow, it uses an exponential backoff to avoid overwhelming the system.
 			wait := 250 * time.Millisecond
-			for summary := range tasks {
+			for task := range tasks {
+				summary := task.summary
+				if ctx.Err() != nil {
+					interrupt(summary)
+					continue // stopping; leave the remaining items for the resumed scan or the retries
+				}
 				docID := summary.DocID()
 
 				log.Printf("[%s] Checking ID %s", name, docID)
 				start := time.Now()
 
-				exists, err := ai.svc.Exists(ctx, docID)
-				if err != nil {
-					log.Printf("[%s] Exists check failed for ID %s: %v", name, docID, err)
+				if summary.Deleted {
+					err := ai.svc.Delete(work, docID)
+					if err != nil {
+						log.Printf("[%s] Delete failed for ID %s: %v", name, docID, err)
+						ai.recordDeleteFailure(work, summary, err)
+					} else {
+						log.Printf("[%s] Successfully deleted ID %s", name, docID)
+						ai.resolveJob(work, summary)
+					}
+					prog.done(task.seq)
+					summary.Finish(err)
 					continue
 				}
-				if exists {
-					log.Printf("[%s] Scan found for ID %s", name, docID)
-					continue
+
+				if !incremental {
+					exists, err := ai.svc.Exists(work, docID)
+					if err != nil {
+						log.Printf("[%s] Exists check failed for ID %s: %v", name, docID, err)
+						prog.done(task.seq)
+						summary.Finish(err)
+						continue
+					}
+					if exists {
+						log.Printf("[%s] Scan found for ID %s", name, docID)
+						prog.done(task.seq)
+						summary.Finish(nil)
+						continue
+					}
 				}
 
-				doc, err := client.Fetch(ctx, summary)
+				doc, err := client.Fetch(work, summary)
 				if err != nil {
 					log.Printf("[%s] Fetch failed for ID %s: %v", name, docID, err)
-					continue
-				}
-				if err := ai.svc.Index(ctx, *doc); err != nil {
+					ai.recordFailure(work, summary, err)
+				} else if err = ai.svc.Index(work, *doc); err != nil {
 					log.Printf("[%s] Index failed for ID %s: %v", name, doc.ID, err)
+					ai.recordFailure(work, summary, err)
 				} else {
 					log.Printf("[%s] Successfully indexed ID %s", name, doc.ID)
+					ai.resolveJob(work, summary)
 				}
+				prog.done(task.seq)
+				summary.Finish(err)
 
 				elapsed := time.Since(start)
 				if elapsed > slowThreshold {
-					time.Sleep(wait)
+					sleep(ctx, wait)
 					if wait < 30*time.Second {
 						wait *= 2
 					}
@@ -239,11 +773,255 @@ func (ai *AutoIndexer) processSummaries(ctx context.Context, name string, client
 		}()
 	}
 
+feed:
 	for summary := range summaries {
-		tasks <- summary
+		select {
+		case tasks <- sequenced{seq: prog.dispatch(summary.Cursor), summary: summary}:
+		case <-ctx.Done():
+			interrupt(summary)
+			break feed
+		}
 	}
 
 	close(tasks)
 
 	wg.Wait()
+
+	// Unblock a producer that does not watch the context, and keep what it still emits
+	ai.wg.Add(1)
+	go func() {
+		defer ai.wg.Done()
+		for summary := range summaries {
+			interrupt(summary)
+		}
+	}()
+}
+
+// sequenced is a summary numbered in the order it was read from the scan.
+type sequenced struct {
+	seq     int
+	summary datasource.DataSummary
+}
+
+// progress tracks the position up to which all dispatched summaries of a scan have been processed.
+type progress struct {
+	base   int    // Pager offset of the first dispatched summary
+	cursor string // Stream cursor the scan started from
+	paged  bool   // Whether the scan uses offsets rather than stream cursors
+
+	mu      sync.Mutex
+	next    int            // Sequence number of the next dispatched summary
+	last    string         // Cursor of the last dispatched summary
+	pending map[int]string // Cursors of dispatched summaries that have not been processed
+}
+
+// dispatch returns the sequence number of the next summary and marks it as pending.
+func (p *progress) dispatch(cursor string) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.pending == nil {
+		p.pending = make(map[int]string)
+	}
+	seq := p.next
+	p.pending[seq] = cursor
+	p.last = cursor
+	p.next++
+	return seq
+}
+
+// done marks the summary with the given sequence number as processed.
+func (p *progress) done(seq int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.pending, seq)
+}
+
+// checkpoint returns the position to resume the scan from: the offset of the first summary that has
+// not been processed for paged scans, or its cursor for streams. Resuming may process a few summaries
+// again, but none are skipped.
+func (p *progress) checkpoint() (int, string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	pos, cursor := p.next, p.last
+	if p.next == 0 {
+		cursor = p.cursor
+	}
+	for seq, c := range p.pending {
+		if seq < pos {
+			pos, cursor = seq, c
+		}
+	}
+
+	if p.paged {
+		return p.base + pos, ""
+	}
+	return 0, cursor
+}
+
+// sleep waits for d or until ctx is cancelled. It returns false if ctx was cancelled.
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// recordFailure stores a failed item in the job queue, so it is retried with backoff instead of
+// waiting for the next full scan. The Raw context of the summary is stored if the client can only
+// fetch the item from it. The job is stored even if ctx is cancelled, e.g., by Stop, since the
+// item would be lost otherwise.
+func (ai *AutoIndexer) recordFailure(ctx context.Context, summary datasource.DataSummary, err error) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), recordTimeout)
+	defer cancel()
+
+	failure := jobqueue.Failure{
+		Source: summary.Source,
+		Type:   summary.Type,
+		ItemID: summary.ID,
+		Err:    err,
+	}
+
+	ai.mu.Lock()
+	client := ai.clients[summary.Source]
+	ai.mu.Unlock()
+	if codec, ok := client.(datasource.RawCodec); ok && summary.Raw != nil {
+		raw, encErr := codec.EncodeRaw(summary.Raw)
+		if encErr != nil {
+			log.Printf("[%s] Failed to encode item for ID %s: %v", summary.Source, summary.DocID(), encErr)
+		}
+		failure.Raw = raw
+	}
+
+	if err := ai.jobs.Fail(ctx, failure); err != nil {
+		log.Printf("[%s] Failed to record job for ID %s: %v", summary.Source, summary.DocID(), err)
+	}
+}
+
+// recordDeleteFailure stores an item whose document failed to be removed in the job queue, so the
+// removal is retried with backoff. Like recordFailure, the job is stored even if ctx is cancelled.
+func (ai *AutoIndexer) recordDeleteFailure(ctx context.Context, summary datasource.DataSummary, err error) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), recordTimeout)
+	defer cancel()
+
+	failure := jobqueue.Failure{
+		Source: summary.Source,
+		Type:   summary.Type,
+		ItemID: summary.ID,
+		Delete: true,
+		Err:    err,
+	}
+	if err := ai.jobs.Fail(ctx, failure); err != nil {
+		log.Printf("[%s] Failed to record job for ID %s: %v", summary.Source, summary.DocID(), err)
+	}
+}
+
+// resolveJob removes the failed job of an item that was just indexed or deleted, if there is one.
+func (ai *AutoIndexer) resolveJob(ctx context.Context, summary datasource.DataSummary) {
+	if err := ai.jobs.Resolve(ctx, summary.DocID()); err != nil {
+		log.Printf("[%s] Failed to resolve job for ID %s: %v", summary.Source, summary.DocID(), err)
+	}
+}
+
+// runRetries periodically retries the failed jobs that are due until the context is cancelled.
+// Jobs are only retried while this replica holds the retry lease.
+func (ai *AutoIndexer) runRetries(ctx context.Context) {
+	const retryInterval = 30 * time.Second
+
+	// The lease is renewed more often than jobs are retried, so that it does not lapse in between
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+
+	keeper := newLeaseKeeper(ai.leases, ai.opts, retryLease)
+	defer keeper.release()
+
+	var lastRetry time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !keeper.renew(ctx) {
+				continue
+			}
+			if time.Since(lastRetry) < retryInterval {
+				continue
+			}
+			lastRetry = time.Now()
+
+			jobs, err := ai.jobs.Due(ctx, 100)
+			if err != nil {
+				log.Printf("[jobs] Failed to load due jobs: %v", err)
+				continue
+			}
+			for _, job := range jobs {
+				if ctx.Err() != nil {
+					break
+				}
+				ai.retryJob(ai.work, job)
+			}
+		}
+	}
+}
+
+// retryJob attempts a failed job again. The item is fetched again, by ID or from its stored
+// context, so that a retry indexes the current version of items that can be fetched by ID. The
+// document of a failed removal is removed again. ctx is the work context.
+func (ai *AutoIndexer) retryJob(ctx context.Context, job jobqueue.Job) {
+	summary := datasource.DataSummary{ID: job.ItemID, Source: job.Source, Type: job.Type}
+
+	if job.Delete {
+		if err := ai.svc.Delete(ctx, job.DocID); err != nil {
+			log.Printf("[%s] Retry delete failed for ID %s: %v", job.Source, job.DocID, err)
+			ai.recordDeleteFailure(ctx, summary, err)
+			return
+		}
+		log.Printf("[%s] Successfully deleted ID %s on retry", job.Source, job.DocID)
+		ai.completeJob(ctx, job)
+		return
+	}
+
+	ai.mu.Lock()
+	client, ok := ai.clients[job.Source]
+	ai.mu.Unlock()
+	if !ok {
+		ai.recordFailure(ctx, summary, ErrUnknownSource)
+		return
+	}
+
+	if codec, ok := client.(datasource.RawCodec); ok && len(job.Raw) > 0 {
+		raw, err := codec.DecodeRaw(job.Raw)
+		if err != nil {
+			log.Printf("[%s] Retry decode failed for ID %s: %v", job.Source, job.DocID, err)
+			ai.recordFailure(ctx, summary, err)
+			return
+		}
+		summary.Raw = raw
+	}
+
+	doc, err := client.Fetch(ctx, summary)
+	if err != nil {
+		log.Printf("[%s] Retry fetch failed for ID %s: %v", job.Source, job.DocID, err)
+		ai.recordFailure(ctx, summary, err)
+		return
+	}
+	if err := ai.svc.Index(ctx, *doc); err != nil {
+		log.Printf("[%s] Retry index failed for ID %s: %v", job.Source, job.DocID, err)
+		ai.recordFailure(ctx, summary, err)
+		return
+	}
+
+	log.Printf("[%s] Successfully indexed ID %s on retry", job.Source, doc.ID)
+	ai.completeJob(ctx, job)
+}
+
+// completeJob removes a job that was retried successfully.
+func (ai *AutoIndexer) completeJob(ctx context.Context, job jobqueue.Job) {
+	if err := ai.jobs.Complete(ctx, job.ID); err != nil {
+		log.Printf("[%s] Failed to complete job for ID %s: %v", job.Source, job.DocID, err)
+	}
 }
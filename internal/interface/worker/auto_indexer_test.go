@@ -0,0 +1,349 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yangszwei/koala/internal/infrastructure/datasource"
+	"github.com/yangszwei/koala/internal/usecase/jobqueue"
+	"github.com/yangszwei/koala/internal/usecase/search"
+)
+
+// pushedReport is the Raw context of testClient, which is not a map.
+type pushedReport struct {
+	Text string
+}
+
+// testClient can only fetch reports from their Raw context, like clients of pushed messages.
+type testClient struct {
+	fail    bool
+	fetched chan struct{} // If set, Fetch signals it and blocks until it is cancelled
+}
+
+func (c *testClient) Name() string { return "pushed" }
+
+func (c *testClient) Fetch(ctx context.Context, summary datasource.DataSummary) (*search.Document, error) {
+	if c.fetched != nil {
+		c.fetched <- struct{}{}
+		<-ctx.Done()
+		time.Sleep(50 * time.Millisecond) // winding down
+		return nil, ctx.Err()
+	}
+	if c.fail {
+		return nil, errors.New("temporary failure")
+	}
+	report, ok := summary.Raw.(*pushedReport)
+	if !ok {
+		return nil, fmt.Errorf("report %s was not pushed", summary.ID)
+	}
+	return &search.Document{ID: summary.DocID(), Type: "report", ReportText: report.Text}, nil
+}
+
+func (c *testClient) EncodeRaw(raw any) (json.RawMessage, error) {
+	return json.Marshal(raw)
+}
+
+func (c *testClient) DecodeRaw(data json.RawMessage) (any, error) {
+	var report pushedReport
+	err := json.Unmarshal(data, &report)
+	return &report, err
+}
+
+// memoryIndex records indexed documents.
+type memoryIndex struct {
+	search.Service
+	docs map[string]search.Document
+}
+
+func (m *memoryIndex) Index(_ context.Context, doc search.Document) error {
+	m.docs[doc.ID] = doc
+	return nil
+}
+
+func (m *memoryIndex) Delete(_ context.Context, id string) error {
+	delete(m.docs, id)
+	return nil
+}
+
+// memoryJobs is an in-memory job queue.
+type memoryJobs struct {
+	jobqueue.Service
+	mu   sync.Mutex
+	jobs map[string]jobqueue.Job
+}
+
+func (m *memoryJobs) Fail(_ context.Context, failure jobqueue.Failure) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	id := jobqueue.JobID(failure.DocID())
+	job := m.jobs[id]
+	job.ID, job.DocID = id, failure.DocID()
+	job.Source, job.Type, job.ItemID, job.Delete = failure.Source, failure.Type, failure.ItemID, failure.Delete
+	if failure.Raw != nil {
+		job.Raw = failure.Raw
+	}
+	job.Attempts++
+	m.jobs[id] = job
+	return nil
+}
+
+func (m *memoryJobs) Complete(_ context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.jobs, id)
+	return nil
+}
+
+func (m *memoryJobs) Resolve(_ context.Context, docID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.jobs, jobqueue.JobID(docID))
+	return nil
+}
+
+func TestRetryNonMapRaw(t *testing.T) {
+	tests := []struct {
+		name      string
+		supersede string // Text of a newer version indexed before the retry, if any
+		want      string
+	}{
+		{name: "retried from stored context", want: "first version"},
+		{name: "superseded by newer version", supersede: "second version", want: "second version"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			index := &memoryIndex{docs: make(map[string]search.Document)}
+			jobs := &memoryJobs{jobs: make(map[string]jobqueue.Job)}
+			client := &testClient{fail: true}
+
+			ai := NewAutoIndexer(index, jobs, nil, nil, Options{})
+			ai.Register(client, ScanPolicy{})
+
+			summary := datasource.DataSummary{ID: "r1", Source: "pushed", Type: "report", Raw: &pushedReport{Text: "first version"}}
+			ai.processItem(ctx, queuedItem{name: "pushed", summary: summary})
+			if len(jobs.jobs) != 1 {
+				t.Fatalf("jobs = %d, want 1 after failed fetch", len(jobs.jobs))
+			}
+			client.fail = false
+
+			if tt.supersede != "" {
+				newer := summary
+				newer.Raw = &pushedReport{Text: tt.supersede}
+				ai.processItem(ctx, queuedItem{name: "pushed", summary: newer})
+			}
+			for _, job := range jobs.jobs {
+				ai.retryJob(ctx, job)
+			}
+
+			if got := index.docs["report:pushed:r1"].ReportText; got != tt.want {
+				t.Errorf("indexed text = %q, want %q", got, tt.want)
+			}
+			if len(jobs.jobs) != 0 {
+				t.Errorf("jobs = %d, want 0 after successful index", len(jobs.jobs))
+			}
+		})
+	}
+}
+
+func TestPersistQueue(t *testing.T) {
+	tests := []struct {
+		name       string
+		delete     bool
+		wantDelete bool
+		wantDocs   int // Documents indexed after the job is retried
+	}{
+		{name: "queued index", wantDocs: 1},
+		{name: "queued delete", delete: true, wantDelete: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			index := &memoryIndex{docs: make(map[string]search.Document)}
+			jobs := &memoryJobs{jobs: make(map[string]jobqueue.Job)}
+			client := &testClient{}
+
+			ai := NewAutoIndexer(index, jobs, nil, nil, Options{})
+			ai.Register(client, ScanPolicy{})
+
+			// The document is indexed before, so that a delete has something to remove
+			summary := datasource.DataSummary{ID: "r1", Source: "pushed", Type: "report", Raw: &pushedReport{Text: "report"}}
+			ai.processItem(ctx, queuedItem{name: "pushed", summary: summary})
+			if len(index.docs) != 1 {
+				t.Fatalf("docs = %d, want 1 before the queued item", len(index.docs))
+			}
+
+			ai.queue <- queuedItem{name: "pushed", summary: summary, delete: tt.delete}
+			ai.persistQueue()
+
+			job, ok := jobs.jobs[jobqueue.JobID(summary.DocID())]
+			if !ok {
+				t.Fatal("queued item was not recorded as a job")
+			}
+			if job.Delete != tt.wantDelete {
+				t.Errorf("job.Delete = %t, want %t", job.Delete, tt.wantDelete)
+			}
+
+			ai.retryJob(ctx, job)
+			if len(index.docs) != tt.wantDocs {
+				t.Errorf("docs = %d, want %d after retry", len(index.docs), tt.wantDocs)
+			}
+			if len(jobs.jobs) != 0 {
+				t.Errorf("jobs = %d, want 0 after successful retry", len(jobs.jobs))
+			}
+		})
+	}
+}
+
+func TestProgressCheckpoint(t *testing.T) {
+	tests := []struct {
+		name       string
+		base       int    // Pager offset the scan started from
+		cursor     string // Stream cursor the scan started from
+		paged      bool
+		cursors    []string // Cursors of the dispatched summaries, in order
+		done       []int    // Sequence numbers of the processed summaries
+		wantOffset int
+		wantCursor string
+	}{
+		{name: "paged scan before dispatch", base: 200, paged: true, wantOffset: 200},
+		{name: "paged scan with gap", base: 200, paged: true, cursors: []string{"", "", "", ""}, done: []int{0, 2, 3}, wantOffset: 201},
+		{name: "paged scan all done", paged: true, cursors: []string{"", ""}, done: []int{0, 1}, wantOffset: 2},
+		{name: "stream before dispatch", cursor: "c0", wantCursor: "c0"},
+		{name: "stream with pending summary", cursors: []string{"c1", "c2", "c3"}, done: []int{0, 2}, wantCursor: "c2"},
+		{name: "stream all done", cursors: []string{"c1", "c2"}, done: []int{0, 1}, wantCursor: "c2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &progress{base: tt.base, cursor: tt.cursor, paged: tt.paged}
+			for _, cursor := range tt.cursors {
+				p.dispatch(cursor)
+			}
+			for _, seq := range tt.done {
+				p.done(seq)
+			}
+
+			offset, cursor := p.checkpoint()
+			if offset != tt.wantOffset || cursor != tt.wantCursor {
+				t.Errorf("checkpoint() = %d, %q, want %d, %q", offset, cursor, tt.wantOffset, tt.wantCursor)
+			}
+		})
+	}
+}
+
+func TestProcessDeletedSummary(t *testing.T) {
+	ctx := context.Background()
+	index := &memoryIndex{docs: make(map[string]search.Document)}
+	jobs := &memoryJobs{jobs: make(map[string]jobqueue.Job)}
+	client := &testClient{}
+
+	ai := NewAutoIndexer(index, jobs, nil, nil, Options{})
+	ai.Register(client, ScanPolicy{})
+
+	summary := datasource.DataSummary{ID: "r1", Source: "pushed", Type: "report", Raw: &pushedReport{Text: "report"}}
+	ai.processItem(ctx, queuedItem{name: "pushed", summary: summary})
+	if len(index.docs) != 1 {
+		t.Fatalf("docs = %d, want 1 before the scan", len(index.docs))
+	}
+
+	deleted := summary
+	deleted.Deleted, deleted.Raw = true, nil
+	finished := false
+	deleted.Done = func(err error) { finished = err == nil }
+	summaries := make(chan datasource.DataSummary, 1)
+	summaries <- deleted
+	close(summaries)
+
+	ai.processSummaries(ctx, "pushed", client, summaries, &progress{})
+
+	if len(index.docs) != 0 {
+		t.Errorf("docs = %d, want 0 after a deleted summary", len(index.docs))
+	}
+	if !finished {
+		t.Error("deleted summary was not finished")
+	}
+}
+
+func TestProcessInterruptedSummaries(t *testing.T) {
+	tests := []struct {
+		name     string
+		paged    bool
+		wantJobs map[string]bool // Whether the recorded jobs are deletes, by document ID
+	}{
+		{name: "stream", wantJobs: map[string]bool{"report:pushed:r1": false, "report:pushed:r2": true}},
+		{name: "paged scan resumed from checkpoint", paged: true, wantJobs: map[string]bool{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			index := &memoryIndex{docs: make(map[string]search.Document)}
+			jobs := &memoryJobs{jobs: make(map[string]jobqueue.Job)}
+			client := &testClient{}
+
+			ai := NewAutoIndexer(index, jobs, nil, nil, Options{})
+			ai.Register(client, ScanPolicy{})
+
+			summaries := make(chan datasource.DataSummary, 2)
+			summaries <- datasource.DataSummary{ID: "r1", Source: "pushed", Type: "report", Raw: &pushedReport{Text: "report"}}
+			summaries <- datasource.DataSummary{ID: "r2", Source: "pushed", Type: "report", Deleted: true}
+			close(summaries)
+
+			// The scan is stopped before any summary is processed, while the work context set by
+			// Start is still running
+			ai.work = context.Background()
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			ai.processSummaries(ctx, "pushed", client, summaries, &progress{paged: tt.paged})
+			ai.wg.Wait()
+
+			got := make(map[string]bool)
+			for _, job := range jobs.jobs {
+				got[job.DocID] = job.Delete
+			}
+			if !reflect.DeepEqual(got, tt.wantJobs) {
+				t.Errorf("jobs = %v, want %v", got, tt.wantJobs)
+			}
+			if len(index.docs) != 0 {
+				t.Errorf("docs = %d, want 0 after a stopped scan", len(index.docs))
+			}
+		})
+	}
+}
+
+func TestStopWaitsForCancelledWork(t *testing.T) {
+	index := &memoryIndex{docs: make(map[string]search.Document)}
+	jobs := &memoryJobs{jobs: make(map[string]jobqueue.Job)}
+	client := &testClient{fetched: make(chan struct{})}
+
+	ai := NewAutoIndexer(index, jobs, nil, nil, Options{APIOnly: true})
+	ai.Register(client, ScanPolicy{})
+	ai.Start(context.Background())
+
+	summary := datasource.DataSummary{ID: "r1", Type: "report", Raw: &pushedReport{Text: "report"}}
+	if err := ai.Enqueue("pushed", summary); err != nil {
+		t.Fatal(err)
+	}
+	<-client.fetched
+
+	// The fetch does not finish before the deadline, so it is cancelled
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := ai.Stop(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Stop() = %v, want %v", err, context.Canceled)
+	}
+
+	jobs.mu.Lock()
+	defer jobs.mu.Unlock()
+	if _, ok := jobs.jobs[jobqueue.JobID("report:pushed:r1")]; !ok {
+		t.Error("cancelled fetch was not recorded as a job before Stop returned")
+	}
+}
@@ -0,0 +1,128 @@
+package worker
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/yangszwei/koala/internal/usecase/lease"
+)
+
+// withDefaults returns the options with a random holder ID and the default lease TTL filled in.
+func (o Options) withDefaults() Options {
+	if o.HolderID == "" {
+		o.HolderID = newHolderID()
+	}
+	if o.LeaseTTL <= 0 {
+		o.LeaseTTL = 30 * time.Second
+	}
+	return o
+}
+
+// leaseKeeper tracks whether this replica holds the lease on a resource. A renewal that fails with
+// an error is not taken as losing the lease until the lease, as last renewed, expires, so a transient
+// Elasticsearch error does not stop the holder's work. It is safe for concurrent use.
+type leaseKeeper struct {
+	leases   lease.Service
+	opts     Options
+	resource string
+
+	mu        sync.Mutex
+	held      bool
+	expiresAt time.Time
+}
+
+func newLeaseKeeper(leases lease.Service, opts Options, resource string) *leaseKeeper {
+	return &leaseKeeper{leases: leases, opts: opts, resource: resource}
+}
+
+// renew takes or renews the lease and reports whether this replica holds it.
+func (k *leaseKeeper) renew(ctx context.Context) bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	// The lease expires TTL after the renewal is written at the earliest, so start counting before it
+	start := time.Now()
+	held, err := k.leases.Acquire(ctx, k.resource, k.opts.HolderID, k.opts.LeaseTTL)
+	switch {
+	case err == nil:
+		k.held = held
+		if held {
+			k.expiresAt = start.Add(k.opts.LeaseTTL)
+		}
+	case k.held && time.Now().Before(k.expiresAt):
+		if ctx.Err() == nil {
+			log.Printf("[lease] Failed to renew lease %s, still held until %s: %v",
+				k.resource, k.expiresAt.Format(time.RFC3339), err)
+		}
+	default:
+		if ctx.Err() == nil {
+			log.Printf("[lease] Failed to acquire lease %s: %v", k.resource, err)
+		}
+		k.held = false
+	}
+	return k.held
+}
+
+// release gives up the lease if it is held, so that another replica can take over immediately.
+func (k *leaseKeeper) release() {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if !k.held {
+		return
+	}
+	k.held = false
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := k.leases.Release(ctx, k.resource, k.opts.HolderID); err != nil {
+		log.Printf("[lease] Failed to release lease %s: %v", k.resource, err)
+	}
+}
+
+// newHolderID returns a lease holder ID made of the host name and a random suffix, so that
+// replicas on the same host and restarts of the same replica are told apart.
+func newHolderID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "koala"
+	}
+	suffix := make([]byte, 4)
+	_, _ = rand.Read(suffix)
+	return host + "-" + hex.EncodeToString(suffix)
+}
+
+// runLeased calls fn every interval while this replica holds the lease on resource, until ctx is
+// cancelled, and releases the lease on return. fn is first called as soon as the lease is acquired.
+// The lease is not renewed while fn runs, so another replica may run it as well if it takes longer
+// than the lease TTL; fn must be safe to run concurrently on several replicas.
+func runLeased(ctx context.Context, leases lease.Service, opts Options, resource string, interval time.Duration, fn func(context.Context)) {
+	// The lease is renewed more often than fn runs, so that it does not lapse in between
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+
+	keeper := newLeaseKeeper(leases, opts, resource)
+	defer keeper.release()
+
+	var lastRun time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !keeper.renew(ctx) {
+				continue
+			}
+			if time.Since(lastRun) < interval {
+				continue
+			}
+			lastRun = time.Now()
+			fn(ctx)
+		}
+	}
+}
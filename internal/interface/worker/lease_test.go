@@ -0,0 +1,75 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/yangszwei/koala/internal/usecase/lease"
+)
+
+// acquireResult is the outcome of an Acquire call of scriptedLeases.
+type acquireResult struct {
+	held bool
+	err  error
+}
+
+// scriptedLeases answers Acquire calls with the given results in turn.
+type scriptedLeases struct {
+	lease.Service
+	results  []acquireResult
+	released bool
+}
+
+func (l *scriptedLeases) Acquire(context.Context, string, string, time.Duration) (bool, error) {
+	r := l.results[0]
+	l.results = l.results[1:]
+	return r.held, r.err
+}
+
+func (l *scriptedLeases) Release(context.Context, string, string) error {
+	l.released = true
+	return nil
+}
+
+func TestLeaseKeeperRenew(t *testing.T) {
+	errUnavailable := errors.New("cluster unavailable")
+	tests := []struct {
+		name         string
+		results      []acquireResult
+		expired      bool // Whether the lease expires before the last renewal
+		want         bool
+		wantReleased bool
+	}{
+		{name: "acquired", results: []acquireResult{{held: true}}, want: true, wantReleased: true},
+		{name: "held by another replica", results: []acquireResult{{held: false}}},
+		{name: "error before acquiring", results: []acquireResult{{err: errUnavailable}}},
+		{name: "error before expiry", results: []acquireResult{{held: true}, {err: errUnavailable}}, want: true, wantReleased: true},
+		{name: "error after expiry", results: []acquireResult{{held: true}, {err: errUnavailable}}, expired: true},
+		{name: "taken over", results: []acquireResult{{held: true}, {held: false}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			leases := &scriptedLeases{results: tt.results}
+			k := newLeaseKeeper(leases, Options{HolderID: "a", LeaseTTL: time.Minute}, "test")
+
+			var got bool
+			for i := range tt.results {
+				if tt.expired && i == len(tt.results)-1 {
+					k.expiresAt = time.Now().Add(-time.Second)
+				}
+				got = k.renew(context.Background())
+			}
+			if got != tt.want {
+				t.Errorf("renew() = %t, want %t", got, tt.want)
+			}
+
+			k.release()
+			if leases.released != tt.wantReleased {
+				t.Errorf("released = %t, want %t", leases.released, tt.wantReleased)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package worker
+
+import (
+	"context"
+	"sync"
+)
+
+// loops runs background goroutines that are stopped together.
+type loops struct {
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// start runs each fn in its own goroutine with a context that is cancelled by stop.
+func (l *loops) start(ctx context.Context, fns ...func(context.Context)) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	l.mu.Lock()
+	l.cancel = cancel
+	l.mu.Unlock()
+
+	for _, fn := range fns {
+		l.wg.Add(1)
+		go func() {
+			defer l.wg.Done()
+			fn(ctx)
+		}()
+	}
+}
+
+// stop cancels the goroutines and waits for them to return, or for ctx to expire. It returns
+// false if the goroutines were not running.
+func (l *loops) stop(ctx context.Context) (bool, error) {
+	l.mu.Lock()
+	cancel := l.cancel
+	l.cancel = nil
+	l.mu.Unlock()
+	if cancel == nil {
+		return false, nil
+	}
+
+	cancel()
+
+	done := make(chan struct{})
+	go func() {
+		l.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true, nil
+	case <-ctx.Done():
+		return true, ctx.Err()
+	}
+}
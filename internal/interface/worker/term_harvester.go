@@ -0,0 +1,61 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/yangszwei/koala/internal/usecase/harvest"
+	"github.com/yangszwei/koala/internal/usecase/lease"
+)
+
+// termHarvestLease is the lease resource of the term harvester.
+const termHarvestLease = "term-harvest"
+
+// TermHarvester periodically proposes completion terms mined from the indexed reports. Only the
+// replica holding the harvest lease harvests, and none does in API-only mode.
+type TermHarvester struct {
+	svc      harvest.Service
+	leases   lease.Service
+	opts     Options
+	interval time.Duration
+	loops    loops
+}
+
+// NewTermHarvester returns a TermHarvester that harvests every interval.
+func NewTermHarvester(svc harvest.Service, leases lease.Service, interval time.Duration, opts Options) *TermHarvester {
+	if interval <= 0 {
+		interval = 24 * time.Hour
+	}
+	return &TermHarvester{svc: svc, leases: leases, opts: opts.withDefaults(), interval: interval}
+}
+
+// Start launches the background goroutine that harvests terms.
+func (th *TermHarvester) Start(ctx context.Context) {
+	if th.opts.APIOnly {
+		return
+	}
+	th.loops.start(ctx, func(ctx context.Context) {
+		runLeased(ctx, th.leases, th.opts, termHarvestLease, th.interval, th.run)
+	})
+}
+
+// Stop stops harvesting, waiting for a harvest in progress to be cancelled.
+func (th *TermHarvester) Stop(ctx context.Context) error {
+	_, err := th.loops.stop(ctx)
+	return err
+}
+
+// run harvests terms once and logs the outcome.
+func (th *TermHarvester) run(ctx context.Context) {
+	start := time.Now()
+	result, err := th.svc.Harvest(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("[terms] Failed to harvest terms: %v", err)
+		}
+		return
+	}
+	log.Printf("[terms] Harvested %d documents in %s: %d terms added, %d proposed for review, %d expired",
+		result.Documents, time.Since(start).Round(time.Millisecond), result.Added, result.Proposed, result.Expired)
+}
@@ -0,0 +1,118 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/yangszwei/koala/internal/domain"
+	"github.com/yangszwei/koala/internal/usecase/completion"
+	"github.com/yangszwei/koala/internal/usecase/lease"
+	"github.com/yangszwei/koala/internal/usecase/search"
+)
+
+// termStatsLease is the lease resource of the term stats updates.
+const termStatsLease = "term-stats"
+
+const (
+	// termStatsInterval is how often the document counts of all terms are recomputed.
+	termStatsInterval = time.Hour
+	// queryFlushInterval is how often the buffered search counts are written to the terms.
+	queryFlushInterval = time.Minute
+	// termStatsBatchSize is the number of terms counted in a single multi-search request.
+	termStatsBatchSize = 100
+)
+
+// TermStats keeps the popularity of the completion terms up to date, so that suggestions are
+// ranked by how often terms are mentioned in indexed documents and searched for.
+//
+// Every replica periodically flushes the search counts it buffered. Document counts are only
+// recomputed by the replica holding the term stats lease, and not at all in API-only mode.
+type TermStats struct {
+	terms  completion.Service
+	svc    search.Service
+	leases lease.Service
+	opts   Options
+	loops  loops
+}
+
+// NewTermStats returns a TermStats that counts the mentions of terms using svc.
+func NewTermStats(terms completion.Service, svc search.Service, leases lease.Service, opts Options) *TermStats {
+	return &TermStats{terms: terms, svc: svc, leases: leases, opts: opts.withDefaults()}
+}
+
+// Start launches the background goroutines that flush search counts and update document counts.
+func (ts *TermStats) Start(ctx context.Context) {
+	if ts.opts.APIOnly {
+		ts.loops.start(ctx, ts.runFlush)
+		return
+	}
+	ts.loops.start(ctx, ts.runFlush, func(ctx context.Context) {
+		runLeased(ctx, ts.leases, ts.opts, termStatsLease, termStatsInterval, ts.runUpdate)
+	})
+}
+
+// Stop stops the background goroutines and flushes the search counts buffered since the last flush.
+func (ts *TermStats) Stop(ctx context.Context) error {
+	running, err := ts.loops.stop(ctx)
+	if !running || err != nil {
+		return err
+	}
+	return ts.terms.FlushQueries(ctx)
+}
+
+// runFlush periodically writes the buffered search counts until the context is cancelled.
+func (ts *TermStats) runFlush(ctx context.Context) {
+	ticker := time.NewTicker(queryFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := ts.terms.FlushQueries(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("[terms] Failed to flush query counts: %v", err)
+			}
+		}
+	}
+}
+
+// runUpdate recomputes the document counts of all terms and logs the outcome.
+func (ts *TermStats) runUpdate(ctx context.Context) {
+	start := time.Now()
+	if err := ts.update(ctx); err != nil {
+		if ctx.Err() == nil {
+			log.Printf("[terms] Failed to update term stats: %v", err)
+		}
+		return
+	}
+	log.Printf("[terms] Updated term stats in %s", time.Since(start).Round(time.Millisecond))
+}
+
+// update counts the documents mentioning each term or one of its synonyms, and stores the counts.
+func (ts *TermStats) update(ctx context.Context) error {
+	return ts.terms.Each(ctx, termStatsBatchSize, func(terms []domain.CompletionTerm) error {
+		phrases := make([][]string, len(terms))
+		for i, term := range terms {
+			phrases[i] = append([]string{term.Term}, term.Synonyms...)
+		}
+
+		mentions, err := ts.svc.Mentions(ctx, phrases)
+		if err != nil {
+			return err
+		}
+
+		for i := range terms {
+			stats := domain.TermStats{}
+			if terms[i].Stats != nil {
+				stats = *terms[i].Stats
+			}
+			stats.DocCount = mentions[i].Count
+			stats.Modalities = mentions[i].Modalities
+			terms[i].Stats = &stats
+		}
+
+		return ts.terms.UpdateStats(ctx, terms)
+	})
+}
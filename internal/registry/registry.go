@@ -19,8 +19,17 @@ import (
 	"github.com/yangszwei/koala/internal/infrastructure/elasticsearch"
 	httpserver "github.com/yangszwei/koala/internal/interface/http"
 	"github.com/yangszwei/koala/internal/interface/worker"
+	"github.com/yangszwei/koala/internal/usecase/checkpoint"
 	"github.com/yangszwei/koala/internal/usecase/completion"
+	"github.com/yangszwei/koala/internal/usecase/harvest"
+	"github.com/yangszwei/koala/internal/usecase/importer"
+	"github.com/yangszwei/koala/internal/usecase/jobqueue"
+	"github.com/yangszwei/koala/internal/usecase/lease"
+	"github.com/yangszwei/koala/internal/usecase/patient"
 	"github.com/yangszwei/koala/internal/usecase/search"
+	"github.com/yangszwei/koala/internal/usecase/terminology"
+	"github.com/yangszwei/koala/internal/usecase/thumbnail"
+	"github.com/yangszwei/koala/pkg/diskcache"
 )
 
 // App defines the application lifecycle interface, exposing methods to start and shut down the
@@ -73,16 +82,60 @@ func (a *app) Init() (err error) {
 
 	// Initialize the services
 	completionSvc := completion.NewService(a.es.Client)
-	searchSvc := search.NewService(a.es.Client)
+	if err := completionSvc.Migrate(context.Background()); err != nil {
+		log.Printf("[WARN] failed to migrate completion terms: %v\n", err)
+	}
+	searchSvc := search.NewService(a.es.Client, completionSvc, completionSvc)
+	if !a.cfg.Indexer.APIOnly {
+		if err := searchSvc.Migrate(context.Background()); err != nil {
+			log.Printf("[WARN] failed to migrate search documents: %v\n", err)
+		}
+	}
+	harvestSvc := harvest.NewService(a.es.Client, searchSvc, completionSvc, harvest.Options{
+		MaxDocuments:   a.cfg.Harvest.MaxDocuments,
+		MaxWords:       a.cfg.Harvest.MaxWords,
+		MinDocCount:    a.cfg.Harvest.MinDocCount,
+		MaxDocRatio:    a.cfg.Harvest.MaxDocRatio,
+		MaxCandidates:  a.cfg.Harvest.MaxCandidates,
+		AutoAddMinDocs: a.cfg.Harvest.AutoAddMinDocs,
+	})
+	importSvc := importer.NewService(searchSvc)
+	terminologySvc := terminology.NewService(completionSvc)
+	jobSvc := jobqueue.NewService(a.es.Client)
+	patientSvc := patient.NewService(a.es.Client)
+	checkpointSvc := checkpoint.NewService(a.es.Client)
+	leaseSvc := lease.NewService(a.es.Client)
+
+	workerOpts := worker.Options{
+		APIOnly:  a.cfg.Indexer.APIOnly,
+		LeaseTTL: a.cfg.Indexer.LeaseTTL,
+	}
+	sources := datasource.NewDirectory()
+	thumbnailCache, err := diskcache.New(a.cfg.Thumbnails.CacheDir, a.cfg.Thumbnails.MaxCacheSize)
+	if err != nil {
+		return fmt.Errorf("failed to open thumbnail cache: %w", err)
+	}
+	thumbnailSvc := thumbnail.NewService(searchSvc, sources, thumbnailCache)
+	indexerSvc := worker.NewAutoIndexer(thumbnail.EvictOnDelete(searchSvc, thumbnailSvc), jobSvc, checkpointSvc, leaseSvc, workerOpts)
+	termStats := worker.NewTermStats(completionSvc, searchSvc, leaseSvc, workerOpts)
+	termHarvester := worker.NewTermHarvester(harvestSvc, leaseSvc, a.cfg.Harvest.Interval, workerOpts)
 
 	// Register the HTTP server routes
 	a.server.RegisterRoutes(httpserver.RoutesDeps{
-		CompletionService: completionSvc,
-		SearchService:     searchSvc,
+		Checkpoints:        indexerSvc,
+		CompletionService:  completionSvc,
+		Sources:            sources,
+		HarvestService:     harvestSvc,
+		ImportService:      importSvc,
+		JobService:         jobSvc,
+		PatientService:     patientSvc,
+		SearchService:      searchSvc,
+		TerminologyService: terminologySvc,
+		ThumbnailService:   thumbnailSvc,
+		IndexQueue:         indexerSvc,
+		WebhookSecret:      a.cfg.Webhook.Secret,
 	})
 
-	indexerSvc := worker.NewAutoIndexer(searchSvc)
-
 	scanPolicy := worker.ScanPolicy{
 		FullScanInterval: 15 * time.Minute,
 		PageSize:         50,
@@ -90,15 +143,23 @@ func (a *app) Init() (err error) {
 	}
 
 	for _, ds := range a.cfg.DataSources {
-		client, err := datasource.New(ds.Type, ds.Name, ds.URL)
+		client, err := datasource.New(ds)
 		if err != nil {
 			log.Printf("[WARN] skipping datasource %s: %v\n", ds.Name, err)
 			continue
 		}
 		indexerSvc.Register(client, scanPolicy)
+		sources.Add(ds, client)
 	}
 
 	indexerSvc.Start(context.Background())
+	a.server.RegisterShutdownHook(indexerSvc.Stop)
+
+	termStats.Start(context.Background())
+	a.server.RegisterShutdownHook(termStats.Stop)
+
+	termHarvester.Start(context.Background())
+	a.server.RegisterShutdownHook(termHarvester.Stop)
 
 	return
 }
@@ -119,9 +180,12 @@ func (a *app) Run() error {
 	return a.Shutdown()
 }
 
-// Shutdown gracefully shuts down the HTTP server.
+// shutdownTimeout bounds how long in-flight requests and indexing may take to finish on shutdown.
+const shutdownTimeout = 20 * time.Second
+
+// Shutdown gracefully shuts down the HTTP server and the background indexer.
 func (a *app) Shutdown() error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
 	defer cancel()
 
 	return a.server.Shutdown(ctx)
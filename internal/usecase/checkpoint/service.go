@@ -0,0 +1,93 @@
+// Package checkpoint persists the scan progress of each data source, so that scans survive
+// restarts of the indexer.
+package checkpoint
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8"
+)
+
+// Checkpoint is the persisted scan progress of a data source.
+type Checkpoint struct {
+	Source       string    `json:"source"`       // Data source name
+	LastFullScan time.Time `json:"lastFullScan"` // When the last full scan completed
+	InProgress   bool      `json:"inProgress"`   // Whether a scan was interrupted before it completed
+	Offset       int       `json:"offset"`       // Pager offset up to which the interrupted scan was processed
+	Cursor       string    `json:"cursor"`       // Stream cursor up to which the interrupted scan was processed
+	State        string    `json:"state"`        // Position of an incremental client after the last completed scan
+	UpdatedAt    time.Time `json:"updatedAt"`    // When the checkpoint was last saved
+}
+
+// Service defines the operations on scan checkpoints.
+type Service interface {
+	// Load returns the checkpoint of a data source, or an empty checkpoint if none was saved.
+	Load(ctx context.Context, source string) (Checkpoint, error)
+	// Save stores the checkpoint of a data source.
+	Save(ctx context.Context, cp Checkpoint) error
+}
+
+const indexName = "indexer_checkpoints"
+
+// service implements the checkpoint operations using Elasticsearch.
+type service struct {
+	es *elasticsearch.Client
+}
+
+// NewService returns a new instance of the checkpoint Service.
+func NewService(es *elasticsearch.Client) Service {
+	return &service{es: es}
+}
+
+// Load reads the checkpoint of a data source.
+func (s *service) Load(ctx context.Context, source string) (Checkpoint, error) {
+	res, err := s.es.Get(indexName, source, s.es.Get.WithContext(ctx))
+	if err != nil {
+		return Checkpoint{}, fmt.Errorf("get checkpoint request: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == 404 {
+		return Checkpoint{Source: source}, nil
+	}
+	if res.IsError() {
+		return Checkpoint{}, fmt.Errorf("get checkpoint error: %s", res.String())
+	}
+
+	var parsed struct {
+		Source Checkpoint `json:"_source"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&parsed); err != nil {
+		return Checkpoint{}, fmt.Errorf("decode checkpoint: %w", err)
+	}
+	return parsed.Source, nil
+}
+
+// Save stores the checkpoint of a data source, replacing the previous one.
+func (s *service) Save(ctx context.Context, cp Checkpoint) error {
+	cp.UpdatedAt = time.Now().UTC()
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return fmt.Errorf("marshal checkpoint: %w", err)
+	}
+
+	res, err := s.es.Index(
+		indexName,
+		bytes.NewReader(data),
+		s.es.Index.WithDocumentID(cp.Source),
+		s.es.Index.WithContext(ctx),
+	)
+	if err != nil {
+		return fmt.Errorf("index checkpoint request: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return fmt.Errorf("index checkpoint error: %s", res.String())
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package checkpoint
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8"
+)
+
+// newStore returns a client of a fake Elasticsearch that stores documents by ID in memory.
+func newStore(t *testing.T) *elasticsearch.Client {
+	t.Helper()
+	var mu sync.Mutex
+	docs := make(map[string]string)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+		id := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.Method {
+		case http.MethodPut, http.MethodPost:
+			body, _ := io.ReadAll(r.Body)
+			docs[id] = string(body)
+			fmt.Fprintf(w, `{"_id":%q,"result":"created"}`, id)
+		case http.MethodGet:
+			doc, ok := docs[id]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				fmt.Fprintf(w, `{"_id":%q,"found":false}`, id)
+				return
+			}
+			fmt.Fprintf(w, `{"_id":%q,"found":true,"_source":%s}`, id, doc)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	es, err := elasticsearch.NewClient(elasticsearch.Config{Addresses: []string{srv.URL}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return es
+}
+
+func TestCheckpointRoundTrip(t *testing.T) {
+	scanned := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		cp   Checkpoint
+	}{
+		{name: "completed scan", cp: Checkpoint{Source: "orthanc", LastFullScan: scanned}},
+		{name: "interrupted paged scan", cp: Checkpoint{Source: "pacs", LastFullScan: scanned, InProgress: true, Offset: 2400}},
+		{name: "interrupted stream", cp: Checkpoint{Source: "fhir", InProgress: true, Cursor: "https://fhir.example/DiagnosticReport?_getpages=abc&_offset=50"}},
+		{name: "stateful client", cp: Checkpoint{Source: "bulk", LastFullScan: scanned, State: "2024-05-01T12:00:00Z"}},
+		{name: "escaped source name", cp: Checkpoint{Source: `PACS\\:West`, Offset: 10}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			svc := NewService(newStore(t))
+
+			if err := svc.Save(ctx, tt.cp); err != nil {
+				t.Fatal(err)
+			}
+			got, err := svc.Load(ctx, tt.cp.Source)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got.UpdatedAt.IsZero() {
+				t.Error("UpdatedAt was not set")
+			}
+			got.UpdatedAt = time.Time{}
+			if got != tt.cp {
+				t.Errorf("loaded %+v, want %+v", got, tt.cp)
+			}
+		})
+	}
+}
+
+func TestLoadMissingCheckpoint(t *testing.T) {
+	got, err := NewService(newStore(t)).Load(context.Background(), "new")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != (Checkpoint{Source: "new"}) {
+		t.Errorf("loaded %+v, want an empty checkpoint", got)
+	}
+}
@@ -0,0 +1,76 @@
+package completion
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/yangszwei/koala/internal/domain"
+)
+
+// maxConceptTerms bounds the number of terms returned by a concept lookup.
+const maxConceptTerms = 1000
+
+// Concepts returns the terms coding the given concepts.
+func (s *service) Concepts(ctx context.Context, system string, codes []string) ([]domain.CompletionTerm, error) {
+	return s.findCoded(ctx, system, "code", codes)
+}
+
+// Narrower returns the terms coding the children of the given concepts, in term order.
+func (s *service) Narrower(ctx context.Context, system string, codes []string) ([]domain.CompletionTerm, error) {
+	return s.findCoded(ctx, system, "parents", codes)
+}
+
+// findCoded returns the terms of the system whose field contains one of the codes.
+func (s *service) findCoded(ctx context.Context, system, field string, codes []string) ([]domain.CompletionTerm, error) {
+	if len(codes) == 0 {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(map[string]interface{}{
+		"query": map[string]interface{}{
+			"bool": map[string]interface{}{
+				"filter": []map[string]interface{}{
+					{"term": map[string]interface{}{"system": system}},
+					{"terms": map[string]interface{}{field: codes}},
+				},
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := s.es.Search(
+		s.es.Search.WithContext(ctx),
+		s.es.Search.WithIndex(indexName),
+		s.es.Search.WithBody(bytes.NewReader(data)),
+		s.es.Search.WithSort("term_keyword", "_id"),
+		s.es.Search.WithSize(maxConceptTerms),
+		s.es.Search.WithTrackTotalHits(false),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("search concepts request: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return nil, fmt.Errorf("search concepts error: %s", res.String())
+	}
+
+	var parsed struct {
+		Hits struct {
+			Hits []termHit `json:"hits"`
+		} `json:"hits"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&parsed); err != nil {
+		return nil, fmt.Errorf("decode concepts: %w", err)
+	}
+
+	terms := make([]domain.CompletionTerm, 0, len(parsed.Hits.Hits))
+	for _, hit := range parsed.Hits.Hits {
+		terms = append(terms, hit.term())
+	}
+	return terms, nil
+}
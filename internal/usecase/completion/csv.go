@@ -0,0 +1,118 @@
+package completion
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/yangszwei/koala/internal/domain"
+)
+
+// listSeparator separates the synonyms and parents of a term within a CSV cell.
+const listSeparator = "|"
+
+// csvHeaders are the columns written by Export, in order. Upload accepts the same columns.
+var csvHeaders = []string{"id", "term", "label", "category", "source", "weight", "synonyms", "system", "code", "parents"}
+
+// csvColumns maps the known columns of an uploaded CSV to their index in a record.
+type csvColumns map[string]int
+
+// parseCSVHeaders locates the known columns in the header row. Only the term column is required.
+func parseCSVHeaders(headers []string) (csvColumns, error) {
+	termIdx, err := findTermColumnIndex(headers)
+	if err != nil {
+		return nil, err
+	}
+
+	cols := csvColumns{"term": termIdx}
+	for i, h := range headers {
+		h = strings.ToLower(strings.TrimSpace(h))
+		for _, name := range csvHeaders {
+			if h == name && name != "term" {
+				cols[name] = i
+			}
+		}
+	}
+	return cols, nil
+}
+
+// get returns the value of a column in a record, or an empty string if the column is absent.
+func (cols csvColumns) get(record []string, name string) string {
+	i, ok := cols[name]
+	if !ok || i >= len(record) {
+		return ""
+	}
+	return record[i]
+}
+
+// term builds a completion term from a CSV record.
+func (cols csvColumns) term(record []string) (domain.CompletionTerm, error) {
+	term := domain.CompletionTerm{
+		ID:       cols.get(record, "id"),
+		Term:     cols.get(record, "term"),
+		Label:    cols.get(record, "label"),
+		Category: cols.get(record, "category"),
+		Source:   cols.get(record, "source"),
+		System:   cols.get(record, "system"),
+		Code:     cols.get(record, "code"),
+	}
+
+	if weight := strings.TrimSpace(cols.get(record, "weight")); weight != "" {
+		w, err := strconv.Atoi(weight)
+		if err != nil {
+			return term, fmt.Errorf("invalid weight %q", weight)
+		}
+		term.Weight = w
+	}
+	if synonyms := cols.get(record, "synonyms"); synonyms != "" {
+		term.Synonyms = strings.Split(synonyms, listSeparator)
+	}
+	if parents := cols.get(record, "parents"); parents != "" {
+		term.Parents = strings.Split(parents, listSeparator)
+	}
+
+	term.Normalize()
+	return term, nil
+}
+
+// csvRecord converts a completion term to a CSV record in the order of csvHeaders.
+func csvRecord(term domain.CompletionTerm) []string {
+	weight := ""
+	if term.Weight != 0 {
+		weight = strconv.Itoa(term.Weight)
+	}
+	return []string{
+		term.ID,
+		term.Term,
+		term.Label,
+		term.Category,
+		term.Source,
+		weight,
+		strings.Join(term.Synonyms, listSeparator),
+		term.System,
+		term.Code,
+		strings.Join(term.Parents, listSeparator),
+	}
+}
+
+// newCSVWriter returns a CSV writer with the header row already written.
+func newCSVWriter(w io.Writer) (*csv.Writer, error) {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvHeaders); err != nil {
+		return nil, fmt.Errorf("failed to write CSV headers: %w", err)
+	}
+	return writer, nil
+}
+
+// findTermColumnIndex returns the index of the "term" or "terms" column in the header row.
+// It returns an error if no such column is found.
+func findTermColumnIndex(headers []string) (int, error) {
+	for i, h := range headers {
+		if strings.EqualFold(h, "term") || strings.EqualFold(h, "terms") {
+			return i, nil
+		}
+	}
+	return -1, fmt.Errorf("CSV does not contain a 'term' column")
+}
@@ -5,9 +5,11 @@ import (
 	"context"
 	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"strings"
+	"sync"
 
 	"github.com/elastic/go-elasticsearch/v8"
 	"github.com/yangszwei/koala/internal/domain"
@@ -15,14 +17,73 @@ import (
 	"github.com/yangszwei/koala/pkg/iox"
 )
 
+// ErrNotFound is returned when a completion term does not exist.
+var ErrNotFound = errors.New("completion term not found")
+
+// Filter narrows the terms returned by List.
+type Filter struct {
+	Query    string // Matches terms, labels and synonyms containing the text
+	Category string // Exact category
+	Source   string // Exact source vocabulary
+	Offset   int
+	Limit    int
+}
+
+// Scope restricts suggestions to terms relevant to a modality, a category, or both.
+type Scope struct {
+	Modality string // Modality of the studies whose documents mention the term, e.g., "CT"
+	Category string // Category of the term
+}
+
+// Suggestion is an autocomplete suggestion.
+type Suggestion struct {
+	Text     string `json:"text"`               // Text to insert into the search box
+	Label    string `json:"label"`              // Text to display in the suggestion list
+	Category string `json:"category,omitempty"` // Category of the suggested term
+	Coding   string `json:"coding,omitempty"`   // "system|code" token of the concept the term names, for concept searches
+}
+
 // Service defines autocomplete term operations.
 type Service interface {
 	// Upload uploads terms to the service.
 	Upload(ctx context.Context, terms io.Reader) error
+	// Import creates or replaces the terms read from the channel, and returns the number stored.
+	Import(ctx context.Context, terms <-chan domain.CompletionTerm) (int, error)
+	// ImportParents replaces the parents of the existing terms read from the channel, and returns
+	// the number of terms updated. Only the ID and parents of the terms are used.
+	ImportParents(ctx context.Context, terms <-chan domain.CompletionTerm) (int, error)
+	// Save creates or replaces a single term.
+	Save(ctx context.Context, term domain.CompletionTerm) error
+	// Get returns a term by its ID.
+	Get(ctx context.Context, id string) (domain.CompletionTerm, error)
+	// List returns the terms matching the filter ordered by term, and the total number of matches.
+	List(ctx context.Context, filter Filter) ([]domain.CompletionTerm, int, error)
+	// Known returns the lowercased phrases that are already terms or synonyms of terms.
+	Known(ctx context.Context, phrases []string) (map[string]bool, error)
+	// Concepts returns the terms coding the concepts with the given codes in the system.
+	Concepts(ctx context.Context, system string, codes []string) ([]domain.CompletionTerm, error)
+	// Narrower returns the terms coding the concepts whose parents include one of the codes in the system.
+	Narrower(ctx context.Context, system string, codes []string) ([]domain.CompletionTerm, error)
+	// Correct returns the spelling correction of a phrase using the words of the terms, or an
+	// empty string if there is none.
+	Correct(ctx context.Context, text string) (string, error)
+	// Export writes all terms as CSV in the format accepted by Upload.
+	Export(ctx context.Context, w io.Writer) error
 	// Remove removes a term by its ID.
 	Remove(ctx context.Context, id string) error
-	// Suggest retrieves suggestions based on a query.
-	Suggest(ctx context.Context, query string, size int) ([]string, error)
+	// Suggest retrieves suggestions based on a query, restricted to the scope.
+	Suggest(ctx context.Context, query string, scope Scope, size int) ([]Suggestion, error)
+	// Migrate fills in the fields added to terms stored by earlier versions.
+	Migrate(ctx context.Context) error
+
+	// Each calls fn with every stored term, in batches of up to size terms.
+	Each(ctx context.Context, size int, fn func([]domain.CompletionTerm) error) error
+	// UpdateStats stores the document counts and modalities of the terms and updates their ranking.
+	UpdateStats(ctx context.Context, terms []domain.CompletionTerm) error
+	// RecordQuery counts a search for the query if it matches a term. Counts are buffered in memory.
+	RecordQuery(query string)
+	// FlushQueries adds the buffered search counts to the stats of the matching terms.
+	FlushQueries(ctx context.Context) error
 }
 
 const indexName = "terms_completion"
@@ -30,24 +91,103 @@ const indexName = "terms_completion"
 // service implements the autocomplete term operations using Elasticsearch.
 type service struct {
 	es *elasticsearch.Client
+
+	mu      sync.Mutex
+	queries map[string]int64 // Buffered search counts by lowercased query
 }
 
 // NewService returns a new instance of the completion Service.
 func NewService(es *elasticsearch.Client) Service {
-	return &service{es: es}
+	return &service{es: es, queries: make(map[string]int64)}
+}
+
+// completionInput is the value of a completion field.
+type completionInput struct {
+	Input    []string            `json:"input"`
+	Weight   int                 `json:"weight"`
+	Contexts map[string][]string `json:"contexts"`
+}
+
+// newCompletionInput derives the suggestion input of a term from its term, label and synonyms,
+// weighted by its popularity and scoped by its category and the modalities it is used with.
+func newCompletionInput(term domain.CompletionTerm) completionInput {
+	inputs := []string{term.Term}
+	if term.Label != "" && !strings.EqualFold(term.Label, term.Term) {
+		inputs = append(inputs, term.Label)
+	}
+	inputs = append(inputs, term.Synonyms...)
+
+	return completionInput{
+		Input:    inputs,
+		Weight:   suggestWeight(term),
+		Contexts: map[string][]string{scopeContext: scopesOf(term)},
+	}
+}
+
+// storedTerm is a completion term as it is stored in Elasticsearch.
+type storedTerm struct {
+	domain.CompletionTerm
+	TermKeyword   string          `json:"term_keyword"`   // Term for exact matching and sorting
+	ScopedSuggest completionInput `json:"scoped_suggest"` // Term, label and synonyms weighted for suggestions
+}
+
+// termUpdate holds the fields of a stored term that are replaced when the term is uploaded or
+// saved. Unlike storedTerm, it leaves the stats of the term untouched and clears empty fields.
+type termUpdate struct {
+	ID            string          `json:"id"`
+	Term          string          `json:"term"`
+	Label         string          `json:"label"`
+	Category      string          `json:"category"`
+	Source        string          `json:"source"`
+	Weight        int             `json:"weight"`
+	Synonyms      []string        `json:"synonyms"`
+	System        string          `json:"system"`
+	Code          string          `json:"code"`
+	Parents       []string        `json:"parents"`
+	TermKeyword   string          `json:"term_keyword"`
+	ScopedSuggest completionInput `json:"scoped_suggest"`
+	Spell         []string        `json:"spell"`
+}
+
+// newTermUpdate derives the indexed fields of a term.
+func newTermUpdate(term domain.CompletionTerm) termUpdate {
+	return termUpdate{
+		ID:            term.ID,
+		Term:          term.Term,
+		Label:         term.Label,
+		Category:      term.Category,
+		Source:        term.Source,
+		Weight:        term.Weight,
+		Synonyms:      nonNil(term.Synonyms),
+		System:        term.System,
+		Code:          term.Code,
+		Parents:       nonNil(term.Parents),
+		TermKeyword:   term.Term,
+		ScopedSuggest: newCompletionInput(term),
+		Spell:         append([]string{term.Term}, term.Synonyms...),
+	}
+}
+
+// nonNil returns an empty slice instead of nil, so that the field is cleared when it is stored.
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
 }
 
 // Upload indexes terms from a CSV reader into the Elasticsearch completion index.
 func (s *service) Upload(ctx context.Context, terms io.Reader) error {
 	terms = iox.StripBOM(terms)
 	reader := csv.NewReader(terms)
+	reader.FieldsPerRecord = -1
 
 	headers, err := reader.Read()
 	if err != nil {
 		return fmt.Errorf("failed to read CSV headers: %w", err)
 	}
 
-	termIdx, err := findTermColumnIndex(headers)
+	cols, err := parseCSVHeaders(headers)
 	if err != nil {
 		return err
 	}
@@ -67,21 +207,175 @@ func (s *service) Upload(ctx context.Context, terms io.Reader) error {
 				errCh <- fmt.Errorf("failed to read record: %w", err)
 				return
 			}
-			ch <- domain.NewCompletionTerm(record[termIdx])
+			term, err := cols.term(record)
+			if err != nil {
+				line, _ := reader.FieldPos(0)
+				errCh <- fmt.Errorf("line %d: %w", line, err)
+				return
+			}
+			ch <- term
 		}
 	}()
 
-	err = elasticutil.BulkInsertChan(ctx, s.es, indexName, ch, func(doc domain.CompletionTerm) string {
-		return doc.Term
-	}, 1000)
-	if err != nil {
-		return fmt.Errorf("bulk insert failed: %w", err)
+	if _, err := s.Import(ctx, ch); err != nil {
+		return err
 	}
 
 	if readErr := <-errCh; readErr != nil {
 		return readErr
 	}
+	return nil
+}
+
+// importBatchSize is the number of imported terms whose stats are looked up at once.
+const importBatchSize = 1000
+
+// Import upserts the terms read from the channel and returns the number of terms stored. Terms
+// that already exist keep their stats, and their suggestion weights and scopes are derived from
+// them. Terms without text are skipped.
+func (s *service) Import(ctx context.Context, terms <-chan domain.CompletionTerm) (int, error) {
+	ch := make(chan termUpdate, importBatchSize)
+	errCh := make(chan error, 1)
+
+	go func() {
+		defer close(ch)
+		defer close(errCh)
+
+		batch := make([]domain.CompletionTerm, 0, importBatchSize)
+		flush := func() error {
+			if len(batch) == 0 {
+				return nil
+			}
+			ids := make([]string, len(batch))
+			for i, term := range batch {
+				ids[i] = term.ID
+			}
+			stats, err := s.stats(ctx, ids)
+			if err != nil {
+				return err
+			}
+			for _, term := range batch {
+				term.Stats = stats[term.ID]
+				ch <- newTermUpdate(term)
+			}
+			batch = batch[:0]
+			return nil
+		}
+
+		for term := range terms {
+			term.Normalize()
+			if term.Term == "" {
+				continue
+			}
+			batch = append(batch, term)
+			if len(batch) < importBatchSize {
+				continue
+			}
+			if err := flush(); err != nil {
+				errCh <- err
+				// Drain the producer so that it does not block forever
+				for range terms {
+				}
+				return
+			}
+		}
+		if err := flush(); err != nil {
+			errCh <- err
+		}
+	}()
 
+	count, err := elasticutil.BulkUpdateChan(ctx, s.es, indexName, ch, func(doc termUpdate) string {
+		return doc.ID
+	}, 1000, true)
+	if err != nil {
+		// Drain the producer so that it does not block forever
+		for range ch {
+		}
+		return count, fmt.Errorf("bulk insert failed: %w", err)
+	}
+	if statsErr := <-errCh; statsErr != nil {
+		return count, statsErr
+	}
+
+	return count, s.refresh(ctx)
+}
+
+// stats reads the stats of the stored terms with the given IDs, by ID. Terms that do not exist or
+// have no stats are left out.
+func (s *service) stats(ctx context.Context, ids []string) (map[string]*domain.TermStats, error) {
+	data, err := json.Marshal(map[string]interface{}{"ids": ids})
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := s.es.Mget(
+		bytes.NewReader(data),
+		s.es.Mget.WithIndex(indexName),
+		s.es.Mget.WithSourceIncludes("stats"),
+		s.es.Mget.WithContext(ctx),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("get term stats request: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return nil, fmt.Errorf("get term stats error: %s", res.String())
+	}
+
+	var parsed struct {
+		Docs []struct {
+			ID     string `json:"_id"`
+			Found  bool   `json:"found"`
+			Source struct {
+				Stats *domain.TermStats `json:"stats"`
+			} `json:"_source"`
+		} `json:"docs"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&parsed); err != nil {
+		return nil, fmt.Errorf("decode term stats: %w", err)
+	}
+
+	stats := make(map[string]*domain.TermStats)
+	for _, doc := range parsed.Docs {
+		if doc.Found && doc.Source.Stats != nil {
+			stats[doc.ID] = doc.Source.Stats
+		}
+	}
+	return stats, nil
+}
+
+// parentsUpdate is the part of a stored term written by ImportParents.
+type parentsUpdate struct {
+	ID      string   `json:"-"`
+	Parents []string `json:"parents"`
+}
+
+// ImportParents updates the parents of the terms read from the channel and returns the number of
+// terms updated. Terms that do not exist are not created, nor counted.
+func (s *service) ImportParents(ctx context.Context, terms <-chan domain.CompletionTerm) (int, error) {
+	ch := make(chan parentsUpdate, 1000)
+	go func() {
+		defer close(ch)
+		for term := range terms {
+			ch <- parentsUpdate{ID: term.ID, Parents: nonNil(term.Parents)}
+		}
+	}()
+
+	count, err := elasticutil.BulkUpdateChan(ctx, s.es, indexName, ch, func(doc parentsUpdate) string {
+		return doc.ID
+	}, 1000, false)
+	if err != nil {
+		for range ch {
+		}
+		return count, fmt.Errorf("bulk update failed: %w", err)
+	}
+
+	return count, s.refresh(ctx)
+}
+
+// refresh makes the indexed terms visible to searches.
+func (s *service) refresh(ctx context.Context) error {
 	res, err := s.es.Indices.Refresh(
 		s.es.Indices.Refresh.WithContext(ctx),
 		s.es.Indices.Refresh.WithIndex(indexName),
@@ -98,44 +392,168 @@ func (s *service) Upload(ctx context.Context, terms io.Reader) error {
 	return nil
 }
 
-// Remove deletes a completion term by ID.
-func (s *service) Remove(ctx context.Context, id string) error {
-	res, err := s.es.Delete(
+// Save indexes a single term, replacing any term with the same ID but keeping its stats.
+func (s *service) Save(ctx context.Context, term domain.CompletionTerm) error {
+	term.Normalize()
+	if term.Term == "" {
+		return fmt.Errorf("term is required")
+	}
+
+	current, err := s.Get(ctx, term.ID)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	term.Stats = current.Stats
+
+	data, err := json.Marshal(map[string]interface{}{
+		"doc":           newTermUpdate(term),
+		"doc_as_upsert": true,
+	})
+	if err != nil {
+		return fmt.Errorf("marshal term: %w", err)
+	}
+
+	res, err := s.es.Update(
 		indexName,
-		id,
-		s.es.Delete.WithContext(ctx),
-		s.es.Delete.WithRefresh("wait_for"),
+		term.ID,
+		bytes.NewReader(data),
+		s.es.Update.WithRefresh("wait_for"),
+		s.es.Update.WithContext(ctx),
 	)
 	if err != nil {
-		return err
+		return fmt.Errorf("update term request: %w", err)
 	}
 	defer res.Body.Close()
 
-	if res.IsError() && !strings.Contains(res.String(), "not_found") {
-		return fmt.Errorf("deleting term failed: %s", res.String())
+	if res.IsError() {
+		return fmt.Errorf("update term error: %s", res.String())
 	}
-
 	return nil
 }
 
-// Suggest retrieves term suggestions for a given prefix.
-func (s *service) Suggest(ctx context.Context, prefix string, size int) ([]string, error) {
-	body := map[string]interface{}{
-		"suggest": map[string]interface{}{
-			"term-suggest": map[string]interface{}{
-				"prefix": prefix,
-				"completion": map[string]interface{}{
-					"field": "term",
-					"size":  size / 2,
+// Get reads a term by ID.
+func (s *service) Get(ctx context.Context, id string) (domain.CompletionTerm, error) {
+	res, err := s.es.Get(indexName, id, s.es.Get.WithContext(ctx))
+	if err != nil {
+		return domain.CompletionTerm{}, fmt.Errorf("get term request: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == 404 {
+		return domain.CompletionTerm{}, ErrNotFound
+	}
+	if res.IsError() {
+		return domain.CompletionTerm{}, fmt.Errorf("get term error: %s", res.String())
+	}
+
+	var hit termHit
+	if err := json.NewDecoder(res.Body).Decode(&hit); err != nil {
+		return domain.CompletionTerm{}, fmt.Errorf("decode term: %w", err)
+	}
+	return hit.term(), nil
+}
+
+// List searches the terms matching the filter.
+func (s *service) List(ctx context.Context, filter Filter) ([]domain.CompletionTerm, int, error) {
+	must := []map[string]interface{}{}
+	filters := []map[string]interface{}{}
+
+	if q := strings.TrimSpace(filter.Query); q != "" {
+		must = append(must, map[string]interface{}{
+			"bool": map[string]interface{}{
+				"should": []map[string]interface{}{
+					{"wildcard": map[string]interface{}{
+						"term_keyword": map[string]interface{}{
+							"value":            "*" + escapeWildcard(q) + "*",
+							"case_insensitive": true,
+						},
+					}},
+					{"match": map[string]interface{}{"label": q}},
+					{"match": map[string]interface{}{"synonyms": q}},
 				},
+				"minimum_should_match": 1,
+			},
+		})
+	}
+	if filter.Category != "" {
+		filters = append(filters, map[string]interface{}{"term": map[string]interface{}{"category": filter.Category}})
+	}
+	if filter.Source != "" {
+		filters = append(filters, map[string]interface{}{"term": map[string]interface{}{"source": filter.Source}})
+	}
+
+	body := map[string]interface{}{
+		"query": map[string]interface{}{
+			"bool": map[string]interface{}{
+				"must":   must,
+				"filter": filters,
 			},
-			"term-suggest-fuzzy": map[string]interface{}{
-				"prefix": prefix,
-				"completion": map[string]interface{}{
-					"field": "term",
-					"fuzzy": true,
-					"size":  size / 2,
+		},
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	res, err := s.es.Search(
+		s.es.Search.WithContext(ctx),
+		s.es.Search.WithIndex(indexName),
+		s.es.Search.WithBody(bytes.NewReader(data)),
+		s.es.Search.WithSort("term_keyword", "_id"),
+		s.es.Search.WithFrom(filter.Offset),
+		s.es.Search.WithSize(filter.Limit),
+		s.es.Search.WithTrackTotalHits(true),
+	)
+	if err != nil {
+		return nil, 0, fmt.Errorf("search terms request: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return nil, 0, fmt.Errorf("search terms error: %s", res.String())
+	}
+
+	var parsed struct {
+		Hits struct {
+			Total struct {
+				Value int `json:"value"`
+			} `json:"total"`
+			Hits []termHit `json:"hits"`
+		} `json:"hits"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&parsed); err != nil {
+		return nil, 0, fmt.Errorf("decode terms: %w", err)
+	}
+
+	terms := make([]domain.CompletionTerm, 0, len(parsed.Hits.Hits))
+	for _, hit := range parsed.Hits.Hits {
+		terms = append(terms, hit.term())
+	}
+	return terms, parsed.Hits.Total.Value, nil
+}
+
+// Known looks up the phrases among the terms and synonyms, ignoring case.
+func (s *service) Known(ctx context.Context, phrases []string) (map[string]bool, error) {
+	known := make(map[string]bool)
+	if len(phrases) == 0 {
+		return known, nil
+	}
+
+	lower := make([]string, len(phrases))
+	for i, p := range phrases {
+		lower[i] = strings.ToLower(p)
+	}
+
+	body := map[string]interface{}{
+		"_source": []string{"term", "synonyms"},
+		"query": map[string]interface{}{
+			"bool": map[string]interface{}{
+				"should": []map[string]interface{}{
+					{"terms": map[string]interface{}{"term_keyword": lower}},
+					{"terms": map[string]interface{}{"synonyms.keyword": lower}},
 				},
+				"minimum_should_match": 1,
 			},
 		},
 	}
@@ -149,52 +567,168 @@ func (s *service) Suggest(ctx context.Context, prefix string, size int) ([]strin
 		s.es.Search.WithContext(ctx),
 		s.es.Search.WithIndex(indexName),
 		s.es.Search.WithBody(bytes.NewReader(data)),
+		s.es.Search.WithSize(len(phrases)*2),
 		s.es.Search.WithTrackTotalHits(false),
 	)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("search terms request: %w", err)
 	}
 	defer res.Body.Close()
 
 	if res.IsError() {
-		return nil, fmt.Errorf("suggestion failed: %s", res.String())
+		return nil, fmt.Errorf("search terms error: %s", res.String())
 	}
 
 	var parsed struct {
-		Suggest map[string][]struct {
-			Options []struct {
-				Text string `json:"text"`
-			} `json:"options"`
-		} `json:"suggest"`
+		Hits struct {
+			Hits []termHit `json:"hits"`
+		} `json:"hits"`
 	}
-
 	if err := json.NewDecoder(res.Body).Decode(&parsed); err != nil {
-		return nil, err
+		return nil, fmt.Errorf("decode terms: %w", err)
 	}
 
-	var suggestions []string
-	seen := make(map[string]struct{})
-	for _, key := range []string{"term-suggest", "term-suggest-fuzzy"} {
-		for _, result := range parsed.Suggest[key] {
-			for _, opt := range result.Options {
-				if _, exists := seen[opt.Text]; !exists {
-					suggestions = append(suggestions, opt.Text)
-					seen[opt.Text] = struct{}{}
-				}
+	wanted := make(map[string]bool, len(lower))
+	for _, p := range lower {
+		wanted[p] = true
+	}
+	for _, hit := range parsed.Hits.Hits {
+		for _, text := range append([]string{hit.Source.Term}, hit.Source.Synonyms...) {
+			if text = strings.ToLower(text); wanted[text] {
+				known[text] = true
+			}
+		}
+	}
+	return known, nil
+}
+
+// Export scrolls through all terms and writes them as CSV.
+func (s *service) Export(ctx context.Context, w io.Writer) error {
+	writer, err := newCSVWriter(w)
+	if err != nil {
+		return err
+	}
+
+	err = s.Each(ctx, 1000, func(terms []domain.CompletionTerm) error {
+		for _, term := range terms {
+			if err := writer.Write(csvRecord(term)); err != nil {
+				return fmt.Errorf("failed to write CSV record: %w", err)
 			}
 		}
+		writer.Flush()
+		return writer.Error()
+	})
+	if err != nil {
+		return err
 	}
 
-	return suggestions, nil
+	writer.Flush()
+	return writer.Error()
 }
 
-// findTermColumnIndex returns the index of the "term" or "terms" column in the header row.
-// It returns an error if no such column is found.
-func findTermColumnIndex(headers []string) (int, error) {
-	for i, h := range headers {
-		if strings.EqualFold(h, "term") || strings.EqualFold(h, "terms") {
-			return i, nil
+// Each scrolls through all terms. It stops at the first error returned by fn.
+func (s *service) Each(ctx context.Context, size int, fn func([]domain.CompletionTerm) error) error {
+	err := elasticutil.Scroll(ctx, s.es, indexName, nil, "_doc", size, func(hits []termHit) error {
+		terms := make([]domain.CompletionTerm, 0, len(hits))
+		for _, hit := range hits {
+			terms = append(terms, hit.term())
 		}
+		return fn(terms)
+	})
+	if err != nil {
+		return fmt.Errorf("scroll terms: %w", err)
+	}
+	return nil
+}
+
+// Remove deletes a completion term by ID.
+func (s *service) Remove(ctx context.Context, id string) error {
+	res, err := s.es.Delete(
+		indexName,
+		id,
+		s.es.Delete.WithContext(ctx),
+		s.es.Delete.WithRefresh("wait_for"),
+	)
+	if err != nil {
+		return err
 	}
-	return -1, fmt.Errorf("CSV does not contain a 'term' column")
+	defer res.Body.Close()
+
+	if res.IsError() && !strings.Contains(res.String(), "not_found") {
+		return fmt.Errorf("deleting term failed: %s", res.String())
+	}
+
+	return nil
+}
+
+// migrateScript backfills the ID, keyword, scoped suggestion input and spelling vocabulary of
+// terms stored before they were introduced. Terms without a scoped suggestion input only get
+// their curated weight and category scope; popularity is added the next time the stats are updated.
+const migrateScript = `
+def src = ctx._source;
+if (src.id == null) { src.id = ctx._id; }
+src.term_keyword = src.term;
+if (src.scoped_suggest == null) {
+  def input = [src.term];
+  if (src.label != null && src.label != '') { input.add(src.label); }
+  if (src.synonyms != null) { input.addAll(src.synonyms); }
+  def scopes = [params.all];
+  if (src.category != null && src.category != '') { scopes.add(params.category + src.category.toLowerCase()); }
+  def weight = src.weight == null ? 0 : (int) Math.max(0, src.weight);
+  src.scoped_suggest = ['input': input, 'weight': weight, 'contexts': [params.context: scopes]];
+}
+src.remove('suggest');
+def spell = [src.term];
+if (src.synonyms != null) { spell.addAll(src.synonyms); }
+src.spell = spell;
+`
+
+// Migrate updates terms stored before term metadata, scoped suggestions and spelling correction
+// were introduced, which lack the fields used for listing, suggestions and corrections.
+func (s *service) Migrate(ctx context.Context) error {
+	body := map[string]interface{}{
+		"query": map[string]interface{}{
+			"bool": map[string]interface{}{
+				"should": []map[string]interface{}{
+					{"bool": map[string]interface{}{"must_not": map[string]interface{}{"exists": map[string]interface{}{"field": "scoped_suggest"}}}},
+					{"bool": map[string]interface{}{"must_not": map[string]interface{}{"exists": map[string]interface{}{"field": "spell"}}}},
+				},
+				"minimum_should_match": 1,
+			},
+		},
+		"script": map[string]interface{}{
+			"source": migrateScript,
+			"lang":   "painless",
+			"params": map[string]interface{}{
+				"all":      allScope,
+				"category": categoryScopePrefix,
+				"context":  scopeContext,
+			},
+		},
+	}
+
+	if err := s.updateByQuery(ctx, body); err != nil {
+		return fmt.Errorf("migrate terms: %w", err)
+	}
+	return nil
+}
+
+// termHit is a search hit, get response or suggestion option holding a stored term.
+type termHit struct {
+	ID     string     `json:"_id"`
+	Source storedTerm `json:"_source"`
+}
+
+// term returns the stored term, using the document ID for terms stored without one.
+func (h termHit) term() domain.CompletionTerm {
+	term := h.Source.CompletionTerm
+	if term.ID == "" {
+		term.ID = h.ID
+	}
+	return term
+}
+
+// escapeWildcard escapes the special characters of a wildcard query.
+func escapeWildcard(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`).Replace(s)
 }
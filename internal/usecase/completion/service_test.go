@@ -0,0 +1,119 @@
+package completion
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/elastic/go-elasticsearch/v8"
+	"github.com/yangszwei/koala/internal/domain"
+)
+
+// newTermStore returns a client of a fake Elasticsearch holding the stats of stored terms by ID,
+// which records the docs of bulk updates by ID.
+func newTermStore(t *testing.T, stats map[string]string, updated map[string]termUpdate) *elasticsearch.Client {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/_mget"):
+			var req struct {
+				IDs []string `json:"ids"`
+			}
+			_ = json.NewDecoder(r.Body).Decode(&req)
+			var docs []string
+			for _, id := range req.IDs {
+				if s, ok := stats[id]; ok {
+					docs = append(docs, fmt.Sprintf(`{"_id":%q,"found":true,"_source":{"stats":%s}}`, id, s))
+				} else {
+					docs = append(docs, fmt.Sprintf(`{"_id":%q,"found":false}`, id))
+				}
+			}
+			fmt.Fprintf(w, `{"docs":[%s]}`, strings.Join(docs, ","))
+		case strings.HasSuffix(r.URL.Path, "/_bulk"):
+			var items []string
+			scanner := bufio.NewScanner(r.Body)
+			for scanner.Scan() {
+				if !scanner.Scan() {
+					break
+				}
+				var update struct {
+					Doc termUpdate `json:"doc"`
+				}
+				_ = json.Unmarshal(scanner.Bytes(), &update)
+				updated[update.Doc.ID] = update.Doc
+				items = append(items, fmt.Sprintf(`{"update":{"_id":%q,"status":200}}`, update.Doc.ID))
+			}
+			fmt.Fprintf(w, `{"errors":false,"items":[%s]}`, strings.Join(items, ","))
+		default:
+			fmt.Fprint(w, `{}`)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	es, err := elasticsearch.NewClient(elasticsearch.Config{Addresses: []string{srv.URL}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return es
+}
+
+func TestImportKeepsScopes(t *testing.T) {
+	tests := []struct {
+		name       string
+		stats      string // Stats of the stored term, if it exists
+		wantScopes []string
+		wantWeight int
+	}{
+		{
+			name:       "new term",
+			wantScopes: []string{"_all", "category:finding"},
+			wantWeight: 5,
+		},
+		{
+			name:       "existing term with stats",
+			stats:      `{"docCount":3,"queryCount":1,"modalities":["CT"]}`,
+			wantScopes: []string{"_all", "category:finding", "modality:ct", "modality:ct/category:finding"},
+			wantWeight: 5 + 20 + 20,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stats := make(map[string]string)
+			if tt.stats != "" {
+				stats["nodule"] = tt.stats
+			}
+			updated := make(map[string]termUpdate)
+			svc := NewService(newTermStore(t, stats, updated))
+
+			terms := make(chan domain.CompletionTerm, 1)
+			terms <- domain.CompletionTerm{ID: "nodule", Term: "nodule", Category: "finding", Weight: 5}
+			close(terms)
+
+			count, err := svc.Import(context.Background(), terms)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if count != 1 {
+				t.Errorf("count = %d, want 1", count)
+			}
+
+			suggest := updated["nodule"].ScopedSuggest
+			if got := suggest.Contexts[scopeContext]; !reflect.DeepEqual(got, tt.wantScopes) {
+				t.Errorf("scopes = %v, want %v", got, tt.wantScopes)
+			}
+			if suggest.Weight != tt.wantWeight {
+				t.Errorf("weight = %d, want %d", suggest.Weight, tt.wantWeight)
+			}
+		})
+	}
+}
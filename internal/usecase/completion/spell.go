@@ -0,0 +1,75 @@
+package completion
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Correct returns the phrase closest to text made of the words of the terms and their synonyms,
+// or an empty string if text is spelled correctly or no correction is found.
+func (s *service) Correct(ctx context.Context, text string) (string, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return "", nil
+	}
+
+	data, err := json.Marshal(map[string]interface{}{
+		"size": 0,
+		"suggest": map[string]interface{}{
+			"text": text,
+			"correction": map[string]interface{}{
+				"phrase": map[string]interface{}{
+					"field":      "spell",
+					"size":       1,
+					"gram_size":  1,
+					"max_errors": 2,
+					"direct_generator": []map[string]interface{}{
+						{"field": "spell", "suggest_mode": "missing"},
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	res, err := s.es.Search(
+		s.es.Search.WithContext(ctx),
+		s.es.Search.WithIndex(indexName),
+		s.es.Search.WithBody(bytes.NewReader(data)),
+	)
+	if err != nil {
+		return "", fmt.Errorf("correct spelling request: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return "", fmt.Errorf("correct spelling error: %s", res.String())
+	}
+
+	var parsed struct {
+		Suggest struct {
+			Correction []struct {
+				Options []struct {
+					Text string `json:"text"`
+				} `json:"options"`
+			} `json:"correction"`
+		} `json:"suggest"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&parsed); err != nil {
+		return "", fmt.Errorf("decode spelling correction: %w", err)
+	}
+
+	for _, entry := range parsed.Suggest.Correction {
+		for _, option := range entry.Options {
+			if !strings.EqualFold(option.Text, text) {
+				return option.Text, nil
+			}
+		}
+	}
+	return "", nil
+}
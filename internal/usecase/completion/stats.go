@@ -0,0 +1,158 @@
+package completion
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/yangszwei/koala/internal/domain"
+	"github.com/yangszwei/koala/pkg/elasticutil"
+)
+
+// maxBufferedQueries bounds the number of distinct queries counted between flushes.
+const maxBufferedQueries = 10000
+
+// statsUpdate is the part of a stored term written by UpdateStats. The query count is left out,
+// since it is only ever incremented by FlushQueries.
+type statsUpdate struct {
+	ID    string `json:"-"`
+	Stats struct {
+		DocCount   int64     `json:"docCount"`
+		Modalities []string  `json:"modalities"`
+		UpdatedAt  time.Time `json:"updatedAt"`
+	} `json:"stats"`
+	ScopedSuggest completionInput `json:"scoped_suggest"`
+}
+
+// UpdateStats stores the document counts and modalities of the terms, and updates their
+// suggestion weights and scopes accordingly.
+func (s *service) UpdateStats(ctx context.Context, terms []domain.CompletionTerm) error {
+	now := time.Now().UTC()
+
+	ch := make(chan statsUpdate, len(terms))
+	for _, term := range terms {
+		if term.Stats == nil {
+			continue
+		}
+
+		var update statsUpdate
+		update.ID = term.ID
+		update.Stats.DocCount = term.Stats.DocCount
+		update.Stats.Modalities = term.Stats.Modalities
+		if update.Stats.Modalities == nil {
+			update.Stats.Modalities = []string{}
+		}
+		update.Stats.UpdatedAt = now
+		update.ScopedSuggest = newCompletionInput(term)
+		ch <- update
+	}
+	close(ch)
+
+	_, err := elasticutil.BulkUpdateChan(ctx, s.es, indexName, ch, func(doc statsUpdate) string {
+		return doc.ID
+	}, 1000, false)
+	if err != nil {
+		return fmt.Errorf("bulk update failed: %w", err)
+	}
+	return nil
+}
+
+// RecordQuery counts a search for the query. Queries longer than a term can be are ignored.
+func (s *service) RecordQuery(query string) {
+	query = strings.ToLower(strings.TrimSpace(query))
+	if query == "" || len(query) > 50 {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.queries[query]; ok || len(s.queries) < maxBufferedQueries {
+		s.queries[query]++
+	}
+}
+
+// flushScript adds the buffered search counts of a term and its synonyms to its query count.
+const flushScript = `
+def src = ctx._source;
+long n = params.counts.getOrDefault(src.term.toLowerCase(), 0L);
+if (src.synonyms != null) {
+  for (s in src.synonyms) { n += params.counts.getOrDefault(s.toLowerCase(), 0L); }
+}
+if (src.stats == null) { src.stats = [:]; }
+src.stats.queryCount = (src.stats.queryCount == null ? 0L : src.stats.queryCount) + n;
+`
+
+// FlushQueries adds the buffered search counts to the terms matching the queries by term or
+// synonym. The counts are kept for the next flush if the update fails.
+func (s *service) FlushQueries(ctx context.Context) error {
+	s.mu.Lock()
+	counts := s.queries
+	s.queries = make(map[string]int64)
+	s.mu.Unlock()
+
+	if len(counts) == 0 {
+		return nil
+	}
+
+	queries := make([]string, 0, len(counts))
+	for q := range counts {
+		queries = append(queries, q)
+	}
+
+	body := map[string]interface{}{
+		"query": map[string]interface{}{
+			"bool": map[string]interface{}{
+				"should": []map[string]interface{}{
+					{"terms": map[string]interface{}{"term_keyword": queries}},
+					{"terms": map[string]interface{}{"synonyms.keyword": queries}},
+				},
+				"minimum_should_match": 1,
+			},
+		},
+		"script": map[string]interface{}{
+			"source": flushScript,
+			"lang":   "painless",
+			"params": map[string]interface{}{"counts": counts},
+		},
+	}
+
+	err := s.updateByQuery(ctx, body)
+	if err != nil {
+		s.mu.Lock()
+		for q, n := range counts {
+			s.queries[q] += n
+		}
+		s.mu.Unlock()
+		return fmt.Errorf("flush query counts: %w", err)
+	}
+	return nil
+}
+
+// updateByQuery runs an update by query against the term index, skipping terms that are modified
+// concurrently.
+func (s *service) updateByQuery(ctx context.Context, body map[string]interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	res, err := s.es.UpdateByQuery(
+		[]string{indexName},
+		s.es.UpdateByQuery.WithContext(ctx),
+		s.es.UpdateByQuery.WithBody(bytes.NewReader(data)),
+		s.es.UpdateByQuery.WithConflicts("proceed"),
+		s.es.UpdateByQuery.WithRefresh(true),
+	)
+	if err != nil {
+		return fmt.Errorf("update by query request: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return fmt.Errorf("update by query error: %s", res.String())
+	}
+	return nil
+}
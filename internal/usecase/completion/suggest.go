@@ -0,0 +1,188 @@
+package completion
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/yangszwei/koala/internal/domain"
+)
+
+// The scoped_suggest completion field has a single category context listing the scopes a term is
+// suggested in. Every term is in the "_all" scope, since suggestions must always be queried with a
+// context, and in one scope per category, modality, and modality and category combination, so that
+// a combined scope only matches terms that are relevant to both.
+const (
+	scopeContext        = "scope"
+	allScope            = "_all"
+	categoryScopePrefix = "category:"
+	modalityScopePrefix = "modality:"
+)
+
+// fuzzyPenalty scales the score of suggestions that only match the prefix with a typo, so that
+// they are ranked below exact matches of similar popularity.
+const fuzzyPenalty = 0.5
+
+// scopesOf returns the scopes a term is suggested in.
+func scopesOf(term domain.CompletionTerm) []string {
+	scopes := []string{allScope}
+
+	category := strings.ToLower(term.Category)
+	if category != "" {
+		scopes = append(scopes, categoryScopePrefix+category)
+	}
+	if term.Stats != nil {
+		for _, m := range term.Stats.Modalities {
+			m = strings.ToLower(m)
+			scopes = append(scopes, modalityScopePrefix+m)
+			if category != "" {
+				scopes = append(scopes, modalityScopePrefix+m+"/"+categoryScopePrefix+category)
+			}
+		}
+	}
+	return scopes
+}
+
+// value returns the context value matching the scope.
+func (s Scope) value() string {
+	modality := strings.ToLower(strings.TrimSpace(s.Modality))
+	category := strings.ToLower(strings.TrimSpace(s.Category))
+	switch {
+	case modality != "" && category != "":
+		return modalityScopePrefix + modality + "/" + categoryScopePrefix + category
+	case modality != "":
+		return modalityScopePrefix + modality
+	case category != "":
+		return categoryScopePrefix + category
+	default:
+		return allScope
+	}
+}
+
+// suggestWeight ranks a term by its curated weight and its popularity. Popularity grows
+// logarithmically, so that a handful of very common terms do not crowd out the rest, and a search
+// counts more than a mention in a document since it reflects what users look for.
+func suggestWeight(term domain.CompletionTerm) int {
+	weight := max(term.Weight, 0)
+	if term.Stats != nil {
+		weight += int(math.Round(10 * math.Log2(1+float64(term.Stats.DocCount))))
+		weight += int(math.Round(20 * math.Log2(1+float64(term.Stats.QueryCount))))
+	}
+	return weight
+}
+
+// Suggest retrieves term suggestions for a given prefix within a scope. The prefix is matched
+// against the terms, labels and synonyms, both exactly and with typos, and the matches are ranked
+// together by weight, with typo matches penalized.
+func (s *service) Suggest(ctx context.Context, prefix string, scope Scope, size int) ([]Suggestion, error) {
+	completion := func(fuzzy bool) map[string]interface{} {
+		c := map[string]interface{}{
+			"field":           "scoped_suggest",
+			"size":            size,
+			"skip_duplicates": true,
+			"contexts": map[string]interface{}{
+				scopeContext: []string{scope.value()},
+			},
+		}
+		if fuzzy {
+			c["fuzzy"] = map[string]interface{}{"fuzziness": "AUTO"}
+		}
+		return c
+	}
+
+	body := map[string]interface{}{
+		"_source": []string{"id", "term", "label", "category", "system", "code"},
+		"suggest": map[string]interface{}{
+			"term-suggest": map[string]interface{}{
+				"prefix":     prefix,
+				"completion": completion(false),
+			},
+			"term-suggest-fuzzy": map[string]interface{}{
+				"prefix":     prefix,
+				"completion": completion(true),
+			},
+		},
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := s.es.Search(
+		s.es.Search.WithContext(ctx),
+		s.es.Search.WithIndex(indexName),
+		s.es.Search.WithBody(bytes.NewReader(data)),
+		s.es.Search.WithTrackTotalHits(false),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return nil, fmt.Errorf("suggestion failed: %s", res.String())
+	}
+
+	var parsed struct {
+		Suggest map[string][]struct {
+			Options []struct {
+				termHit
+				Score float64 `json:"_score"`
+			} `json:"options"`
+		} `json:"suggest"`
+	}
+
+	if err := json.NewDecoder(res.Body).Decode(&parsed); err != nil {
+		return nil, err
+	}
+
+	type candidate struct {
+		term  domain.CompletionTerm
+		score float64
+	}
+	candidates := make(map[string]*candidate)
+	for key, factor := range map[string]float64{"term-suggest": 1, "term-suggest-fuzzy": fuzzyPenalty} {
+		for _, result := range parsed.Suggest[key] {
+			for _, opt := range result.Options {
+				term := opt.term()
+				// Weights may be 0, so the score is offset for the penalty to have an effect
+				score := (opt.Score + 1) * factor
+				if c, ok := candidates[term.ID]; !ok || score > c.score {
+					candidates[term.ID] = &candidate{term: term, score: score}
+				}
+			}
+		}
+	}
+
+	ranked := make([]*candidate, 0, len(candidates))
+	for _, c := range candidates {
+		ranked = append(ranked, c)
+	}
+	slices.SortFunc(ranked, func(a, b *candidate) int {
+		if a.score != b.score {
+			return cmp.Compare(b.score, a.score)
+		}
+		if len(a.term.Term) != len(b.term.Term) {
+			return cmp.Compare(len(a.term.Term), len(b.term.Term))
+		}
+		return strings.Compare(a.term.Term, b.term.Term)
+	})
+
+	suggestions := make([]Suggestion, 0, min(len(ranked), size))
+	for _, c := range ranked[:min(len(ranked), size)] {
+		suggestions = append(suggestions, Suggestion{
+			Text:     c.term.Term,
+			Label:    c.term.DisplayLabel(),
+			Category: c.term.Category,
+			Coding:   c.term.Coding(),
+		})
+	}
+
+	return suggestions, nil
+}
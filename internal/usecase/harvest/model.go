@@ -0,0 +1,76 @@
+package harvest
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrNotFound is returned when a candidate does not exist.
+var ErrNotFound = errors.New("candidate not found")
+
+// State is the review state of a candidate term.
+type State string
+
+const (
+	// StatePending candidates wait for a curator to approve or reject them.
+	StatePending State = "pending"
+	// StateApproved candidates were added as completion terms by a curator.
+	StateApproved State = "approved"
+	// StateAdded candidates were added as completion terms automatically.
+	StateAdded State = "added"
+	// StateRejected candidates are not proposed again.
+	StateRejected State = "rejected"
+)
+
+// Source is the source vocabulary of the completion terms added from candidates.
+const Source = "harvested"
+
+// Candidate is a phrase found in report text that may be added as a completion term.
+type Candidate struct {
+	ID        string    `json:"id"`        // Hash of the phrase
+	Phrase    string    `json:"phrase"`    // Lowercased phrase as found in the reports
+	Words     int       `json:"words"`     // Number of words in the phrase
+	DocCount  int       `json:"docCount"`  // Number of sampled documents mentioning the phrase
+	Score     float64   `json:"score"`     // Significance of the phrase; higher is more significant
+	State     State     `json:"state"`     // pending | approved | added | rejected
+	CreatedAt time.Time `json:"createdAt"` // When the phrase was first proposed
+	UpdatedAt time.Time `json:"updatedAt"` // When the phrase was last counted or reviewed
+}
+
+// Options configures how candidates are mined from the reports.
+type Options struct {
+	MaxDocuments   int     // Number of most recent reports sampled per run
+	MaxWords       int     // Longest phrase proposed, in words
+	MinDocCount    int     // Fewest documents a phrase must appear in to be proposed
+	MaxDocRatio    float64 // Phrases in a larger share of documents are boilerplate and ignored
+	MaxCandidates  int     // Most significant phrases proposed per run
+	AutoAddMinDocs int     // Phrases in at least this many documents are added without review; 0 disables
+}
+
+// withDefaults fills in the unset options.
+func (o Options) withDefaults() Options {
+	if o.MaxDocuments <= 0 {
+		o.MaxDocuments = 20000
+	}
+	if o.MaxWords <= 0 {
+		o.MaxWords = 3
+	}
+	if o.MinDocCount <= 0 {
+		o.MinDocCount = 5
+	}
+	if o.MaxDocRatio <= 0 || o.MaxDocRatio > 1 {
+		o.MaxDocRatio = 0.5
+	}
+	if o.MaxCandidates <= 0 {
+		o.MaxCandidates = 200
+	}
+	return o
+}
+
+// Result summarizes a harvest run.
+type Result struct {
+	Documents int `json:"documents"` // Number of documents sampled
+	Proposed  int `json:"proposed"`  // Number of candidates queued for review
+	Added     int `json:"added"`     // Number of candidates added as terms automatically
+	Expired   int `json:"expired"`   // Number of pending candidates no longer proposed and deleted
+}
@@ -0,0 +1,177 @@
+package harvest
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// maxTrackedPhrases bounds the number of distinct phrases counted at once. When it is exceeded, the
+// rarest phrases are forgotten until half of them remain, since they are unlikely to become frequent.
+const maxTrackedPhrases = 500000
+
+// subsumedRatio is how much of a phrase's occurrences a longer phrase must account for to replace it,
+// e.g., "pleural" is dropped in favour of "pleural effusion" if it rarely occurs on its own.
+const subsumedRatio = 0.9
+
+// stopwords may not start or end a phrase, and are not proposed on their own. They are common English
+// words, followed by words that are common in reports but do not make sense as terms on their own.
+var stopwords = toSet(`a about above after again against all also an and any are as at be because been
+before being below between both but by can could did do does doing down during each few for from
+further had has have having here how if in into is it its itself just may might more most must no nor
+not now of off on once only or other our out over own per same seen should since so some such than
+that the their them then there these they this those through to too under until up upon very was
+were what when where which while who whom why will with within without would yet
+
+compared comparison consistent demonstrated evidence exam examination finding findings identified
+impression likely measures measuring noted patient possible present previous prior probably study
+suggest suggests suggestive unchanged visualized`)
+
+func toSet(words string) map[string]bool {
+	set := make(map[string]bool)
+	for _, w := range strings.Fields(words) {
+		set[w] = true
+	}
+	return set
+}
+
+// isSentenceBreak reports whether a phrase may not span r.
+func isSentenceBreak(r rune) bool {
+	return strings.ContainsRune(".,;:!?()[]{}\"\n\r\t", r)
+}
+
+// isWordBreak reports whether r separates words.
+func isWordBreak(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-' && r != '\''
+}
+
+// counter counts the number of documents each phrase of up to maxWords words appears in.
+type counter struct {
+	maxWords   int
+	maxPhrases int
+	docs       int
+	df         map[string]int
+}
+
+func newCounter(maxWords int) *counter {
+	return &counter{maxWords: maxWords, maxPhrases: maxTrackedPhrases, df: make(map[string]int)}
+}
+
+// add counts the phrases of a document's text.
+func (c *counter) add(text string) {
+	c.docs++
+	for phrase := range c.phrases(text) {
+		c.df[phrase]++
+	}
+	if len(c.df) > c.maxPhrases {
+		c.prune()
+	}
+}
+
+// prune forgets the rarest phrases until at most half of maxPhrases remain, so that it runs once
+// per many documents rather than on every document. Phrases tied at the cut-off are all forgotten.
+func (c *counter) prune() {
+	byCount := make(map[int]int)
+	for _, n := range c.df {
+		byCount[n]++
+	}
+	counts := make([]int, 0, len(byCount))
+	for n := range byCount {
+		counts = append(counts, n)
+	}
+	sort.Ints(counts)
+
+	// Find the smallest count such that dropping phrases seen at most that often leaves half
+	remaining, cutoff := len(c.df), 0
+	for _, n := range counts {
+		if remaining <= c.maxPhrases/2 {
+			break
+		}
+		remaining -= byCount[n]
+		cutoff = n
+	}
+
+	for phrase, n := range c.df {
+		if n <= cutoff {
+			delete(c.df, phrase)
+		}
+	}
+}
+
+// phrases returns the distinct phrases of a text. Phrases do not span punctuation or words
+// without letters, such as measurements, and do not start or end with a stopword.
+func (c *counter) phrases(text string) map[string]bool {
+	found := make(map[string]bool)
+	for _, sentence := range strings.FieldsFunc(strings.ToLower(text), isSentenceBreak) {
+		var run []string
+		flush := func() {
+			for n := 1; n <= c.maxWords; n++ {
+				for i := 0; i+n <= len(run); i++ {
+					first, last := run[i], run[i+n-1]
+					if stopwords[first] || stopwords[last] || (n == 1 && len([]rune(first)) < 3) {
+						continue
+					}
+					found[strings.Join(run[i:i+n], " ")] = true
+				}
+			}
+			run = run[:0]
+		}
+
+		for _, word := range strings.FieldsFunc(sentence, isWordBreak) {
+			word = strings.Trim(word, "-'")
+			if !strings.ContainsFunc(word, unicode.IsLetter) {
+				flush()
+				continue
+			}
+			run = append(run, word)
+		}
+		flush()
+	}
+	return found
+}
+
+// candidates returns the most significant phrases. A phrase is significant if it appears in many
+// documents, but not in so many that it is boilerplate; it is scored by its document frequency
+// weighted by its inverse document frequency.
+func (c *counter) candidates(opts Options) []Candidate {
+	maxDocs := int(opts.MaxDocRatio * float64(c.docs))
+
+	// Drop phrases that mostly occur as part of a longer phrase
+	subsumed := make(map[string]bool)
+	for phrase, n := range c.df {
+		words := strings.Fields(phrase)
+		if len(words) < 2 || n < opts.MinDocCount {
+			continue
+		}
+		for _, sub := range []string{strings.Join(words[1:], " "), strings.Join(words[:len(words)-1], " ")} {
+			if m, ok := c.df[sub]; ok && float64(n) >= subsumedRatio*float64(m) {
+				subsumed[sub] = true
+			}
+		}
+	}
+
+	var candidates []Candidate
+	for phrase, n := range c.df {
+		if n < opts.MinDocCount || n > maxDocs || subsumed[phrase] {
+			continue
+		}
+		candidates = append(candidates, Candidate{
+			Phrase:   phrase,
+			Words:    len(strings.Fields(phrase)),
+			DocCount: n,
+			Score:    float64(n) * math.Log(float64(c.docs)/float64(n)),
+		})
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].Score != candidates[j].Score {
+			return candidates[i].Score > candidates[j].Score
+		}
+		return candidates[i].Phrase < candidates[j].Phrase
+	})
+	if len(candidates) > opts.MaxCandidates {
+		candidates = candidates[:opts.MaxCandidates]
+	}
+	return candidates
+}
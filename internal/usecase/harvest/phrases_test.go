@@ -0,0 +1,151 @@
+package harvest
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestCounterPhrases(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{
+			name: "phrases up to max words",
+			text: "Small pleural effusion",
+			want: []string{"effusion", "pleural", "pleural effusion", "small", "small pleural", "small pleural effusion"},
+		},
+		{
+			name: "stopwords do not start or end a phrase",
+			text: "effusion of the lung",
+			want: []string{"effusion", "effusion of the lung", "lung"},
+		},
+		{
+			name: "punctuation breaks phrases",
+			text: "Pleural effusion. Lung nodule",
+			want: []string{"effusion", "lung", "lung nodule", "nodule", "pleural", "pleural effusion"},
+		},
+		{
+			name: "measurements break phrases",
+			text: "nodule 12 lesion",
+			want: []string{"lesion", "nodule"},
+		},
+		{
+			name: "short words are not phrases on their own",
+			text: "CT head",
+			want: []string{"ct head", "head"},
+		},
+		{
+			name: "hyphens and apostrophes are kept inside words",
+			text: "Non-contrast 'Crohn's' disease",
+			want: []string{"crohn's", "crohn's disease", "disease", "non-contrast", "non-contrast crohn's", "non-contrast crohn's disease"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for phrase := range newCounter(4).phrases(tt.text) {
+				got = append(got, phrase)
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("phrases(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCounterCandidates(t *testing.T) {
+	tests := []struct {
+		name string
+		docs []string
+		opts Options
+		want []string
+	}{
+		{
+			name: "boilerplate and rare phrases are dropped",
+			docs: []string{"nodule. normal", "nodule. normal", "nodule. normal", "effusion. normal", "normal"},
+			opts: Options{MinDocCount: 2, MaxDocRatio: 0.8},
+			want: []string{"nodule"},
+		},
+		{
+			name: "phrases subsumed by a longer phrase are dropped",
+			docs: []string{"pleural effusion", "pleural effusion", "pleural effusion", "effusion", "nodule"},
+			opts: Options{MinDocCount: 2, MaxDocRatio: 0.8},
+			want: []string{"pleural effusion", "effusion"},
+		},
+		{
+			name: "most significant candidates are kept",
+			docs: []string{"nodule", "nodule", "nodule", "mass", "mass", "cyst", "cyst", "normal", "normal", "normal"},
+			opts: Options{MinDocCount: 2, MaxDocRatio: 0.5, MaxCandidates: 2},
+			want: []string{"nodule", "normal"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newCounter(3)
+			for _, doc := range tt.docs {
+				c.add(doc)
+			}
+
+			var got []string
+			for _, candidate := range c.candidates(tt.opts.withDefaults()) {
+				got = append(got, candidate.Phrase)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("candidates = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCounterPrune(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxPhrases int
+		docs       []string
+		want       map[string]int
+	}{
+		{
+			name:       "under the cap",
+			maxPhrases: 4,
+			docs:       []string{"nodule", "nodule", "mass", "cyst"},
+			want:       map[string]int{"nodule": 2, "mass": 1, "cyst": 1},
+		},
+		{
+			name:       "phrases seen once are forgotten",
+			maxPhrases: 4,
+			docs:       []string{"nodule", "nodule", "mass", "mass", "cyst", "lesion", "polyp"},
+			want:       map[string]int{"nodule": 2, "mass": 2},
+		},
+		{
+			name:       "rarest phrases are forgotten until half remain",
+			maxPhrases: 4,
+			docs:       []string{"nodule", "nodule", "nodule", "mass", "mass", "mass", "cyst", "cyst", "lesion", "lesion", "polyp"},
+			want:       map[string]int{"nodule": 3, "mass": 3},
+		},
+		{
+			name:       "new phrases are counted after a prune",
+			maxPhrases: 4,
+			docs:       []string{"nodule", "nodule", "mass", "mass", "cyst", "lesion", "polyp", "polyp", "cyst"},
+			want:       map[string]int{"nodule": 2, "mass": 2, "polyp": 1, "cyst": 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newCounter(1)
+			c.maxPhrases = tt.maxPhrases
+			for _, doc := range tt.docs {
+				c.add(doc)
+			}
+			if !reflect.DeepEqual(c.df, tt.want) {
+				t.Errorf("df = %v, want %v", c.df, tt.want)
+			}
+		})
+	}
+}
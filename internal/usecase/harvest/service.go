@@ -0,0 +1,364 @@
+// Package harvest mines frequent phrases from indexed report text and proposes them as
+// completion terms, so that the suggestions grow with the reports without manual curation.
+package harvest
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8"
+	"github.com/yangszwei/koala/internal/domain"
+	"github.com/yangszwei/koala/internal/usecase/completion"
+	"github.com/yangszwei/koala/internal/usecase/search"
+	"github.com/yangszwei/koala/pkg/elasticutil"
+)
+
+// Service defines the operations on harvested candidate terms.
+type Service interface {
+	// Harvest samples the most recent reports and proposes their significant phrases that are not
+	// terms yet. Phrases found often enough are added as terms right away; others are queued for review.
+	Harvest(ctx context.Context) (Result, error)
+	// List returns the candidates in the given state, or in any state if it is empty, most
+	// significant first, and the total number of such candidates.
+	List(ctx context.Context, state State, offset, limit int) ([]Candidate, int, error)
+	// Approve adds a candidate as a completion term. Fields set in term override the defaults
+	// derived from the candidate.
+	Approve(ctx context.Context, id string, term domain.CompletionTerm) (domain.CompletionTerm, error)
+	// Reject marks a candidate so that it is not proposed again.
+	Reject(ctx context.Context, id string) error
+}
+
+const indexName = "terms_candidates"
+
+// errStopScan ends the report scan once enough documents have been sampled.
+var errStopScan = errors.New("enough documents sampled")
+
+// service implements the candidate operations using Elasticsearch.
+type service struct {
+	es    *elasticsearch.Client
+	docs  search.Service
+	terms completion.Service
+	opts  Options
+}
+
+// NewService returns a new instance of the harvest Service, which samples reports from docs and
+// adds approved candidates to terms.
+func NewService(es *elasticsearch.Client, docs search.Service, terms completion.Service, opts Options) Service {
+	return &service{es: es, docs: docs, terms: terms, opts: opts.withDefaults()}
+}
+
+// CandidateID returns the candidate ID of a phrase. It is a hash so it can be used in URL paths.
+func CandidateID(phrase string) string {
+	sum := sha1.Sum([]byte(phrase))
+	return hex.EncodeToString(sum[:])
+}
+
+// Harvest counts the phrases of the sampled reports and stores the most significant new ones.
+// Candidates that were already reviewed keep their state and are not proposed again.
+func (s *service) Harvest(ctx context.Context) (Result, error) {
+	counter := newCounter(s.opts.MaxWords)
+	err := s.docs.ScanReports(ctx, 500, func(docs []search.Document) error {
+		for _, doc := range docs {
+			counter.add(doc.ReportText + "\n" + doc.Impression)
+			if counter.docs >= s.opts.MaxDocuments {
+				return errStopScan
+			}
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errStopScan) {
+		return Result{}, fmt.Errorf("scan reports: %w", err)
+	}
+
+	result := Result{Documents: counter.docs}
+	now := time.Now().UTC()
+	candidates := counter.candidates(s.opts)
+	if len(candidates) == 0 {
+		result.Expired, err = s.expire(ctx, now)
+		return result, err
+	}
+
+	phrases := make([]string, len(candidates))
+	for i, c := range candidates {
+		phrases[i] = c.Phrase
+	}
+	known, err := s.terms.Known(ctx, phrases)
+	if err != nil {
+		return result, fmt.Errorf("look up terms: %w", err)
+	}
+
+	ids := make([]string, len(candidates))
+	for i, c := range candidates {
+		ids[i] = CandidateID(c.Phrase)
+	}
+	existing, err := s.getAll(ctx, ids)
+	if err != nil {
+		return result, err
+	}
+
+	ch := make(chan Candidate, len(candidates))
+	for i, c := range candidates {
+		c.ID = ids[i]
+		c.State = StatePending
+		c.CreatedAt = now
+		c.UpdatedAt = now
+		if prev, ok := existing[c.ID]; ok {
+			if prev.State != StatePending {
+				continue
+			}
+			c.CreatedAt = prev.CreatedAt
+		}
+		if known[c.Phrase] {
+			continue
+		}
+
+		if s.opts.AutoAddMinDocs > 0 && c.DocCount >= s.opts.AutoAddMinDocs {
+			term := domain.NewCompletionTerm(c.Phrase)
+			term.Source = Source
+			if err := s.terms.Save(ctx, term); err != nil {
+				return result, fmt.Errorf("add term %q: %w", c.Phrase, err)
+			}
+			c.State = StateAdded
+			result.Added++
+		} else {
+			result.Proposed++
+		}
+		ch <- c
+	}
+	close(ch)
+
+	_, err = elasticutil.BulkInsertChan(ctx, s.es, indexName, ch, func(c Candidate) string {
+		return c.ID
+	}, 1000)
+	if err != nil {
+		return result, fmt.Errorf("store candidates: %w", err)
+	}
+
+	result.Expired, err = s.expire(ctx, now)
+	return result, err
+}
+
+// expire deletes the pending candidates that were not proposed again since a harvest run started,
+// as the phrases are no longer significant in the most recent reports.
+func (s *service) expire(ctx context.Context, since time.Time) (int, error) {
+	data, err := json.Marshal(map[string]interface{}{
+		"query": map[string]interface{}{
+			"bool": map[string]interface{}{
+				"filter": []interface{}{
+					map[string]interface{}{"term": map[string]interface{}{"state": StatePending}},
+					map[string]interface{}{"range": map[string]interface{}{"updatedAt": map[string]interface{}{"lt": since}}},
+				},
+			},
+		},
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := s.es.DeleteByQuery(
+		[]string{indexName},
+		bytes.NewReader(data),
+		s.es.DeleteByQuery.WithContext(ctx),
+		s.es.DeleteByQuery.WithRefresh(true),
+		s.es.DeleteByQuery.WithConflicts("proceed"),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("expire candidates request: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return 0, fmt.Errorf("expire candidates error: %s", res.String())
+	}
+
+	var parsed struct {
+		Deleted int `json:"deleted"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&parsed); err != nil {
+		return 0, fmt.Errorf("decode expire candidates response: %w", err)
+	}
+	return parsed.Deleted, nil
+}
+
+// List returns the candidates in a state, most significant first.
+func (s *service) List(ctx context.Context, state State, offset, limit int) ([]Candidate, int, error) {
+	query := map[string]interface{}{"match_all": map[string]interface{}{}}
+	if state != "" {
+		query = map[string]interface{}{"term": map[string]interface{}{"state": state}}
+	}
+
+	data, err := json.Marshal(map[string]interface{}{"query": query})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	res, err := s.es.Search(
+		s.es.Search.WithContext(ctx),
+		s.es.Search.WithIndex(indexName),
+		s.es.Search.WithBody(bytes.NewReader(data)),
+		s.es.Search.WithSort("score:desc", "phrase"),
+		s.es.Search.WithFrom(offset),
+		s.es.Search.WithSize(limit),
+		s.es.Search.WithTrackTotalHits(true),
+	)
+	if err != nil {
+		return nil, 0, fmt.Errorf("search candidates request: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return nil, 0, fmt.Errorf("search candidates error: %s", res.String())
+	}
+
+	var parsed struct {
+		Hits struct {
+			Total struct {
+				Value int `json:"value"`
+			} `json:"total"`
+			Hits []struct {
+				Source Candidate `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&parsed); err != nil {
+		return nil, 0, fmt.Errorf("decode candidates: %w", err)
+	}
+
+	candidates := make([]Candidate, 0, len(parsed.Hits.Hits))
+	for _, hit := range parsed.Hits.Hits {
+		candidates = append(candidates, hit.Source)
+	}
+	return candidates, parsed.Hits.Total.Value, nil
+}
+
+// Approve adds the phrase of a candidate as a completion term and marks the candidate approved.
+func (s *service) Approve(ctx context.Context, id string, term domain.CompletionTerm) (domain.CompletionTerm, error) {
+	candidate, err := s.get(ctx, id)
+	if err != nil {
+		return domain.CompletionTerm{}, err
+	}
+
+	if term.Term == "" {
+		term.Term = candidate.Phrase
+	}
+	if term.Source == "" {
+		term.Source = Source
+	}
+	term.Stats = nil
+	term.Normalize()
+
+	if err := s.terms.Save(ctx, term); err != nil {
+		return domain.CompletionTerm{}, fmt.Errorf("add term: %w", err)
+	}
+
+	candidate.State = StateApproved
+	candidate.UpdatedAt = time.Now().UTC()
+	return term, s.put(ctx, candidate)
+}
+
+// Reject marks a candidate rejected.
+func (s *service) Reject(ctx context.Context, id string) error {
+	candidate, err := s.get(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	candidate.State = StateRejected
+	candidate.UpdatedAt = time.Now().UTC()
+	return s.put(ctx, candidate)
+}
+
+// get reads a candidate by ID.
+func (s *service) get(ctx context.Context, id string) (*Candidate, error) {
+	res, err := s.es.Get(indexName, id, s.es.Get.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("get candidate request: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == 404 {
+		return nil, ErrNotFound
+	}
+	if res.IsError() {
+		return nil, fmt.Errorf("get candidate error: %s", res.String())
+	}
+
+	var parsed struct {
+		Source Candidate `json:"_source"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&parsed); err != nil {
+		return nil, fmt.Errorf("decode candidate: %w", err)
+	}
+	return &parsed.Source, nil
+}
+
+// getAll reads the candidates with the given IDs that exist, by ID.
+func (s *service) getAll(ctx context.Context, ids []string) (map[string]Candidate, error) {
+	data, err := json.Marshal(map[string]interface{}{"ids": ids})
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := s.es.Mget(
+		bytes.NewReader(data),
+		s.es.Mget.WithIndex(indexName),
+		s.es.Mget.WithContext(ctx),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("get candidates request: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return nil, fmt.Errorf("get candidates error: %s", res.String())
+	}
+
+	var parsed struct {
+		Docs []struct {
+			Found  bool      `json:"found"`
+			Source Candidate `json:"_source"`
+		} `json:"docs"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&parsed); err != nil {
+		return nil, fmt.Errorf("decode candidates: %w", err)
+	}
+
+	candidates := make(map[string]Candidate)
+	for _, doc := range parsed.Docs {
+		if doc.Found {
+			candidates[doc.Source.ID] = doc.Source
+		}
+	}
+	return candidates, nil
+}
+
+// put stores a candidate, replacing any previous version.
+func (s *service) put(ctx context.Context, candidate *Candidate) error {
+	data, err := json.Marshal(candidate)
+	if err != nil {
+		return fmt.Errorf("marshal candidate: %w", err)
+	}
+
+	res, err := s.es.Index(
+		indexName,
+		bytes.NewReader(data),
+		s.es.Index.WithDocumentID(candidate.ID),
+		s.es.Index.WithRefresh("wait_for"),
+		s.es.Index.WithContext(ctx),
+	)
+	if err != nil {
+		return fmt.Errorf("index candidate request: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return fmt.Errorf("index candidate error: %s", res.String())
+	}
+	return nil
+}
@@ -0,0 +1,332 @@
+package importer
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yangszwei/koala/internal/usecase/search"
+	"github.com/yangszwei/koala/pkg/iox"
+)
+
+// Format identifies the encoding of an import file.
+type Format string
+
+const (
+	FormatCSV    Format = "csv"
+	FormatNDJSON Format = "ndjson"
+)
+
+// ParseFormat returns the Format for a format name or file extension (e.g., "csv", ".jsonl").
+func ParseFormat(s string) (Format, error) {
+	switch strings.ToLower(strings.TrimPrefix(s, ".")) {
+	case "csv":
+		return FormatCSV, nil
+	case "ndjson", "jsonl":
+		return FormatNDJSON, nil
+	default:
+		return "", fmt.Errorf("%w: unsupported format %s", ErrInvalidInput, s)
+	}
+}
+
+// ErrInvalidInput is returned when an import file or its mapping cannot be used at all.
+var ErrInvalidInput = errors.New("invalid import input")
+
+// fields lists the document fields that can be imported, keyed by their lowercased name.
+var fields = map[string]string{
+	"id":                 "id",
+	"type":               "type",
+	"studydate":          "studyDate",
+	"modality":           "modality",
+	"patientid":          "patientId",
+	"patientname":        "patientName",
+	"gender":             "gender",
+	"categories":         "categories",
+	"reporttext":         "reportText",
+	"impression":         "impression",
+	"status":             "status",
+	"codes":              "codes",
+	"codedisplay":        "codeDisplay",
+	"performers":         "performers",
+	"accessionnumbers":   "accessionNumbers",
+	"studydescription":   "studyDescription",
+	"referringphysician": "referringPhysician",
+	"institution":        "institution",
+	"bodyparts":          "bodyParts",
+}
+
+// dateLayouts lists the accepted study date formats.
+var dateLayouts = []string{"2006-01-02", "20060102", "2006/01/02", "2006.01.02", time.RFC3339}
+
+// RowError describes why a row, or one of its fields, could not be imported.
+type RowError struct {
+	Row   int    `json:"row"`
+	Field string `json:"field,omitempty"`
+	Error string `json:"error"`
+}
+
+// Row is a single decoded record of an import file.
+type Row struct {
+	Number   int // 1-based record number, excluding the CSV header
+	Document search.Document
+	Errors   []RowError // validation errors; the document must not be indexed if any
+}
+
+// Decoder reads documents from a CSV or NDJSON import file.
+type Decoder struct {
+	format  Format
+	mapping map[string]string // document field -> column or key
+	csv     *csv.Reader
+	columns map[string]int // lowercased CSV header -> column index
+	lines   *bufio.Scanner
+	row     int
+}
+
+// NewDecoder returns a decoder for the given format. The mapping maps document fields to CSV
+// columns or NDJSON keys; unmapped fields are read from a column or key with the field's own
+// name. Field and column names are matched case-insensitively.
+func NewDecoder(r io.Reader, format Format, mapping map[string]string) (*Decoder, error) {
+	d := &Decoder{format: format, mapping: make(map[string]string)}
+
+	for field, column := range mapping {
+		name, ok := fields[strings.ToLower(field)]
+		if !ok {
+			return nil, fmt.Errorf("%w: unsupported mapping field %s", ErrInvalidInput, field)
+		}
+		d.mapping[name] = column
+	}
+	for _, name := range fields {
+		if _, ok := d.mapping[name]; !ok {
+			d.mapping[name] = name
+		}
+	}
+
+	r = iox.StripBOM(r)
+
+	switch format {
+	case FormatCSV:
+		d.csv = csv.NewReader(r)
+		d.csv.FieldsPerRecord = -1
+		headers, err := d.csv.Read()
+		if err != nil {
+			return nil, fmt.Errorf("%w: failed to read CSV headers: %v", ErrInvalidInput, err)
+		}
+		d.columns = make(map[string]int, len(headers))
+		for i, h := range headers {
+			d.columns[strings.ToLower(strings.TrimSpace(h))] = i
+		}
+	case FormatNDJSON:
+		d.lines = bufio.NewScanner(r)
+		d.lines.Buffer(make([]byte, 0, 64*1024), 16<<20)
+	default:
+		return nil, fmt.Errorf("%w: unsupported format %s", ErrInvalidInput, format)
+	}
+
+	return d, nil
+}
+
+// Next returns the next row. It returns io.EOF when there are no more rows. Rows that cannot be
+// read at all are returned with an error in Row.Errors rather than as an error.
+func (d *Decoder) Next() (Row, error) {
+	values, err := d.nextValues()
+	if err != nil {
+		return Row{}, err
+	}
+	d.row++
+
+	row := Row{Number: d.row}
+	if values == nil {
+		row.Errors = append(row.Errors, RowError{Row: d.row, Error: "malformed record"})
+		return row, nil
+	}
+
+	row.Document, row.Errors = d.toDocument(d.row, values)
+	return row, nil
+}
+
+// nextValues reads the next record as a map of lowercased column or key names to values.
+// A nil map with a nil error denotes a malformed record.
+func (d *Decoder) nextValues() (map[string][]string, error) {
+	if d.format == FormatCSV {
+		record, err := d.csv.Read()
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		if err != nil {
+			if _, ok := err.(*csv.ParseError); ok {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("failed to read record: %w", err)
+		}
+		values := make(map[string][]string, len(d.columns))
+		for name, i := range d.columns {
+			if i < len(record) {
+				values[name] = []string{record[i]}
+			}
+		}
+		return values, nil
+	}
+
+	for d.lines.Scan() {
+		line := strings.TrimSpace(d.lines.Text())
+		if line == "" {
+			continue
+		}
+		var obj map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &obj); err != nil {
+			return nil, nil
+		}
+		values := make(map[string][]string, len(obj))
+		for key, v := range obj {
+			values[strings.ToLower(key)] = jsonStrings(v)
+		}
+		return values, nil
+	}
+	if err := d.lines.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read record: %w", err)
+	}
+	return nil, io.EOF
+}
+
+// toDocument maps and validates a record.
+func (d *Decoder) toDocument(row int, values map[string][]string) (search.Document, []RowError) {
+	var errs []RowError
+	get := func(field string) []string {
+		return values[strings.ToLower(d.mapping[field])]
+	}
+	first := func(field string) string {
+		if v := get(field); len(v) > 0 {
+			return strings.TrimSpace(v[0])
+		}
+		return ""
+	}
+	list := func(field string) []string {
+		var out []string
+		for _, v := range get(field) {
+			for _, item := range strings.Split(v, ";") {
+				if item = strings.TrimSpace(item); item != "" {
+					out = append(out, item)
+				}
+			}
+		}
+		return out
+	}
+
+	doc := search.Document{
+		ID:                 first("id"),
+		Type:               first("type"),
+		Modality:           first("modality"),
+		PatientID:          first("patientId"),
+		PatientName:        first("patientName"),
+		Categories:         list("categories"),
+		ReportText:         first("reportText"),
+		Impression:         first("impression"),
+		Status:             first("status"),
+		Codes:              list("codes"),
+		CodeDisplay:        first("codeDisplay"),
+		Performers:         list("performers"),
+		AccessionNumbers:   list("accessionNumbers"),
+		StudyDescription:   first("studyDescription"),
+		ReferringPhysician: first("referringPhysician"),
+		Institution:        first("institution"),
+		BodyParts:          list("bodyParts"),
+	}
+
+	switch doc.Type {
+	case "":
+		doc.Type = "report"
+	case "image", "report", "report_image":
+	default:
+		errs = append(errs, RowError{Row: row, Field: "type", Error: fmt.Sprintf("invalid type %q", doc.Type)})
+	}
+
+	if raw := first("studyDate"); raw != "" {
+		if date, ok := parseDate(raw); ok {
+			doc.StudyDate = date
+		} else {
+			errs = append(errs, RowError{Row: row, Field: "studyDate", Error: fmt.Sprintf("invalid date %q", raw)})
+		}
+	}
+
+	if raw := first("gender"); raw != "" {
+		if gender, ok := parseGender(raw); ok {
+			doc.Gender = gender
+		} else {
+			errs = append(errs, RowError{Row: row, Field: "gender", Error: fmt.Sprintf("invalid gender %q", raw)})
+		}
+	}
+
+	if doc.Categories == nil {
+		doc.Categories = []string{}
+	}
+	if doc.ReportText == "" && doc.Impression == "" && doc.Type != "image" {
+		errs = append(errs, RowError{Row: row, Field: "reportText", Error: "report has neither text nor impression"})
+	}
+
+	return doc, errs
+}
+
+// parseDate normalizes a study date to YYYY-MM-DD.
+func parseDate(s string) (string, bool) {
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.Format("2006-01-02"), true
+		}
+	}
+	return "", false
+}
+
+// parseGender normalizes gender values and DICOM/HL7 sex codes to "male", "female", "other" or "unknown".
+func parseGender(s string) (string, bool) {
+	switch strings.ToLower(s) {
+	case "m", "male":
+		return "male", true
+	case "f", "female":
+		return "female", true
+	case "o", "other":
+		return "other", true
+	case "u", "unknown":
+		return "unknown", true
+	default:
+		return "", false
+	}
+}
+
+// ContentID derives a stable identifier from the content of a document without an ID column,
+// so that importing the same file twice does not create duplicates.
+func ContentID(doc search.Document) string {
+	h := sha1.New()
+	for _, part := range []string{doc.Type, doc.PatientID, doc.StudyDate, doc.Modality, doc.ReportText, doc.Impression} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))[:20]
+}
+
+// jsonStrings converts an NDJSON value to a list of strings.
+func jsonStrings(v interface{}) []string {
+	switch val := v.(type) {
+	case nil:
+		return nil
+	case string:
+		return []string{val}
+	case []interface{}:
+		var out []string
+		for _, item := range val {
+			out = append(out, jsonStrings(item)...)
+		}
+		return out
+	case float64:
+		return []string{strconv.FormatFloat(val, 'f', -1, 64)}
+	default:
+		return []string{fmt.Sprint(val)}
+	}
+}
@@ -0,0 +1,104 @@
+// Package importer imports search documents from CSV and NDJSON files.
+package importer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/yangszwei/koala/internal/usecase/search"
+	"github.com/yangszwei/koala/pkg/elasticutil"
+)
+
+// Report summarizes the outcome of an import.
+type Report struct {
+	Total   int        `json:"total"`   // Number of rows read
+	Indexed int        `json:"indexed"` // Number of rows the search backend accepted
+	Errors  []RowError `json:"errors"`  // Validation errors of the rows that were skipped
+
+	Rejected     int    `json:"rejected"`               // Number of valid rows the search backend rejected
+	RejectReason string `json:"rejectReason,omitempty"` // Why the first rejected row was rejected
+}
+
+// Service defines file import operations.
+type Service interface {
+	// Import validates the rows of a CSV or NDJSON file and bulk indexes the valid ones.
+	Import(ctx context.Context, r io.Reader, format Format, mapping map[string]string) (*Report, error)
+}
+
+// service implements the import operations on top of the search service.
+type service struct {
+	search search.Service
+}
+
+// NewService returns a new instance of the import Service.
+func NewService(search search.Service) Service {
+	return &service{search: search}
+}
+
+// Import reads rows from r, collects validation errors per row, and bulk indexes all valid rows.
+// Documents are stored under "<type>:import:<id>", where the ID is taken from the ID column or
+// derived from the content, so that imported rows never replace documents of a data source.
+func (s *service) Import(ctx context.Context, r io.Reader, format Format, mapping map[string]string) (*Report, error) {
+	decoder, err := NewDecoder(r, format, mapping)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{Errors: []RowError{}}
+	ch := make(chan search.Document, 500)
+	errCh := make(chan error, 1)
+
+	go func() {
+		defer close(ch)
+		defer close(errCh)
+		for {
+			row, err := decoder.Next()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				errCh <- err
+				return
+			}
+
+			report.Total++
+			if len(row.Errors) > 0 {
+				report.Errors = append(report.Errors, row.Errors...)
+				continue
+			}
+
+			doc := row.Document
+			id := doc.ID
+			if id == "" {
+				id = ContentID(doc)
+			}
+			doc.ID = fmt.Sprintf("%s:import:%s", doc.Type, id)
+
+			select {
+			case <-ctx.Done():
+				return
+			case ch <- doc:
+			}
+		}
+	}()
+
+	indexed, err := s.search.IndexBatch(ctx, ch)
+	var bulkErr *elasticutil.BulkError
+	if errors.As(err, &bulkErr) {
+		report.Rejected, report.RejectReason = bulkErr.Failed, bulkErr.Reason
+	} else if err != nil {
+		// Drain the decoder so that it does not block forever
+		for range ch {
+		}
+		return nil, fmt.Errorf("import failed: %w", err)
+	}
+	report.Indexed = indexed
+
+	if readErr := <-errCh; readErr != nil {
+		return nil, readErr
+	}
+
+	return report, nil
+}
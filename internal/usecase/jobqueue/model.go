@@ -0,0 +1,70 @@
+package jobqueue
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// State is the state of a failed indexing job.
+type State string
+
+const (
+	// StatePending jobs are retried once their next attempt time has passed.
+	StatePending State = "pending"
+	// StateDead jobs have failed MaxAttempts times and are kept until retried or discarded.
+	StateDead State = "dead"
+)
+
+const (
+	// MaxAttempts is the number of failed attempts after which a job is dead-lettered.
+	MaxAttempts = 8
+	// baseBackoff is the delay before the first retry; it doubles with every failed attempt.
+	baseBackoff = time.Minute
+	// maxBackoff caps the delay between retries.
+	maxBackoff = 6 * time.Hour
+)
+
+// Job records an item of a data source that failed to be fetched and indexed, or whose document
+// failed to be removed.
+type Job struct {
+	ID          string          `json:"id"`            // Opaque job ID derived from the document ID
+	DocID       string          `json:"docId"`         // Document ID in the search index (type:source:id)
+	Source      string          `json:"source"`        // Data source name
+	Type        string          `json:"type"`          // Item type, e.g., study or report
+	ItemID      string          `json:"itemId"`        // Item ID in the data source
+	Delete      bool            `json:"delete"`        // Whether the document is removed rather than indexed
+	State       State           `json:"state"`         // pending | dead
+	Attempts    int             `json:"attempts"`      // Number of failed attempts
+	LastError   string          `json:"lastError"`     // Error of the last failed attempt
+	NextAttempt time.Time       `json:"nextAttempt"`   // When the job is retried next
+	CreatedAt   time.Time       `json:"createdAt"`     // When the job first failed
+	UpdatedAt   time.Time       `json:"updatedAt"`     // When the job last changed
+	Raw         json.RawMessage `json:"raw,omitempty"` // Serialized context of items that cannot be fetched by ID
+}
+
+// Failure describes a failed attempt to fetch and index an item, or to remove its document.
+type Failure struct {
+	Source string
+	Type   string
+	ItemID string
+	Delete bool            // Whether the document was being removed rather than indexed
+	Raw    json.RawMessage // Serialized context needed to fetch the item again, if any
+	Err    error
+}
+
+// DocID builds the document ID of the failed item, matching datasource.DataSummary.DocID.
+func (f Failure) DocID() string {
+	return f.Type + ":" + f.Source + ":" + f.ItemID
+}
+
+// backoff returns the delay before the next attempt after the given number of failed attempts.
+func backoff(attempts int) time.Duration {
+	d := baseBackoff
+	for i := 1; i < attempts && d < maxBackoff; i++ {
+		d *= 2
+	}
+	if d > maxBackoff {
+		d = maxBackoff
+	}
+	return d
+}
@@ -0,0 +1,317 @@
+// Package jobqueue records indexing jobs that failed, so they can be retried with exponential
+// backoff and dead-lettered after too many attempts.
+package jobqueue
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8"
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+)
+
+// ErrNotFound is returned when a job does not exist.
+var ErrNotFound = errors.New("job not found")
+
+// Service defines the operations of the durable indexing job queue.
+type Service interface {
+	// Fail records a failed attempt. The job is created if needed, its attempt count increased,
+	// and it is dead-lettered once MaxAttempts is reached.
+	Fail(ctx context.Context, failure Failure) error
+	// Due returns up to limit pending jobs whose next attempt time has passed.
+	Due(ctx context.Context, limit int) ([]Job, error)
+	// Complete removes a job after it has been retried successfully.
+	Complete(ctx context.Context, id string) error
+	// Resolve removes the job of a document, if there is one, after its item was indexed or
+	// deleted by other means, so that a pending retry does not replace it with an older version.
+	Resolve(ctx context.Context, docID string) error
+	// List returns jobs in the given state (all states if empty) and the total number of matches.
+	List(ctx context.Context, state State, offset, limit int) ([]Job, int, error)
+	// Retry resets a job so that it is attempted again immediately.
+	Retry(ctx context.Context, id string) error
+	// Discard removes a job without retrying it.
+	Discard(ctx context.Context, id string) error
+}
+
+const indexName = "indexing_jobs"
+
+// maxUpdateAttempts is how often a job is read and written again after concurrent updates.
+const maxUpdateAttempts = 5
+
+// service implements the job queue using an Elasticsearch index.
+type service struct {
+	es *elasticsearch.Client
+}
+
+// NewService returns a new instance of the job queue Service.
+func NewService(es *elasticsearch.Client) Service {
+	return &service{es: es}
+}
+
+// JobID returns the job ID of a document ID. It is a hash so it can be used in URL paths.
+func JobID(docID string) string {
+	sum := sha1.Sum([]byte(docID))
+	return hex.EncodeToString(sum[:])
+}
+
+// Fail records a failed attempt of the item described by failure.
+func (s *service) Fail(ctx context.Context, failure Failure) error {
+	docID := failure.DocID()
+	id := JobID(docID)
+
+	return s.update(ctx, id, func(job *Job) (*Job, error) {
+		now := time.Now().UTC()
+		if job == nil {
+			job = &Job{ID: id, DocID: docID, CreatedAt: now}
+		}
+
+		job.Source = failure.Source
+		job.Type = failure.Type
+		job.ItemID = failure.ItemID
+		job.Delete = failure.Delete
+		if failure.Raw != nil {
+			job.Raw = failure.Raw // Failures without a context, e.g., of a retry, keep the stored one
+		}
+		job.Attempts++
+		job.UpdatedAt = now
+		if failure.Err != nil {
+			job.LastError = failure.Err.Error()
+		}
+
+		if job.Attempts >= MaxAttempts {
+			job.State = StateDead
+			job.NextAttempt = time.Time{}
+		} else {
+			job.State = StatePending
+			job.NextAttempt = now.Add(backoff(job.Attempts))
+		}
+		return job, nil
+	})
+}
+
+// Due returns pending jobs that are ready to be retried, oldest first.
+func (s *service) Due(ctx context.Context, limit int) ([]Job, error) {
+	query := map[string]interface{}{
+		"bool": map[string]interface{}{
+			"filter": []interface{}{
+				map[string]interface{}{"term": map[string]interface{}{"state": StatePending}},
+				map[string]interface{}{"range": map[string]interface{}{"nextAttempt": map[string]interface{}{"lte": "now"}}},
+			},
+		},
+	}
+	jobs, _, err := s.search(ctx, query, "nextAttempt", 0, limit)
+	return jobs, err
+}
+
+// Complete deletes the job with the given ID.
+func (s *service) Complete(ctx context.Context, id string) error {
+	return s.delete(ctx, id, "wait_for")
+}
+
+// Resolve deletes the job of a document without waiting for a refresh, since it is called for
+// every indexed item and there usually is no job.
+func (s *service) Resolve(ctx context.Context, docID string) error {
+	return s.delete(ctx, JobID(docID), "false")
+}
+
+// List returns jobs in the given state, most recently updated first.
+func (s *service) List(ctx context.Context, state State, offset, limit int) ([]Job, int, error) {
+	query := map[string]interface{}{"match_all": map[string]interface{}{}}
+	if state != "" {
+		query = map[string]interface{}{"term": map[string]interface{}{"state": state}}
+	}
+	return s.search(ctx, query, "updatedAt:desc", offset, limit)
+}
+
+// Retry moves the job back to the pending state with a fresh attempt count.
+func (s *service) Retry(ctx context.Context, id string) error {
+	return s.update(ctx, id, func(job *Job) (*Job, error) {
+		if job == nil {
+			return nil, ErrNotFound
+		}
+
+		now := time.Now().UTC()
+		job.State = StatePending
+		job.Attempts = 0
+		job.NextAttempt = now
+		job.UpdatedAt = now
+		return job, nil
+	})
+}
+
+// Discard deletes the job with the given ID.
+func (s *service) Discard(ctx context.Context, id string) error {
+	if _, err := s.get(ctx, id); err != nil {
+		return err
+	}
+	return s.delete(ctx, id, "wait_for")
+}
+
+// storedJob is a job together with the version it was read at.
+type storedJob struct {
+	Job         Job `json:"_source"`
+	SeqNo       int `json:"_seq_no"`
+	PrimaryTerm int `json:"_primary_term"`
+}
+
+// update reads the job with the given ID, changes it with fn, which receives nil if there is no
+// job, and writes it back. Writes are conditional on the version that was read, so that concurrent
+// failures of the same item each count as an attempt, and a job removed in the meantime is not
+// silently recreated from its old state; on a conflict the job is read and changed again.
+func (s *service) update(ctx context.Context, id string, fn func(*Job) (*Job, error)) error {
+	for attempt := 0; attempt < maxUpdateAttempts; attempt++ {
+		current, err := s.get(ctx, id)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return err
+		}
+
+		var job *Job
+		if current != nil {
+			job = &current.Job
+		}
+		job, err = fn(job)
+		if err != nil {
+			return err
+		}
+
+		ok, err := s.put(ctx, job, current)
+		if err != nil {
+			return err
+		}
+		if ok {
+			return nil
+		}
+	}
+	return fmt.Errorf("update job %s: too many concurrent updates", id)
+}
+
+// get reads a job by ID.
+func (s *service) get(ctx context.Context, id string) (*storedJob, error) {
+	res, err := s.es.Get(indexName, id, s.es.Get.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("get job request: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	if res.IsError() {
+		return nil, fmt.Errorf("get job error: %s", res.String())
+	}
+
+	var current storedJob
+	if err := json.NewDecoder(res.Body).Decode(&current); err != nil {
+		return nil, fmt.Errorf("decode job: %w", err)
+	}
+	return &current, nil
+}
+
+// put writes a job. It creates the document if previous is nil, and otherwise only replaces the
+// version that was read. It returns false if the job was written or removed in the meantime.
+func (s *service) put(ctx context.Context, job *Job, previous *storedJob) (bool, error) {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return false, fmt.Errorf("marshal job: %w", err)
+	}
+
+	opts := []func(*esapi.IndexRequest){
+		s.es.Index.WithDocumentID(job.ID),
+		s.es.Index.WithRefresh("wait_for"),
+		s.es.Index.WithContext(ctx),
+	}
+	if previous == nil {
+		opts = append(opts, s.es.Index.WithOpType("create"))
+	} else {
+		opts = append(opts,
+			s.es.Index.WithIfSeqNo(previous.SeqNo),
+			s.es.Index.WithIfPrimaryTerm(previous.PrimaryTerm),
+		)
+	}
+
+	res, err := s.es.Index(indexName, bytes.NewReader(data), opts...)
+	if err != nil {
+		return false, fmt.Errorf("index job request: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusConflict {
+		return false, nil
+	}
+	if res.IsError() {
+		return false, fmt.Errorf("index job error: %s", res.String())
+	}
+	return true, nil
+}
+
+// delete removes a job by ID with the given refresh policy. Deleting a missing job is not an error.
+func (s *service) delete(ctx context.Context, id, refresh string) error {
+	res, err := s.es.Delete(
+		indexName,
+		id,
+		s.es.Delete.WithRefresh(refresh),
+		s.es.Delete.WithContext(ctx),
+	)
+	if err != nil {
+		return fmt.Errorf("delete job request: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() && res.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("delete job error: %s", res.String())
+	}
+	return nil
+}
+
+// search runs a query against the job index and returns the matching jobs and their total count.
+func (s *service) search(ctx context.Context, query map[string]interface{}, sort string, offset, limit int) ([]Job, int, error) {
+	data, err := json.Marshal(map[string]interface{}{"query": query})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	res, err := s.es.Search(
+		s.es.Search.WithContext(ctx),
+		s.es.Search.WithIndex(indexName),
+		s.es.Search.WithBody(bytes.NewReader(data)),
+		s.es.Search.WithSort(sort),
+		s.es.Search.WithFrom(offset),
+		s.es.Search.WithSize(limit),
+		s.es.Search.WithTrackTotalHits(true),
+	)
+	if err != nil {
+		return nil, 0, fmt.Errorf("search jobs request: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return nil, 0, fmt.Errorf("search jobs error: %s", res.String())
+	}
+
+	var parsed struct {
+		Hits struct {
+			Total struct {
+				Value int `json:"value"`
+			} `json:"total"`
+			Hits []struct {
+				Source Job `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&parsed); err != nil {
+		return nil, 0, fmt.Errorf("decode jobs: %w", err)
+	}
+
+	jobs := make([]Job, 0, len(parsed.Hits.Hits))
+	for _, hit := range parsed.Hits.Hits {
+		jobs = append(jobs, hit.Source)
+	}
+	return jobs, parsed.Hits.Total.Value, nil
+}
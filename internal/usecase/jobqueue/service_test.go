@@ -0,0 +1,116 @@
+package jobqueue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/elastic/go-elasticsearch/v8"
+)
+
+// jobStore is a fake Elasticsearch job index with versioned documents. Before the first write,
+// interfere may change the stored job like a concurrent writer.
+type jobStore struct {
+	mu        sync.Mutex
+	job       *Job
+	seqNo     int
+	interfere func(job *Job) *Job
+}
+
+func (s *jobStore) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	w.Header().Set("X-Elastic-Product", "Elasticsearch")
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method == http.MethodGet {
+		if s.job == nil {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"found":false}`)
+			return
+		}
+		data, _ := json.Marshal(s.job)
+		fmt.Fprintf(w, `{"found":true,"_seq_no":%d,"_primary_term":1,"_source":%s}`, s.seqNo, data)
+		return
+	}
+
+	if s.interfere != nil {
+		s.job, s.interfere = s.interfere(s.job), nil
+		s.seqNo++
+	}
+
+	query := r.URL.Query()
+	conflict := query.Get("op_type") == "create" && s.job != nil
+	if ifSeqNo := query.Get("if_seq_no"); ifSeqNo != "" {
+		conflict = s.job == nil || ifSeqNo != strconv.Itoa(s.seqNo)
+	}
+	if conflict {
+		w.WriteHeader(http.StatusConflict)
+		fmt.Fprint(w, `{"error":{"type":"version_conflict_engine_exception"},"status":409}`)
+		return
+	}
+
+	var job Job
+	body, _ := io.ReadAll(r.Body)
+	if err := json.Unmarshal(body, &job); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	s.job = &job
+	s.seqNo++
+	fmt.Fprint(w, `{"result":"updated"}`)
+}
+
+func TestFailConcurrentUpdates(t *testing.T) {
+	failure := Failure{Source: "pacs", Type: "study", ItemID: "1.2.3", Err: errors.New("timeout")}
+	existing := func(attempts int) *Job {
+		return &Job{ID: JobID(failure.DocID()), DocID: failure.DocID(), State: StatePending, Attempts: attempts}
+	}
+	failedElsewhere := func(job *Job) *Job {
+		changed := *job
+		changed.Attempts++
+		return &changed
+	}
+
+	tests := []struct {
+		name         string
+		job          *Job
+		interfere    func(job *Job) *Job
+		wantAttempts int
+		wantState    State
+	}{
+		{name: "new job", wantAttempts: 1, wantState: StatePending},
+		{name: "existing job", job: existing(2), wantAttempts: 3, wantState: StatePending},
+		{name: "failed concurrently", job: existing(2), interfere: failedElsewhere, wantAttempts: 4, wantState: StatePending},
+		{name: "created concurrently", interfere: func(*Job) *Job { return existing(1) }, wantAttempts: 2, wantState: StatePending},
+		{name: "dead-lettered by concurrent failure", job: existing(MaxAttempts - 2), interfere: failedElsewhere, wantAttempts: MaxAttempts, wantState: StateDead},
+		{name: "resolved concurrently", job: existing(2), interfere: func(*Job) *Job { return nil }, wantAttempts: 1, wantState: StatePending},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &jobStore{job: tt.job, interfere: tt.interfere}
+			srv := httptest.NewServer(store)
+			defer srv.Close()
+
+			es, err := elasticsearch.NewClient(elasticsearch.Config{Addresses: []string{srv.URL}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := NewService(es).Fail(context.Background(), failure); err != nil {
+				t.Fatal(err)
+			}
+
+			if store.job.Attempts != tt.wantAttempts || store.job.State != tt.wantState {
+				t.Errorf("job = %d attempts, %s, want %d attempts, %s", store.job.Attempts, store.job.State, tt.wantAttempts, tt.wantState)
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+// Package lease coordinates replicas through time-limited leases stored as Elasticsearch
+// documents, so that only one replica works on a resource at a time.
+package lease
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8"
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+)
+
+// Lease is the stored state of a lease on a resource.
+type Lease struct {
+	Resource   string    `json:"resource"`   // Name of the leased resource, e.g., "datasource:Orthanc"
+	Holder     string    `json:"holder"`     // ID of the replica holding the lease
+	ExpiresAt  time.Time `json:"expiresAt"`  // When the lease lapses unless renewed
+	AcquiredAt time.Time `json:"acquiredAt"` // When the holder acquired the lease
+}
+
+// Service defines the lease operations.
+type Service interface {
+	// Acquire takes or renews the lease on a resource for ttl. It returns false if another
+	// holder has a lease that has not expired.
+	Acquire(ctx context.Context, resource, holder string, ttl time.Duration) (bool, error)
+	// Release gives up the lease on a resource if it is held by holder.
+	Release(ctx context.Context, resource, holder string) error
+}
+
+const indexName = "indexer_leases"
+
+// service implements leases using Elasticsearch documents. Updates are conditional on the
+// document's sequence number, so concurrent attempts to take a lease cannot both succeed.
+// Expiry is judged by the local clock of each replica, so clocks must be roughly in sync.
+type service struct {
+	es *elasticsearch.Client
+}
+
+// NewService returns a new instance of the lease Service.
+func NewService(es *elasticsearch.Client) Service {
+	return &service{es: es}
+}
+
+// stored is a lease document together with the version it was read at.
+type stored struct {
+	Lease       Lease `json:"_source"`
+	SeqNo       int   `json:"_seq_no"`
+	PrimaryTerm int   `json:"_primary_term"`
+}
+
+// Acquire takes the lease if it is free, expired or already held by holder.
+func (s *service) Acquire(ctx context.Context, resource, holder string, ttl time.Duration) (bool, error) {
+	now := time.Now().UTC()
+
+	current, err := s.get(ctx, resource)
+	if err != nil {
+		return false, err
+	}
+
+	lease := Lease{Resource: resource, Holder: holder, ExpiresAt: now.Add(ttl), AcquiredAt: now}
+	if current == nil {
+		return s.put(ctx, lease, nil)
+	}
+	if current.Lease.Holder != holder && current.Lease.ExpiresAt.After(now) {
+		return false, nil
+	}
+	if current.Lease.Holder == holder {
+		lease.AcquiredAt = current.Lease.AcquiredAt
+	}
+	return s.put(ctx, lease, current)
+}
+
+// Release expires the lease immediately if it is held by holder, so another replica can take it
+// without waiting for the lease to lapse.
+func (s *service) Release(ctx context.Context, resource, holder string) error {
+	current, err := s.get(ctx, resource)
+	if err != nil {
+		return err
+	}
+	if current == nil || current.Lease.Holder != holder {
+		return nil
+	}
+
+	lease := current.Lease
+	lease.ExpiresAt = time.Now().UTC()
+	_, err = s.put(ctx, lease, current)
+	return err
+}
+
+// get reads the lease on a resource, or returns nil if there is none.
+func (s *service) get(ctx context.Context, resource string) (*stored, error) {
+	res, err := s.es.Get(indexName, resource, s.es.Get.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("get lease request: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if res.IsError() {
+		return nil, fmt.Errorf("get lease error: %s", res.String())
+	}
+
+	var current stored
+	if err := json.NewDecoder(res.Body).Decode(&current); err != nil {
+		return nil, fmt.Errorf("decode lease: %w", err)
+	}
+	return &current, nil
+}
+
+// put writes a lease. It creates the document if previous is nil, and otherwise only replaces the
+// version that was read. It returns false if another replica wrote the lease in the meantime.
+func (s *service) put(ctx context.Context, lease Lease, previous *stored) (bool, error) {
+	data, err := json.Marshal(lease)
+	if err != nil {
+		return false, fmt.Errorf("marshal lease: %w", err)
+	}
+
+	opts := []func(*esapi.IndexRequest){
+		s.es.Index.WithDocumentID(lease.Resource),
+		s.es.Index.WithRefresh("true"),
+		s.es.Index.WithContext(ctx),
+	}
+	if previous == nil {
+		opts = append(opts, s.es.Index.WithOpType("create"))
+	} else {
+		opts = append(opts,
+			s.es.Index.WithIfSeqNo(previous.SeqNo),
+			s.es.Index.WithIfPrimaryTerm(previous.PrimaryTerm),
+		)
+	}
+
+	res, err := s.es.Index(indexName, bytes.NewReader(data), opts...)
+	if err != nil {
+		return false, fmt.Errorf("index lease request: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusConflict {
+		return false, nil
+	}
+	if res.IsError() {
+		return false, fmt.Errorf("index lease error: %s", res.String())
+	}
+	return true, nil
+}
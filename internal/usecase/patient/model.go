@@ -0,0 +1,41 @@
+package patient
+
+import (
+	"errors"
+
+	"github.com/yangszwei/koala/internal/usecase/search"
+)
+
+// ErrNotFound is returned when no documents are indexed for a patient.
+var ErrNotFound = errors.New("patient not found")
+
+// Patient summarizes the demographics and documents of a patient across all sources.
+type Patient struct {
+	ID         string   `json:"id"`
+	Name       string   `json:"name"`                 // Most common spelling of the patient's name
+	Names      []string `json:"names,omitempty"`      // Distinct names, with spelling variants across sources merged
+	Gender     string   `json:"gender,omitempty"`     // Most common gender recorded for the patient
+	Documents  int64    `json:"documents"`            // Number of indexed documents of the patient
+	Modalities []string `json:"modalities,omitempty"` // Modalities of the patient's studies, most frequent first
+	FirstStudy string   `json:"firstStudy,omitempty"` // Date of the earliest study, as YYYY-MM-DD
+	LastStudy  string   `json:"lastStudy,omitempty"`  // Date of the latest study, as YYYY-MM-DD
+}
+
+// Timeline is a patient with their documents grouped by study date and modality.
+type Timeline struct {
+	Patient
+	Dates     []TimelineDate `json:"dates"`     // Most recent date first; documents without a date come last
+	Truncated bool           `json:"truncated"` // Whether older documents were left out of the timeline
+}
+
+// TimelineDate holds the documents of the studies on a single date.
+type TimelineDate struct {
+	Date   string          `json:"date"` // YYYY-MM-DD, or empty for documents without a study date
+	Groups []TimelineGroup `json:"groups"`
+}
+
+// TimelineGroup holds the documents of a single modality on a date.
+type TimelineGroup struct {
+	Modality  string            `json:"modality"`
+	Documents []search.Document `json:"documents"`
+}
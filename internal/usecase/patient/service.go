@@ -0,0 +1,339 @@
+// Package patient aggregates the indexed documents by patient, for browsing everything known
+// about a patient rather than individual search hits.
+package patient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/elastic/go-elasticsearch/v8"
+	"github.com/yangszwei/koala/internal/usecase/search"
+)
+
+// Service defines the patient operations.
+type Service interface {
+	// List returns the patients whose ID or name matches the query, or all patients if it is
+	// empty, most recently seen first, and the total number of such patients.
+	List(ctx context.Context, query string, offset, limit int) ([]Patient, int, error)
+	// Get returns a patient with their documents grouped by study date and modality.
+	Get(ctx context.Context, id string) (*Timeline, error)
+}
+
+// indexName is the index of the documents indexed by the search service.
+const indexName = "search_documents"
+
+// maxTimelineDocuments bounds the number of documents returned in a timeline.
+const maxTimelineDocuments = 1000
+
+// service implements the patient operations using Elasticsearch.
+type service struct {
+	es *elasticsearch.Client
+}
+
+// NewService returns a new instance of the patient Service.
+func NewService(es *elasticsearch.Client) Service {
+	return &service{es: es}
+}
+
+// patientAggs summarize the documents of a patient.
+func patientAggs() map[string]interface{} {
+	return map[string]interface{}{
+		"names":      map[string]interface{}{"terms": map[string]interface{}{"field": "patientName.keyword", "size": 20}},
+		"genders":    map[string]interface{}{"terms": map[string]interface{}{"field": "gender", "size": 5}},
+		"modalities": map[string]interface{}{"terms": map[string]interface{}{"field": "modality", "size": 20}},
+		"firstStudy": map[string]interface{}{"min": map[string]interface{}{"field": "studyDate", "format": "yyyy-MM-dd"}},
+		"lastStudy":  map[string]interface{}{"max": map[string]interface{}{"field": "studyDate", "format": "yyyy-MM-dd"}},
+	}
+}
+
+// termsAgg is the result of a terms aggregation.
+type termsAgg struct {
+	Buckets []search.FacetBucket `json:"buckets"`
+}
+
+// dateAgg is the result of a min or max aggregation of dates. It is empty if no document has a date.
+type dateAgg struct {
+	Value string `json:"value_as_string"`
+}
+
+// patientBucket is the result of patientAggs over the documents of a patient.
+type patientBucket struct {
+	Key        string   `json:"key"`
+	DocCount   int64    `json:"doc_count"`
+	Names      termsAgg `json:"names"`
+	Genders    termsAgg `json:"genders"`
+	Modalities termsAgg `json:"modalities"`
+	FirstStudy dateAgg  `json:"firstStudy"`
+	LastStudy  dateAgg  `json:"lastStudy"`
+}
+
+// patient builds the patient summary of an aggregation result.
+func (b patientBucket) patient() Patient {
+	p := Patient{
+		ID:         b.Key,
+		Documents:  b.DocCount,
+		FirstStudy: b.FirstStudy.Value,
+		LastStudy:  b.LastStudy.Value,
+	}
+	p.Name, p.Names = mergeNames(b.Names.Buckets)
+	for _, g := range b.Genders.Buckets {
+		if g.Key != "" && g.Key != "unknown" {
+			p.Gender = g.Key
+			break
+		}
+	}
+	for _, m := range b.Modalities.Buckets {
+		if m.Key != "" {
+			p.Modalities = append(p.Modalities, m.Key)
+		}
+	}
+	return p
+}
+
+// nameKey identifies the spelling variants of a name. DICOM names put the family name first and
+// are uppercase, while FHIR and HL7 names put the given name first, so the key is the lowercased
+// words of the name in alphabetical order.
+func nameKey(name string) string {
+	words := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	sort.Strings(words)
+	return strings.Join(words, " ")
+}
+
+// mergeNames merges the spelling variants of the names of a patient and returns the most common
+// name and all distinct names, most common first. Each name is spelled as in the sources that do
+// not write names in uppercase where possible, and otherwise as in the most documents.
+func mergeNames(buckets []search.FacetBucket) (string, []string) {
+	type group struct {
+		name      string
+		count     int64
+		mixedCase bool // whether the name is written in mixed case
+	}
+	var groups []*group
+	byKey := make(map[string]*group)
+
+	for _, b := range buckets {
+		key := nameKey(b.Key)
+		if key == "" {
+			continue
+		}
+		mixed := strings.ToUpper(b.Key) != b.Key
+		g, ok := byKey[key]
+		if !ok {
+			g = &group{name: b.Key, mixedCase: mixed}
+			byKey[key] = g
+			groups = append(groups, g)
+		} else if mixed && !g.mixedCase {
+			g.name, g.mixedCase = b.Key, true
+		}
+		g.count += b.DocCount
+	}
+	if len(groups) == 0 {
+		return "", nil
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool { return groups[i].count > groups[j].count })
+	names := make([]string, len(groups))
+	for i, g := range groups {
+		names[i] = g.name
+	}
+	return names[0], names
+}
+
+// List finds the page of matching patients, ordered by their latest study, and then summarizes
+// all documents of these patients, including those that do not match the query.
+func (s *service) List(ctx context.Context, query string, offset, limit int) ([]Patient, int, error) {
+	boolQuery := map[string]interface{}{
+		"filter":   []map[string]interface{}{{"exists": map[string]interface{}{"field": "patientId"}}},
+		"must_not": []map[string]interface{}{{"term": map[string]interface{}{"patientId": ""}}},
+	}
+	if query = strings.TrimSpace(query); query != "" {
+		boolQuery["should"] = []map[string]interface{}{
+			{"term": map[string]interface{}{"patientId": query}},
+			{"prefix": map[string]interface{}{"patientId": query}},
+			{"match": map[string]interface{}{"patientName": map[string]interface{}{"query": query, "operator": "and", "fuzziness": "AUTO"}}},
+		}
+		boolQuery["minimum_should_match"] = 1
+	}
+
+	var page struct {
+		Total struct {
+			Value int `json:"value"`
+		} `json:"total"`
+		Patients struct {
+			Buckets []patientBucket `json:"buckets"`
+		} `json:"patients"`
+	}
+	err := s.aggregate(ctx, map[string]interface{}{"bool": boolQuery}, map[string]interface{}{
+		"total": map[string]interface{}{"cardinality": map[string]interface{}{"field": "patientId"}},
+		"patients": map[string]interface{}{
+			"terms": map[string]interface{}{
+				"field": "patientId",
+				"size":  offset + limit,
+				"order": map[string]interface{}{"lastStudy": "desc"},
+			},
+			"aggs": map[string]interface{}{
+				"lastStudy": patientAggs()["lastStudy"],
+				"page":      map[string]interface{}{"bucket_sort": map[string]interface{}{"from": offset, "size": limit}},
+			},
+		},
+	}, &page)
+	if err != nil {
+		return nil, 0, fmt.Errorf("list patients: %w", err)
+	}
+
+	patients := []Patient{}
+	if len(page.Patients.Buckets) == 0 {
+		return patients, page.Total.Value, nil
+	}
+
+	ids := make([]string, len(page.Patients.Buckets))
+	for i, b := range page.Patients.Buckets {
+		ids[i] = b.Key
+	}
+
+	var summaries struct {
+		Patients struct {
+			Buckets []patientBucket `json:"buckets"`
+		} `json:"patients"`
+	}
+	err = s.aggregate(ctx, map[string]interface{}{"terms": map[string]interface{}{"patientId": ids}}, map[string]interface{}{
+		"patients": map[string]interface{}{
+			"terms": map[string]interface{}{"field": "patientId", "size": len(ids)},
+			"aggs":  patientAggs(),
+		},
+	}, &summaries)
+	if err != nil {
+		return nil, 0, fmt.Errorf("summarize patients: %w", err)
+	}
+
+	byID := make(map[string]patientBucket, len(summaries.Patients.Buckets))
+	for _, b := range summaries.Patients.Buckets {
+		byID[b.Key] = b
+	}
+	for _, id := range ids {
+		if b, ok := byID[id]; ok {
+			patients = append(patients, b.patient())
+		}
+	}
+	return patients, page.Total.Value, nil
+}
+
+// aggregate runs the aggregations over the documents matching the query and decodes their results into v.
+func (s *service) aggregate(ctx context.Context, query, aggs map[string]interface{}, v interface{}) error {
+	data, err := json.Marshal(map[string]interface{}{
+		"size":  0,
+		"query": query,
+		"aggs":  aggs,
+	})
+	if err != nil {
+		return err
+	}
+
+	res, err := s.es.Search(
+		s.es.Search.WithContext(ctx),
+		s.es.Search.WithIndex(indexName),
+		s.es.Search.WithBody(bytes.NewReader(data)),
+	)
+	if err != nil {
+		return fmt.Errorf("aggregation request: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return fmt.Errorf("aggregation error: %s", res.String())
+	}
+
+	var parsed struct {
+		Aggregations json.RawMessage `json:"aggregations"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&parsed); err != nil {
+		return fmt.Errorf("decode aggregations: %w", err)
+	}
+	if len(parsed.Aggregations) == 0 {
+		return nil
+	}
+	return json.Unmarshal(parsed.Aggregations, v)
+}
+
+// Get summarizes the documents of a patient and lists them from the most recent study on. The
+// report text is left out, since it can be fetched per document.
+func (s *service) Get(ctx context.Context, id string) (*Timeline, error) {
+	data, err := json.Marshal(map[string]interface{}{
+		"size":    maxTimelineDocuments,
+		"query":   map[string]interface{}{"term": map[string]interface{}{"patientId": id}},
+		"_source": map[string]interface{}{"excludes": []string{"reportText"}},
+		"sort": []map[string]interface{}{
+			{"studyDate": map[string]interface{}{"order": "desc", "missing": "_last"}},
+			{"modality": map[string]interface{}{"order": "asc"}},
+			{"id": map[string]interface{}{"order": "asc"}},
+		},
+		"aggs": patientAggs(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := s.es.Search(
+		s.es.Search.WithContext(ctx),
+		s.es.Search.WithIndex(indexName),
+		s.es.Search.WithBody(bytes.NewReader(data)),
+		s.es.Search.WithTrackTotalHits(true),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("get patient request: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return nil, fmt.Errorf("get patient error: %s", res.String())
+	}
+
+	var parsed struct {
+		Hits struct {
+			Total struct {
+				Value int64 `json:"value"`
+			} `json:"total"`
+			Hits []struct {
+				Source search.Document `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+		Aggregations patientBucket `json:"aggregations"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&parsed); err != nil {
+		return nil, fmt.Errorf("decode patient: %w", err)
+	}
+	if parsed.Hits.Total.Value == 0 {
+		return nil, ErrNotFound
+	}
+
+	parsed.Aggregations.Key = id
+	parsed.Aggregations.DocCount = parsed.Hits.Total.Value
+	timeline := &Timeline{
+		Patient:   parsed.Aggregations.patient(),
+		Dates:     []TimelineDate{},
+		Truncated: int64(len(parsed.Hits.Hits)) < parsed.Hits.Total.Value,
+	}
+
+	// Documents are sorted by date and modality, so each group is a run of documents
+	for _, hit := range parsed.Hits.Hits {
+		doc := hit.Source
+		if n := len(timeline.Dates); n == 0 || timeline.Dates[n-1].Date != doc.StudyDate {
+			timeline.Dates = append(timeline.Dates, TimelineDate{Date: doc.StudyDate})
+		}
+		date := &timeline.Dates[len(timeline.Dates)-1]
+		if n := len(date.Groups); n == 0 || date.Groups[n-1].Modality != doc.Modality {
+			date.Groups = append(date.Groups, TimelineGroup{Modality: doc.Modality})
+		}
+		group := &date.Groups[len(date.Groups)-1]
+		group.Documents = append(group.Documents, doc)
+	}
+
+	return timeline, nil
+}
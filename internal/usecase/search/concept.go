@@ -0,0 +1,172 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/yangszwei/koala/internal/domain"
+)
+
+// ConceptSource looks up the coded concepts that concept searches are expanded with.
+type ConceptSource interface {
+	// Concepts returns the terms of the concepts with the given codes in the system.
+	Concepts(ctx context.Context, system string, codes []string) ([]domain.CompletionTerm, error)
+	// Narrower returns the terms of the concepts whose parents include one of the codes in the system.
+	Narrower(ctx context.Context, system string, codes []string) ([]domain.CompletionTerm, error)
+}
+
+const (
+	// maxExpansionDepth is how many levels of descendants a concept search is expanded to.
+	maxExpansionDepth = 3
+	// maxExpandedConcepts bounds the number of concepts a concept search is expanded to.
+	maxExpandedConcepts = 50
+)
+
+// Boosts of the concept search clauses. Documents coded with a concept rank above documents that
+// only name it, and the searched concept ranks above its descendants.
+const (
+	conceptCodeBoost    = 10
+	descendantCodeBoost = 5
+	conceptNameBoost    = 2
+	descendantNameBoost = 1
+)
+
+// conceptFields are the text fields searched for the names of a concept.
+var conceptFields = []string{"reportText", "impression", "codeDisplay", "studyDescription", "seriesDescriptions"}
+
+// Expansion explains which concepts a concept search matched documents by.
+type Expansion struct {
+	Concept   string            `json:"concept"`   // The searched "system|code" token
+	Concepts  []ExpandedConcept `json:"concepts"`  // The searched concept, followed by its descendants
+	Truncated bool              `json:"truncated"` // Whether descendants were left out to bound the search
+}
+
+// ExpandedConcept is a concept a search was expanded to, with the names it was searched by.
+type ExpandedConcept struct {
+	Coding   string   `json:"coding"`             // "system|code" token matched against report codes
+	Term     string   `json:"term,omitempty"`     // Preferred name; empty if the concept is not loaded
+	Synonyms []string `json:"synonyms,omitempty"` // Other names of the concept
+	Depth    int      `json:"depth"`              // 0 for the searched concept, 1 for its children, and so on
+}
+
+// names returns the names the concept is searched by.
+func (c ExpandedConcept) names() []string {
+	if c.Term == "" {
+		return c.Synonyms
+	}
+	return append([]string{c.Term}, c.Synonyms...)
+}
+
+// splitCoding splits a "system|code" token. Tokens without a system are bare codes.
+func splitCoding(coding string) (string, string) {
+	if i := strings.LastIndex(coding, "|"); i >= 0 {
+		return strings.TrimSpace(coding[:i]), strings.TrimSpace(coding[i+1:])
+	}
+	return "", strings.TrimSpace(coding)
+}
+
+// expand looks up a concept and its descendants, breadth first, until maxExpansionDepth levels or
+// maxExpandedConcepts concepts are reached. A concept that is not loaded is searched by code only.
+func (s *service) expand(ctx context.Context, coding string) (*Expansion, error) {
+	system, code := splitCoding(coding)
+	if code == "" {
+		return nil, fmt.Errorf("%w: invalid concept %q", ErrInvalidQuery, coding)
+	}
+	root := ExpandedConcept{Coding: coding}
+	expansion := &Expansion{Concept: coding}
+	if s.concepts == nil || system == "" {
+		expansion.Concepts = []ExpandedConcept{root}
+		return expansion, nil
+	}
+
+	terms, err := s.concepts.Concepts(ctx, system, []string{code})
+	if err != nil {
+		return nil, fmt.Errorf("look up concept: %w", err)
+	}
+	if len(terms) > 0 {
+		root.Term, root.Synonyms = terms[0].Term, terms[0].Synonyms
+	}
+	expansion.Concepts = []ExpandedConcept{root}
+
+	seen := map[string]bool{code: true}
+	level := []string{code}
+	for depth := 1; depth <= maxExpansionDepth && len(level) > 0; depth++ {
+		children, err := s.concepts.Narrower(ctx, system, level)
+		if err != nil {
+			return nil, fmt.Errorf("look up descendants: %w", err)
+		}
+
+		level = nil
+		for _, child := range children {
+			if seen[child.Code] {
+				continue
+			}
+			if len(expansion.Concepts) >= maxExpandedConcepts {
+				expansion.Truncated = true
+				return expansion, nil
+			}
+			seen[child.Code] = true
+			level = append(level, child.Code)
+			expansion.Concepts = append(expansion.Concepts, ExpandedConcept{
+				Coding:   child.Coding(),
+				Term:     child.Term,
+				Synonyms: child.Synonyms,
+				Depth:    depth,
+			})
+		}
+	}
+	if len(level) > 0 {
+		// Descendants beyond the deepest level expanded may exist
+		expansion.Truncated = true
+	}
+
+	return expansion, nil
+}
+
+// conceptClause matches documents coded with or naming any of the expanded concepts. Each clause is
+// named after what it matches, e.g., "code:http://snomed.info/sct|60046008" or "text:pleural effusion",
+// so that the names reported with a hit explain why it matched.
+func conceptClause(expansion *Expansion) map[string]interface{} {
+	should := []map[string]interface{}{}
+	named := make(map[string]bool)
+
+	for _, concept := range expansion.Concepts {
+		codeBoost, nameBoost := conceptCodeBoost, conceptNameBoost
+		if concept.Depth > 0 {
+			codeBoost, nameBoost = descendantCodeBoost, descendantNameBoost
+		}
+
+		should = append(should, map[string]interface{}{
+			"constant_score": map[string]interface{}{
+				"filter": map[string]interface{}{"term": map[string]interface{}{"codes": concept.Coding}},
+				"boost":  codeBoost,
+				"_name":  "code:" + concept.Coding,
+			},
+		})
+
+		for _, name := range concept.names() {
+			key := strings.ToLower(name)
+			if named[key] {
+				continue
+			}
+			named[key] = true
+			should = append(should, map[string]interface{}{
+				"multi_match": map[string]interface{}{
+					"query":  name,
+					"type":   "phrase",
+					"fields": conceptFields,
+					"boost":  nameBoost,
+					"_name":  "text:" + name,
+				},
+			})
+		}
+	}
+
+	return map[string]interface{}{
+		"bool": map[string]interface{}{
+			"should":               should,
+			"minimum_should_match": 1,
+		},
+	}
+}
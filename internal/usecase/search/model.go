@@ -1,5 +1,13 @@
 package search
 
+import "errors"
+
+// ErrInvalidQuery is returned when a search query cannot be run.
+var ErrInvalidQuery = errors.New("invalid query")
+
+// ErrNotFound is returned when no document is indexed under an ID.
+var ErrNotFound = errors.New("document not found")
+
 // Document represents a unified study document stored in Elasticsearch.
 type Document struct {
 	ID          string   `json:"id"`
@@ -12,11 +20,33 @@ type Document struct {
 	Categories  []string `json:"categories"`
 	ReportText  string   `json:"reportText"`
 	Impression  string   `json:"impression"`
+
+	// Report metadata, populated from FHIR DiagnosticReports.
+	Status           string   `json:"status,omitempty"`           // registered | partial | preliminary | final | amended | ...
+	Codes            []string `json:"codes,omitempty"`            // Report codes as "system|code" tokens and bare codes (e.g., LOINC)
+	CodeDisplay      string   `json:"codeDisplay,omitempty"`      // Human-readable name of the report code
+	Performers       []string `json:"performers,omitempty"`       // Display names of performers and results interpreters
+	PerformerIDs     []string `json:"performerIds,omitempty"`     // References of performers and results interpreters (e.g., "Practitioner/123")
+	Issued           string   `json:"issued,omitempty"`           // When the report was released
+	EncounterID      string   `json:"encounterId,omitempty"`      // ID of the encounter the report belongs to
+	AccessionNumbers []string `json:"accessionNumbers,omitempty"` // Accession numbers of the orders or studies
+
+	// Study metadata, populated from DICOM headers.
+	StudyDescription   string   `json:"studyDescription,omitempty"`
+	ReferringPhysician string   `json:"referringPhysician,omitempty"`
+	Institution        string   `json:"institution,omitempty"`
+	BodyParts          []string `json:"bodyParts,omitempty"`  // Body parts examined across all series
+	Modalities         []string `json:"modalities,omitempty"` // All modalities in the study
+	SeriesCount        int      `json:"seriesCount,omitempty"`
+	InstanceCount      int      `json:"instanceCount,omitempty"`
+	SeriesDescriptions []string `json:"seriesDescriptions,omitempty"`
 }
 
 // Query defines search parameters.
 type Query struct {
 	Search      string   `form:"search"`
+	Concept     string   `form:"concept"`                  // "system|code" token of a concept to search for instead of Search
+	AutoCorrect bool     `form:"autocorrect,default=true"` // Whether a search without results is retried with its spelling correction
 	Type        string   `form:"type"`
 	Modality    string   `form:"modality"`
 	PatientID   string   `form:"patientId"`
@@ -25,14 +55,42 @@ type Query struct {
 	ToDate      string   `form:"toDate"`
 	Gender      []string `form:"gender"`
 	Category    []string `form:"category"`
+	BodyPart    []string `form:"bodyPart"`
+	Institution string   `form:"institution"`
+	Status      []string `form:"status"`
+	Code        []string `form:"code"`      // "system|code" token or bare code
+	Performer   []string `form:"performer"` // Display name or reference
+	Accession   string   `form:"accession"`
+	EncounterID string   `form:"encounterId"`
 	Limit       int      `form:"limit,default=10"`
 	Offset      int      `form:"offset,default=0"`
 }
 
+// SimilarQuery defines the parameters of a search for reports similar to a document or to free
+// report text. The metadata constraints of the embedded Query filter the similar reports, while
+// its Search and Concept are ignored.
+type SimilarQuery struct {
+	Query
+	DocumentID     string `form:"documentId"`     // ID of the document to find similar reports to
+	Text           string `form:"text"`           // Report text to find similar reports to, if no document is given
+	ExcludePatient bool   `form:"excludePatient"` // Whether reports of the document's patient are left out
+}
+
 // Result is a single returned hit.
 type Result struct {
 	Document Document `json:"document"`
 	Score    float64  `json:"score"`
+	Matched  []string `json:"matched,omitempty"` // Clauses of a concept search the document matched, e.g., "code:..." or "text:..."
+}
+
+// Response is a page of search results.
+type Response struct {
+	Results   []Result   `json:"results"`
+	Total     int64      `json:"total"`               // Number of documents matching the query
+	Expansion *Expansion `json:"expansion,omitempty"` // Concepts a concept search was expanded to
+
+	DidYouMean string `json:"didYouMean,omitempty"` // Spelling correction of the search text
+	Corrected  bool   `json:"corrected,omitempty"`  // Whether the results are for DidYouMean, as the search text matched nothing
 }
 
 // CategoryBucket represents a category and its document count.
@@ -40,3 +98,12 @@ type CategoryBucket struct {
 	Key      string `json:"key"`
 	DocCount int64  `json:"doc_count"`
 }
+
+// FacetBucket represents a distinct value of a facet field and its document count.
+type FacetBucket = CategoryBucket
+
+// Mentions describes the documents mentioning a phrase.
+type Mentions struct {
+	Count      int64    // Number of documents mentioning the phrase
+	Modalities []string // Modalities of the studies of those documents
+}
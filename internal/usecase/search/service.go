@@ -4,7 +4,9 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/elastic/go-elasticsearch/v8"
 	"github.com/yangszwei/koala/pkg/elasticutil"
@@ -14,24 +16,53 @@ import (
 type Service interface {
 	// Index adds or updates a Document in the search backend.
 	Index(ctx context.Context, doc Document) error
-	// Search performs a fulltext + metadata search across indexed studies.
-	Search(ctx context.Context, query Query) ([]Result, error)
+	// IndexBatch adds or updates all Documents read from the channel using bulk requests, and
+	// returns the number of Documents indexed. Documents rejected by the backend are reported by an
+	// *elasticutil.BulkError once the channel is drained.
+	IndexBatch(ctx context.Context, docs <-chan Document) (int, error)
+	// Search performs a fulltext + metadata search across indexed studies. If the query names a
+	// concept, documents are matched by the codes and names of the concept and its descendants.
+	// Misspelled text searches are answered with a correction, and retried with it if they match nothing.
+	Search(ctx context.Context, query Query) (*Response, error)
+	// Similar finds the reports whose text is most like that of a document or of free report text.
+	// It returns ErrNotFound if the document is not indexed.
+	Similar(ctx context.Context, query SimilarQuery) (*Response, error)
 	// ListCategories returns categories that optionally match a given prefix.
 	ListCategories(ctx context.Context, prefix string) ([]CategoryBucket, error)
+	// Facets returns the value counts of the facet fields across documents matching the query.
+	Facets(ctx context.Context, query Query) (map[string][]FacetBucket, error)
+	// Mentions returns, for each phrase group, how many documents mention any of its phrases.
+	Mentions(ctx context.Context, phrases [][]string) ([]Mentions, error)
+	// ScanReports calls fn with the report text of every document that has one, in batches of up to
+	// size documents, most recent studies first.
+	ScanReports(ctx context.Context, size int, fn func([]Document) error) error
+	// Get returns the document with the given ID, or ErrNotFound if it is not indexed.
+	Get(ctx context.Context, id string) (*Document, error)
 	// Exists checks if a document with the given ID already exists in the index.
 	Exists(ctx context.Context, id string) (bool, error)
+	// Delete removes the document with the given ID. Deleting a missing document is not an error.
+	Delete(ctx context.Context, id string) error
+	// Migrate reindexes the documents indexed by earlier versions in the background, so that they
+	// get the fields added since. It does nothing if the index was already migrated.
+	Migrate(ctx context.Context) error
 }
 
 var indexName = "search_documents"
 
 type service struct {
-	es *elasticsearch.Client // Elasticsearch client
+	es       *elasticsearch.Client // Elasticsearch client
+	concepts ConceptSource         // Coded concepts that concept searches are expanded with
+	speller  Speller               // Term vocabulary that searches without results are corrected with
 }
 
 // NewService creates a new search service instance using Elasticsearch and the provided index name.
-func NewService(es *elasticsearch.Client) Service {
+// Concept searches are expanded with the concepts looked up in concepts, and text searches without
+// results are corrected with speller.
+func NewService(es *elasticsearch.Client, concepts ConceptSource, speller Speller) Service {
 	return &service{
-		es: es,
+		es:       es,
+		concepts: concepts,
+		speller:  speller,
 	}
 }
 
@@ -61,6 +92,56 @@ func (s *service) Index(ctx context.Context, doc Document) error {
 	return nil
 }
 
+// IndexBatch bulk indexes the Documents read from the channel and refreshes the index once done.
+func (s *service) IndexBatch(ctx context.Context, docs <-chan Document) (int, error) {
+	indexed, err := elasticutil.BulkInsertChan(ctx, s.es, indexName, docs, func(doc Document) string {
+		return doc.ID
+	}, 500)
+	var bulkErr *elasticutil.BulkError
+	if err != nil && !errors.As(err, &bulkErr) {
+		return indexed, fmt.Errorf("bulk insert failed: %w", err)
+	}
+
+	res, refreshErr := s.es.Indices.Refresh(
+		s.es.Indices.Refresh.WithContext(ctx),
+		s.es.Indices.Refresh.WithIndex(indexName),
+	)
+	if refreshErr != nil {
+		return indexed, fmt.Errorf("failed to refresh index: %w", refreshErr)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return indexed, fmt.Errorf("refresh index error: %s", res.String())
+	}
+
+	return indexed, err
+}
+
+// Get retrieves the document with the given ID from the index.
+func (s *service) Get(ctx context.Context, id string) (*Document, error) {
+	res, err := s.es.Get(indexName, id, s.es.Get.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("get request: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == 404 {
+		return nil, ErrNotFound
+	}
+	if res.IsError() {
+		return nil, fmt.Errorf("get error: %s", res.String())
+	}
+
+	var parsed struct {
+		Source Document `json:"_source"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&parsed); err != nil {
+		return nil, fmt.Errorf("decode document: %w", err)
+	}
+	return &parsed.Source, nil
+}
+
 // Exists checks if a document with the given ID already exists in the index.
 func (s *service) Exists(ctx context.Context, id string) (bool, error) {
 	res, err := s.es.Exists(indexName, id, s.es.Exists.WithContext(ctx))
@@ -71,124 +152,247 @@ func (s *service) Exists(ctx context.Context, id string) (bool, error) {
 	return res.StatusCode == 200, nil
 }
 
-// Search performs a query on the Elasticsearch index with support for progressive fuzziness.
-// It attempts the query using increasing levels of fuzziness ("AUTO", "1", "2") until results are found.
-func (s *service) Search(ctx context.Context, q Query) ([]Result, error) {
-	var results []Result
-	var lastErr error
+// Delete removes the document with the given ID from the index.
+func (s *service) Delete(ctx context.Context, id string) error {
+	res, err := s.es.Delete(
+		indexName,
+		id,
+		s.es.Delete.WithRefresh("wait_for"),
+		s.es.Delete.WithContext(ctx),
+	)
+	if err != nil {
+		return fmt.Errorf("delete request: %w", err)
+	}
+	defer res.Body.Close()
 
-	for _, fuzziness := range []string{"AUTO", "1", "2"} {
-		must := []map[string]interface{}{}
-		if q.Search != "" {
-			safeQuery := elasticutil.EscapeQueryString(q.Search)
-			must = append(must, map[string]interface{}{
-				"multi_match": map[string]interface{}{
-					"query":     safeQuery,
-					"fields":    []string{"reportText", "reportText.autocomplete", "reportText.edge_ngram"},
-					"fuzziness": fuzziness,
-					"operator":  "or",
-				},
-			})
-		}
+	if res.IsError() && res.StatusCode != 404 {
+		return fmt.Errorf("delete error: %s", res.String())
+	}
 
-		filter := []map[string]interface{}{}
+	return nil
+}
 
-		if q.Type != "" {
-			escapedType := elasticutil.EscapeQueryString(q.Type)
-			fmt.Println(escapedType)
-			filter = append(filter, map[string]interface{}{"term": map[string]interface{}{"type": escapedType}})
-		}
-		if q.Modality != "" {
-			escapedModality := elasticutil.EscapeQueryString(q.Modality)
-			filter = append(filter, map[string]interface{}{"term": map[string]interface{}{"modality": escapedModality}})
-		}
-		if q.PatientID != "" {
-			escapedPatientID := elasticutil.EscapeQueryString(q.PatientID)
-			filter = append(filter, map[string]interface{}{"term": map[string]interface{}{"patientId": escapedPatientID}})
-		}
-		if len(q.Gender) > 0 {
-			escapedGender := elasticutil.EscapeQueryStrings(q.Gender)
-			filter = append(filter, map[string]interface{}{"terms": map[string]interface{}{"gender": escapedGender}})
+// Search performs a query on the Elasticsearch index. Text searches are fuzzy and come with a
+// spelling correction drawn from the reports in the same request. If a text search matches nothing,
+// the correction is looked up in the term vocabulary instead, and the search is retried with the
+// correction unless the query opts out of automatic correction.
+func (s *service) Search(ctx context.Context, q Query) (*Response, error) {
+	var expansion *Expansion
+	if q.Concept != "" {
+		var err error
+		if expansion, err = s.expand(ctx, q.Concept); err != nil {
+			return nil, err
 		}
-		if len(q.Category) > 0 {
-			escapedCategory := elasticutil.EscapeQueryStrings(q.Category)
-			filter = append(filter, map[string]interface{}{"terms": map[string]interface{}{"categories": escapedCategory}})
+	}
+
+	response, err := s.search(ctx, q, expansion)
+	if err != nil || expansion != nil || q.Search == "" || len(response.Results) > 0 {
+		return response, err
+	}
+
+	if response.DidYouMean == "" && s.speller != nil {
+		correction, err := s.speller.Correct(ctx, q.Search)
+		if err != nil {
+			return nil, fmt.Errorf("correct spelling: %w", err)
 		}
-		if q.FromDate != "" || q.ToDate != "" {
-			dateRange := map[string]interface{}{}
-			if q.FromDate != "" {
-				dateRange["gte"] = q.FromDate
-			}
-			if q.ToDate != "" {
-				dateRange["lte"] = q.ToDate
-			}
-			filter = append(filter, map[string]interface{}{
-				"range": map[string]interface{}{
-					"studyDate": dateRange,
-				},
-			})
+		if !strings.EqualFold(correction, q.Search) {
+			response.DidYouMean = correction
 		}
+	}
+	if response.DidYouMean == "" || !q.AutoCorrect {
+		return response, nil
+	}
 
-		queryBody := map[string]interface{}{
-			"from": q.Offset,
-			"size": q.Limit,
-			"query": map[string]interface{}{
-				"bool": map[string]interface{}{
-					"must":   must,
-					"filter": filter,
-				},
+	corrected := q
+	corrected.Search = response.DidYouMean
+	retry, err := s.search(ctx, corrected, nil)
+	if err != nil {
+		return nil, err
+	}
+	retry.DidYouMean = response.DidYouMean
+	retry.Corrected = true
+	return retry, nil
+}
+
+// search runs a single search request. Text searches also request a spelling correction.
+func (s *service) search(ctx context.Context, q Query, expansion *Expansion) (*Response, error) {
+	queryBody := map[string]interface{}{
+		"from": q.Offset,
+		"size": q.Limit,
+		"query": map[string]interface{}{
+			"bool": map[string]interface{}{
+				"must":   buildMust(q, expansion),
+				"filter": buildFilter(q),
 			},
-		}
+		},
+	}
+	if q.Search != "" && expansion == nil {
+		queryBody["suggest"] = didYouMeanSuggester(q.Search)
+	}
 
-		fmt.Println(queryBody)
+	response, suggest, err := s.run(ctx, queryBody)
+	if err != nil {
+		return nil, err
+	}
+	response.Expansion = expansion
+	response.DidYouMean = suggest.correction(q.Search)
+	return response, nil
+}
 
-		var buf bytes.Buffer
-		if err := json.NewEncoder(&buf).Encode(queryBody); err != nil {
-			return nil, fmt.Errorf("encode query body: %w", err)
-		}
+// run sends a search request body and returns its page of results and its suggestions.
+func (s *service) run(ctx context.Context, queryBody map[string]interface{}) (*Response, suggestResponse, error) {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(queryBody); err != nil {
+		return nil, suggestResponse{}, fmt.Errorf("encode query body: %w", err)
+	}
 
-		res, err := s.es.Search(
-			s.es.Search.WithContext(ctx),
-			s.es.Search.WithIndex(indexName),
-			s.es.Search.WithBody(&buf),
-			s.es.Search.WithTrackTotalHits(true),
-		)
-		if err != nil {
-			return nil, fmt.Errorf("search request: %w", err)
-		}
-		defer res.Body.Close()
+	res, err := s.es.Search(
+		s.es.Search.WithContext(ctx),
+		s.es.Search.WithIndex(indexName),
+		s.es.Search.WithBody(&buf),
+		s.es.Search.WithTrackTotalHits(true),
+	)
+	if err != nil {
+		return nil, suggestResponse{}, fmt.Errorf("search request: %w", err)
+	}
+	defer res.Body.Close()
 
-		if res.IsError() {
-			return nil, fmt.Errorf("search error: %s", res.String())
-		}
+	if res.IsError() {
+		return nil, suggestResponse{}, fmt.Errorf("search error: %s", res.String())
+	}
 
-		var parsed struct {
-			Hits struct {
-				Hits []struct {
-					Source Document `json:"_source"`
-					Score  float64  `json:"_score"`
-				} `json:"hits"`
+	var parsed struct {
+		Hits struct {
+			Total struct {
+				Value int64 `json:"value"`
+			} `json:"total"`
+			Hits []struct {
+				Source         Document `json:"_source"`
+				Score          float64  `json:"_score"`
+				MatchedQueries []string `json:"matched_queries"`
 			} `json:"hits"`
-		}
+		} `json:"hits"`
+		Suggest suggestResponse `json:"suggest"`
+	}
 
-		if err := json.NewDecoder(res.Body).Decode(&parsed); err != nil {
-			lastErr = fmt.Errorf("decode response: %w", err)
-			continue
+	if err := json.NewDecoder(res.Body).Decode(&parsed); err != nil {
+		return nil, suggestResponse{}, fmt.Errorf("decode response: %w", err)
+	}
+
+	response := &Response{
+		Results: make([]Result, len(parsed.Hits.Hits)),
+		Total:   parsed.Hits.Total.Value,
+	}
+	for i, hit := range parsed.Hits.Hits {
+		response.Results[i] = Result{
+			Document: hit.Source,
+			Score:    hit.Score,
+			Matched:  hit.MatchedQueries,
 		}
+	}
+	return response, parsed.Suggest, nil
+}
 
-		if len(parsed.Hits.Hits) > 0 {
-			results = make([]Result, len(parsed.Hits.Hits))
-			for i, hit := range parsed.Hits.Hits {
-				results[i] = Result{
-					Document: hit.Source,
-					Score:    hit.Score,
-				}
-			}
-			return results, nil
+// buildMust builds the fuzzy fulltext clauses of a Query. If the query was expanded from a
+// concept, the concept clause replaces the fulltext search.
+func buildMust(q Query, expansion *Expansion) []map[string]interface{} {
+	must := []map[string]interface{}{}
+	if expansion != nil {
+		return append(must, conceptClause(expansion))
+	}
+	if q.Search != "" {
+		safeQuery := elasticutil.EscapeQueryString(q.Search)
+		must = append(must, map[string]interface{}{
+			"multi_match": map[string]interface{}{
+				"query":     safeQuery,
+				"fields":    []string{"reportText", "reportText.autocomplete", "reportText.edge_ngram", "codeDisplay", "studyDescription", "seriesDescriptions"},
+				"fuzziness": "AUTO",
+				"operator":  "or",
+			},
+		})
+	}
+	return must
+}
+
+// buildFilter translates the metadata constraints of a Query into Elasticsearch filter clauses.
+// Report metadata values are matched literally, since codes and references contain characters
+// that query string escaping would alter.
+func buildFilter(q Query) []map[string]interface{} {
+	filter := []map[string]interface{}{}
+
+	if q.Type != "" {
+		escapedType := elasticutil.EscapeQueryString(q.Type)
+		filter = append(filter, map[string]interface{}{"term": map[string]interface{}{"type": escapedType}})
+	}
+	if q.Modality != "" {
+		escapedModality := elasticutil.EscapeQueryString(q.Modality)
+		filter = append(filter, map[string]interface{}{
+			"bool": map[string]interface{}{
+				"should": []map[string]interface{}{
+					{"term": map[string]interface{}{"modality": escapedModality}},
+					{"term": map[string]interface{}{"modalities": escapedModality}},
+				},
+				"minimum_should_match": 1,
+			},
+		})
+	}
+	if q.PatientID != "" {
+		escapedPatientID := elasticutil.EscapeQueryString(q.PatientID)
+		filter = append(filter, map[string]interface{}{"term": map[string]interface{}{"patientId": escapedPatientID}})
+	}
+	if len(q.Gender) > 0 {
+		escapedGender := elasticutil.EscapeQueryStrings(q.Gender)
+		filter = append(filter, map[string]interface{}{"terms": map[string]interface{}{"gender": escapedGender}})
+	}
+	if len(q.Category) > 0 {
+		escapedCategory := elasticutil.EscapeQueryStrings(q.Category)
+		filter = append(filter, map[string]interface{}{"terms": map[string]interface{}{"categories": escapedCategory}})
+	}
+	if q.FromDate != "" || q.ToDate != "" {
+		dateRange := map[string]interface{}{}
+		if q.FromDate != "" {
+			dateRange["gte"] = q.FromDate
+		}
+		if q.ToDate != "" {
+			dateRange["lte"] = q.ToDate
 		}
+		filter = append(filter, map[string]interface{}{
+			"range": map[string]interface{}{
+				"studyDate": dateRange,
+			},
+		})
+	}
+	if len(q.Status) > 0 {
+		filter = append(filter, map[string]interface{}{"terms": map[string]interface{}{"status": q.Status}})
+	}
+	if len(q.Code) > 0 {
+		filter = append(filter, map[string]interface{}{"terms": map[string]interface{}{"codes": q.Code}})
+	}
+	if len(q.Performer) > 0 {
+		filter = append(filter, map[string]interface{}{
+			"bool": map[string]interface{}{
+				"should": []map[string]interface{}{
+					{"terms": map[string]interface{}{"performers.keyword": q.Performer}},
+					{"terms": map[string]interface{}{"performerIds": q.Performer}},
+				},
+				"minimum_should_match": 1,
+			},
+		})
+	}
+	if q.Accession != "" {
+		filter = append(filter, map[string]interface{}{"term": map[string]interface{}{"accessionNumbers": q.Accession}})
+	}
+	if q.EncounterID != "" {
+		filter = append(filter, map[string]interface{}{"term": map[string]interface{}{"encounterId": q.EncounterID}})
+	}
+	if len(q.BodyPart) > 0 {
+		filter = append(filter, map[string]interface{}{"terms": map[string]interface{}{"bodyParts": q.BodyPart}})
+	}
+	if q.Institution != "" {
+		filter = append(filter, map[string]interface{}{"term": map[string]interface{}{"institution.keyword": q.Institution}})
 	}
 
-	return results, lastErr
+	return filter
 }
 
 // ListCategories returns all unique categories with their document counts,
@@ -247,3 +451,307 @@ func (s *service) ListCategories(ctx context.Context, prefix string) ([]Category
 
 	return parsed.Aggregations.Categories.Buckets, nil
 }
+
+// facetFields maps facet names exposed by the API to the indexed fields they aggregate.
+var facetFields = map[string]string{
+	"type":        "type",
+	"modality":    "modality",
+	"categories":  "categories",
+	"status":      "status",
+	"codes":       "codes",
+	"performers":  "performers.keyword",
+	"modalities":  "modalities",
+	"bodyParts":   "bodyParts",
+	"institution": "institution.keyword",
+}
+
+// Facets returns the value counts of each facet field across documents matching the query.
+func (s *service) Facets(ctx context.Context, q Query) (map[string][]FacetBucket, error) {
+	var expansion *Expansion
+	if q.Concept != "" {
+		var err error
+		if expansion, err = s.expand(ctx, q.Concept); err != nil {
+			return nil, err
+		}
+	}
+
+	aggs := map[string]interface{}{}
+	for name, field := range facetFields {
+		aggs[name] = map[string]interface{}{
+			"terms": map[string]interface{}{
+				"field": field,
+				"size":  50,
+			},
+		}
+	}
+
+	query := map[string]interface{}{
+		"size": 0,
+		"query": map[string]interface{}{
+			"bool": map[string]interface{}{
+				"must":   buildMust(q, expansion),
+				"filter": buildFilter(q),
+			},
+		},
+		"aggs": aggs,
+	}
+
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(query); err != nil {
+		return nil, fmt.Errorf("encode facet query: %w", err)
+	}
+
+	res, err := s.es.Search(
+		s.es.Search.WithContext(ctx),
+		s.es.Search.WithIndex(indexName),
+		s.es.Search.WithBody(&buf),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("facet aggregation request: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return nil, fmt.Errorf("facet aggregation error: %s", res.String())
+	}
+
+	var parsed struct {
+		Aggregations map[string]struct {
+			Buckets []FacetBucket `json:"buckets"`
+		} `json:"aggregations"`
+	}
+
+	if err := json.NewDecoder(res.Body).Decode(&parsed); err != nil {
+		return nil, fmt.Errorf("decode facet aggregation response: %w", err)
+	}
+
+	facets := make(map[string][]FacetBucket, len(parsed.Aggregations))
+	for name, agg := range parsed.Aggregations {
+		facets[name] = agg.Buckets
+	}
+
+	return facets, nil
+}
+
+// mentionFields are the text fields searched for mentions of a phrase.
+var mentionFields = []string{"reportText", "impression", "codeDisplay", "studyDescription"}
+
+// Mentions counts the documents mentioning each group of phrases and the modalities of their studies.
+// All groups are counted in a single multi-search request.
+func (s *service) Mentions(ctx context.Context, phrases [][]string) ([]Mentions, error) {
+	if len(phrases) == 0 {
+		return nil, nil
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, group := range phrases {
+		should := make([]map[string]interface{}, 0, len(group))
+		for _, phrase := range group {
+			should = append(should, map[string]interface{}{
+				"multi_match": map[string]interface{}{
+					"query":  phrase,
+					"type":   "phrase",
+					"fields": mentionFields,
+				},
+			})
+		}
+
+		query := map[string]interface{}{
+			"size":             0,
+			"track_total_hits": true,
+			"query": map[string]interface{}{
+				"bool": map[string]interface{}{
+					"should":               should,
+					"minimum_should_match": 1,
+				},
+			},
+			"aggs": map[string]interface{}{
+				"modality":   map[string]interface{}{"terms": map[string]interface{}{"field": "modality", "size": 20}},
+				"modalities": map[string]interface{}{"terms": map[string]interface{}{"field": "modalities", "size": 20}},
+			},
+		}
+		if err := enc.Encode(map[string]interface{}{"index": indexName}); err != nil {
+			return nil, fmt.Errorf("encode mention header: %w", err)
+		}
+		if err := enc.Encode(query); err != nil {
+			return nil, fmt.Errorf("encode mention query: %w", err)
+		}
+	}
+
+	res, err := s.es.Msearch(&buf, s.es.Msearch.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("mention search request: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return nil, fmt.Errorf("mention search error: %s", res.String())
+	}
+
+	var parsed struct {
+		Responses []struct {
+			Error interface{} `json:"error"`
+			Hits  struct {
+				Total struct {
+					Value int64 `json:"value"`
+				} `json:"total"`
+			} `json:"hits"`
+			Aggregations map[string]struct {
+				Buckets []FacetBucket `json:"buckets"`
+			} `json:"aggregations"`
+		} `json:"responses"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&parsed); err != nil {
+		return nil, fmt.Errorf("decode mention search response: %w", err)
+	}
+	if len(parsed.Responses) != len(phrases) {
+		return nil, fmt.Errorf("mention search returned %d responses for %d queries", len(parsed.Responses), len(phrases))
+	}
+
+	mentions := make([]Mentions, len(phrases))
+	for i, r := range parsed.Responses {
+		if r.Error != nil {
+			return nil, fmt.Errorf("mention search error: %v", r.Error)
+		}
+
+		seen := make(map[string]bool)
+		mentions[i].Count = r.Hits.Total.Value
+		for _, agg := range []string{"modality", "modalities"} {
+			for _, b := range r.Aggregations[agg].Buckets {
+				if b.Key != "" && !seen[b.Key] {
+					seen[b.Key] = true
+					mentions[i].Modalities = append(mentions[i].Modalities, b.Key)
+				}
+			}
+		}
+	}
+
+	return mentions, nil
+}
+
+// ScanReports scrolls through the documents with report text. Only the ID, study date, modality,
+// report text and impression of the documents are returned. It stops at the first error returned by fn.
+func (s *service) ScanReports(ctx context.Context, size int, fn func([]Document) error) error {
+	query := map[string]interface{}{
+		"_source": []string{"id", "studyDate", "modality", "reportText", "impression"},
+		"query": map[string]interface{}{
+			"exists": map[string]interface{}{"field": "reportText"},
+		},
+	}
+
+	type hit struct {
+		Source Document `json:"_source"`
+	}
+	return elasticutil.Scroll(ctx, s.es, indexName, query, "studyDate:desc", size, func(hits []hit) error {
+		docs := make([]Document, 0, len(hits))
+		for _, hit := range hits {
+			docs = append(docs, hit.Source)
+		}
+		return fn(docs)
+	})
+}
+
+// migrationVersion is the version of the stored documents that Migrate brings earlier documents
+// to. It is recorded in the _meta of the index mapping once the migration has started, so that the
+// migration runs once per version rather than at every start of every replica.
+const migrationVersion = 1
+
+// Migrate starts reindexing the reports that lack the spelling correction field, which is only
+// populated when a document is indexed, unless the index was already migrated to this version.
+// The reindexing runs as an Elasticsearch task, since it may take long on large indices.
+func (s *service) Migrate(ctx context.Context) error {
+	version, err := s.migratedVersion(ctx)
+	if err != nil {
+		return err
+	}
+	if version >= migrationVersion {
+		return nil
+	}
+
+	data, err := json.Marshal(map[string]interface{}{
+		"query": map[string]interface{}{
+			"bool": map[string]interface{}{
+				"filter":   map[string]interface{}{"exists": map[string]interface{}{"field": "reportText"}},
+				"must_not": map[string]interface{}{"exists": map[string]interface{}{"field": spellField}},
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	res, err := s.es.UpdateByQuery(
+		[]string{indexName},
+		s.es.UpdateByQuery.WithContext(ctx),
+		s.es.UpdateByQuery.WithBody(bytes.NewReader(data)),
+		s.es.UpdateByQuery.WithConflicts("proceed"),
+		s.es.UpdateByQuery.WithWaitForCompletion(false),
+	)
+	if err != nil {
+		return fmt.Errorf("migrate documents request: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return fmt.Errorf("migrate documents error: %s", res.String())
+	}
+	return s.setMigratedVersion(ctx, migrationVersion)
+}
+
+// migratedVersion returns the migration version recorded in the index mapping, or 0 if none is.
+func (s *service) migratedVersion(ctx context.Context) (int, error) {
+	res, err := s.es.Indices.GetMapping(
+		s.es.Indices.GetMapping.WithContext(ctx),
+		s.es.Indices.GetMapping.WithIndex(indexName),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("get mapping request: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return 0, fmt.Errorf("get mapping error: %s", res.String())
+	}
+
+	var parsed map[string]struct {
+		Mappings struct {
+			Meta struct {
+				Migration int `json:"migration"`
+			} `json:"_meta"`
+		} `json:"mappings"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&parsed); err != nil {
+		return 0, fmt.Errorf("decode mapping: %w", err)
+	}
+	// The response is keyed by the concrete index name, which differs if indexName is an alias
+	for _, index := range parsed {
+		return index.Mappings.Meta.Migration, nil
+	}
+	return 0, nil
+}
+
+// setMigratedVersion records the migration version in the index mapping.
+func (s *service) setMigratedVersion(ctx context.Context, version int) error {
+	data, err := json.Marshal(map[string]interface{}{
+		"_meta": map[string]interface{}{"migration": version},
+	})
+	if err != nil {
+		return err
+	}
+
+	res, err := s.es.Indices.PutMapping(
+		[]string{indexName},
+		bytes.NewReader(data),
+		s.es.Indices.PutMapping.WithContext(ctx),
+	)
+	if err != nil {
+		return fmt.Errorf("put mapping request: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return fmt.Errorf("put mapping error: %s", res.String())
+	}
+	return nil
+}
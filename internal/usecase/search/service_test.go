@@ -0,0 +1,73 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/elastic/go-elasticsearch/v8"
+)
+
+func TestMigrate(t *testing.T) {
+	tests := []struct {
+		name      string
+		mapping   string
+		wantCalls []string
+		wantMeta  string
+	}{
+		{
+			name:      "not migrated",
+			mapping:   `{"search_documents":{"mappings":{"properties":{}}}}`,
+			wantCalls: []string{"GET /search_documents/_mapping", "POST /search_documents/_update_by_query", "PUT /search_documents/_mapping"},
+			wantMeta:  `{"_meta":{"migration":1}}`,
+		},
+		{
+			name:      "already migrated",
+			mapping:   `{"search_documents_v2":{"mappings":{"_meta":{"migration":1},"properties":{}}}}`,
+			wantCalls: []string{"GET /search_documents/_mapping"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls []string
+			var meta string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Elastic-Product", "Elasticsearch")
+				w.Header().Set("Content-Type", "application/json")
+				calls = append(calls, r.Method+" "+r.URL.Path)
+
+				switch r.Method {
+				case http.MethodGet:
+					fmt.Fprint(w, tt.mapping)
+				case http.MethodPut:
+					body, _ := io.ReadAll(r.Body)
+					meta = string(body)
+					fmt.Fprint(w, `{"acknowledged":true}`)
+				default:
+					fmt.Fprint(w, `{"task":"node:1"}`)
+				}
+			}))
+			defer srv.Close()
+
+			es, err := elasticsearch.NewClient(elasticsearch.Config{Addresses: []string{srv.URL}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := NewService(es, nil, nil).Migrate(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(calls, tt.wantCalls) {
+				t.Errorf("calls = %q, want %q", calls, tt.wantCalls)
+			}
+			if meta != tt.wantMeta {
+				t.Errorf("mapping update = %s, want %s", meta, tt.wantMeta)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// similarFields are the report text fields that similar reports are compared by.
+var similarFields = []string{"reportText", "impression"}
+
+// moreLikeThis matches the documents sharing the most significant terms of the liked documents or
+// texts. Terms must occur in a few reports to be significant, so that typos and identifiers do not
+// drive the comparison, and a share of them must match, so that a single common finding does not.
+func moreLikeThis(like []interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"more_like_this": map[string]interface{}{
+			"fields":               similarFields,
+			"like":                 like,
+			"min_term_freq":        1,
+			"min_doc_freq":         2,
+			"max_query_terms":      25,
+			"minimum_should_match": "30%",
+		},
+	}
+}
+
+// Similar runs a more_like_this query for the document or text. The document itself is never
+// returned, and reports of its patient are left out on request, since priors of the same patient
+// are usually found through the patient's timeline instead.
+func (s *service) Similar(ctx context.Context, q SimilarQuery) (*Response, error) {
+	if q.ExcludePatient && q.DocumentID == "" {
+		return nil, fmt.Errorf("%w: excluding the patient requires a document ID", ErrInvalidQuery)
+	}
+
+	var like []interface{}
+	mustNot := []map[string]interface{}{}
+
+	switch {
+	case q.DocumentID != "":
+		doc, err := s.Get(ctx, q.DocumentID)
+		if err != nil {
+			return nil, err
+		}
+		like = append(like, map[string]interface{}{"_index": indexName, "_id": q.DocumentID})
+		if q.ExcludePatient && doc.PatientID != "" {
+			mustNot = append(mustNot, map[string]interface{}{"term": map[string]interface{}{"patientId": doc.PatientID}})
+		}
+	case strings.TrimSpace(q.Text) != "":
+		like = append(like, q.Text)
+	default:
+		return nil, fmt.Errorf("%w: a document ID or report text is required", ErrInvalidQuery)
+	}
+
+	queryBody := map[string]interface{}{
+		"from": q.Offset,
+		"size": q.Limit,
+		"query": map[string]interface{}{
+			"bool": map[string]interface{}{
+				"must":     []map[string]interface{}{moreLikeThis(like)},
+				"filter":   buildFilter(q.Query),
+				"must_not": mustNot,
+			},
+		},
+	}
+
+	response, _, err := s.run(ctx, queryBody)
+	if err != nil {
+		return nil, fmt.Errorf("similar reports: %w", err)
+	}
+	return response, nil
+}
@@ -0,0 +1,67 @@
+package search
+
+import (
+	"context"
+	"strings"
+)
+
+// Speller corrects the spelling of searches that match no reports, using a vocabulary of known terms.
+type Speller interface {
+	// Correct returns the spelling correction of a phrase, or an empty string if there is none.
+	Correct(ctx context.Context, text string) (string, error)
+}
+
+// spellField is the unstemmed report text field that spelling corrections are drawn from.
+const spellField = "reportText.spell"
+
+// didYouMeanSuggester corrects the spelling of a search using the words of the indexed reports.
+// Only corrections that match reports are suggested, and words are only replaced by words that
+// are more common in the reports, so that rare but correct words are left alone.
+func didYouMeanSuggester(text string) map[string]interface{} {
+	return map[string]interface{}{
+		"text": text,
+		"didYouMean": map[string]interface{}{
+			"phrase": map[string]interface{}{
+				"field":      spellField,
+				"size":       1,
+				"gram_size":  1,
+				"max_errors": 2,
+				"direct_generator": []map[string]interface{}{
+					{"field": spellField, "suggest_mode": "popular"},
+				},
+				"collate": map[string]interface{}{
+					"query": map[string]interface{}{
+						"source": map[string]interface{}{
+							"match": map[string]interface{}{
+								spellField: map[string]interface{}{"query": "{{suggestion}}", "operator": "and"},
+							},
+						},
+					},
+					"prune": true,
+				},
+			},
+		},
+	}
+}
+
+// suggestResponse is the suggest section of a search response.
+type suggestResponse struct {
+	DidYouMean []struct {
+		Options []struct {
+			Text         string `json:"text"`
+			CollateMatch bool   `json:"collate_match"`
+		} `json:"options"`
+	} `json:"didYouMean"`
+}
+
+// correction returns the best correction of text that matches reports, or an empty string.
+func (r suggestResponse) correction(text string) string {
+	for _, entry := range r.DidYouMean {
+		for _, option := range entry.Options {
+			if option.CollateMatch && !strings.EqualFold(option.Text, text) {
+				return option.Text
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,32 @@
+package terminology
+
+import (
+	"fmt"
+	"strings"
+)
+
+// columns maps the lowercased headers of a delimited release file to their index in a record.
+type columns map[string]int
+
+// newColumns locates the headers of a release file and checks that the required ones are present.
+func newColumns(headers []string, required ...string) (columns, error) {
+	cols := make(columns, len(headers))
+	for i, h := range headers {
+		cols[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	for _, name := range required {
+		if _, ok := cols[strings.ToLower(name)]; !ok {
+			return nil, fmt.Errorf("%w: missing column %q", ErrInvalidInput, name)
+		}
+	}
+	return cols, nil
+}
+
+// get returns the trimmed value of a column in a record, or an empty string if the column is absent.
+func (cols columns) get(record []string, name string) string {
+	i, ok := cols[strings.ToLower(name)]
+	if !ok || i >= len(record) {
+		return ""
+	}
+	return strings.TrimSpace(record[i])
+}
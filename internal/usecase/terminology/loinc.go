@@ -0,0 +1,83 @@
+package terminology
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/yangszwei/koala/internal/domain"
+)
+
+// loincSource is the source of the completion terms imported from LOINC.
+const loincSource = "LOINC"
+
+// parseLOINC reads the LOINC table (Loinc.csv). Only active codes in the requested classes are
+// imported. The term of a code is its display name, or its long common name in releases without
+// display names; the long common name and short name become synonyms and the class the category.
+func parseLOINC(r io.Reader, opts Options, emit func(domain.CompletionTerm) error) error {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	headers, err := reader.Read()
+	if err != nil {
+		return fmt.Errorf("%w: failed to read CSV headers: %v", ErrInvalidInput, err)
+	}
+	cols, err := newColumns(headers, "LOINC_NUM", "LONG_COMMON_NAME")
+	if err != nil {
+		return err
+	}
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read record: %w", err)
+		}
+
+		if status := cols.get(record, "STATUS"); status != "" && !strings.EqualFold(status, "ACTIVE") {
+			continue
+		}
+		class := cols.get(record, "CLASS")
+		if !hasClass(class, opts.Classes) {
+			continue
+		}
+
+		code, longName := cols.get(record, "LOINC_NUM"), cols.get(record, "LONG_COMMON_NAME")
+		name := cols.get(record, "DisplayName")
+		if name == "" {
+			name = longName
+		}
+		if code == "" || name == "" {
+			continue
+		}
+
+		term := domain.CompletionTerm{
+			ID:       TermID(SystemLOINC, code),
+			Term:     name,
+			Category: class,
+			Source:   loincSource,
+			Synonyms: []string{longName, cols.get(record, "SHORTNAME")},
+			System:   SystemLOINC,
+			Code:     code,
+		}
+		if err := emit(term); err != nil {
+			return err
+		}
+	}
+}
+
+// hasClass reports whether a LOINC class starts with one of the prefixes, or whether no prefix is given.
+func hasClass(class string, prefixes []string) bool {
+	if len(prefixes) == 0 {
+		return true
+	}
+	for _, p := range prefixes {
+		if strings.HasPrefix(strings.ToUpper(class), strings.ToUpper(p)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,49 @@
+package terminology
+
+import (
+	"testing"
+
+	"github.com/yangszwei/koala/internal/domain"
+)
+
+func TestParseLOINC(t *testing.T) {
+	const input = `"LOINC_NUM","COMPONENT","CLASS","SHORTNAME","LONG_COMMON_NAME","STATUS","DisplayName"
+"24627-2","Chest","RAD","CT Chest","CT Chest","ACTIVE","CT Chest"
+"30746-2","Chest","RAD","XR Chest 2V","XR Chest 2 Views","ACTIVE",""
+"1234-5","Chest","RAD","Old","Old chest study","DEPRECATED","Old"
+"2345-7","Glucose","CHEM","Glucose SerPl-mCnc","Glucose [Mass/volume] in Serum or Plasma","ACTIVE","Glucose, serum"
+`
+	ct := domain.CompletionTerm{
+		ID:       "loinc:24627-2",
+		Term:     "CT Chest",
+		Category: "RAD",
+		Source:   loincSource,
+		Synonyms: []string{"CT Chest", "CT Chest"},
+		System:   SystemLOINC,
+		Code:     "24627-2",
+	}
+	xr := domain.CompletionTerm{
+		ID:       "loinc:30746-2",
+		Term:     "XR Chest 2 Views",
+		Category: "RAD",
+		Source:   loincSource,
+		Synonyms: []string{"XR Chest 2 Views", "XR Chest 2V"},
+		System:   SystemLOINC,
+		Code:     "30746-2",
+	}
+	glucose := domain.CompletionTerm{
+		ID:       "loinc:2345-7",
+		Term:     "Glucose, serum",
+		Category: "CHEM",
+		Source:   loincSource,
+		Synonyms: []string{"Glucose [Mass/volume] in Serum or Plasma", "Glucose SerPl-mCnc"},
+		System:   SystemLOINC,
+		Code:     "2345-7",
+	}
+
+	runParserTests(t, parseLOINC, []parserTest{
+		{name: "active codes of all classes", input: input, want: []domain.CompletionTerm{ct, xr, glucose}},
+		{name: "class prefixes", input: input, opts: Options{Classes: []string{"rad"}}, want: []domain.CompletionTerm{ct, xr}},
+		{name: "missing required column", input: "LOINC_NUM,CLASS\n24627-2,RAD\n", wantErr: ErrInvalidInput},
+	})
+}
@@ -0,0 +1,163 @@
+package terminology
+
+import (
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/yangszwei/koala/internal/domain"
+)
+
+// radlexSource is the source of the completion terms imported from RadLex.
+const radlexSource = "RadLex"
+
+// radlexCode returns the RID of a RadLex class IRI, e.g., "RID5350" for
+// "http://radlex.org/RID/RID5350".
+func radlexCode(iri string) string {
+	iri = strings.TrimSpace(iri)
+	if i := strings.LastIndexAny(iri, "/#"); i >= 0 {
+		return iri[i+1:]
+	}
+	return iri
+}
+
+// newRadLexTerm builds the completion term of a RadLex class.
+func newRadLexTerm(iri, name string, synonyms, parents []string) domain.CompletionTerm {
+	code := radlexCode(iri)
+	codes := make([]string, 0, len(parents))
+	for _, p := range parents {
+		if c := radlexCode(p); c != "" {
+			codes = append(codes, c)
+		}
+	}
+	return domain.CompletionTerm{
+		ID:       TermID(SystemRadLex, code),
+		Term:     name,
+		Source:   radlexSource,
+		Synonyms: synonyms,
+		System:   SystemRadLex,
+		Code:     code,
+		Parents:  codes,
+	}
+}
+
+// parseRadLexCSV reads the CSV export of RadLex as published on BioPortal. Obsolete classes are skipped.
+func parseRadLexCSV(r io.Reader, _ Options, emit func(domain.CompletionTerm) error) error {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	headers, err := reader.Read()
+	if err != nil {
+		return fmt.Errorf("%w: failed to read CSV headers: %v", ErrInvalidInput, err)
+	}
+	cols, err := newColumns(headers, "Class ID", "Preferred Label")
+	if err != nil {
+		return err
+	}
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read record: %w", err)
+		}
+
+		if strings.EqualFold(cols.get(record, "Obsolete"), "true") {
+			continue
+		}
+		iri, name := cols.get(record, "Class ID"), cols.get(record, "Preferred Label")
+		if radlexCode(iri) == "" || name == "" {
+			continue
+		}
+
+		term := newRadLexTerm(iri, name, splitList(cols.get(record, "Synonyms"), "|"), splitList(cols.get(record, "Parents"), "|"))
+		if err := emit(term); err != nil {
+			return err
+		}
+	}
+}
+
+// owlLiteral is a language-tagged annotation of an OWL class.
+type owlLiteral struct {
+	Lang  string `xml:"lang,attr"`
+	Value string `xml:",chardata"`
+}
+
+// owlClass holds the annotations of an OWL class that are imported.
+type owlClass struct {
+	About         string       `xml:"about,attr"`
+	PreferredName []owlLiteral `xml:"Preferred_name"`
+	Label         []owlLiteral `xml:"label"`
+	Synonym       []owlLiteral `xml:"Synonym"`
+	Deprecated    string       `xml:"deprecated"`
+	SubClassOf    []struct {
+		Resource string `xml:"resource,attr"`
+	} `xml:"subClassOf"`
+}
+
+// english returns the values of the literals in English or without a language.
+func english(literals []owlLiteral) []string {
+	var out []string
+	for _, l := range literals {
+		lang := strings.ToLower(l.Lang)
+		if lang != "" && lang != "en" && !strings.HasPrefix(lang, "en-") {
+			continue
+		}
+		if v := strings.TrimSpace(l.Value); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// parseRadLexOWL streams the classes of the RadLex OWL release. Deprecated classes are skipped.
+func parseRadLexOWL(r io.Reader, _ Options, emit func(domain.CompletionTerm) error) error {
+	decoder := xml.NewDecoder(r)
+	for {
+		tok, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%w: failed to read OWL: %v", ErrInvalidInput, err)
+		}
+
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "Class" {
+			continue
+		}
+
+		var class owlClass
+		if err := decoder.DecodeElement(&class, &start); err != nil {
+			return fmt.Errorf("%w: failed to read OWL class: %v", ErrInvalidInput, err)
+		}
+		// Anonymous classes, such as restrictions, have no IRI
+		if class.About == "" || strings.EqualFold(strings.TrimSpace(class.Deprecated), "true") {
+			continue
+		}
+
+		names := english(class.PreferredName)
+		if len(names) == 0 {
+			names = english(class.Label)
+		}
+		if len(names) == 0 {
+			continue
+		}
+
+		parents := make([]string, 0, len(class.SubClassOf))
+		for _, s := range class.SubClassOf {
+			if s.Resource != "" {
+				parents = append(parents, s.Resource)
+			}
+		}
+
+		term := newRadLexTerm(class.About, names[0], append(names[1:], english(class.Synonym)...), parents)
+		if err := emit(term); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package terminology
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/yangszwei/koala/internal/domain"
+)
+
+// parser is the signature shared by the release file parsers.
+type parser func(r io.Reader, opts Options, emit func(domain.CompletionTerm) error) error
+
+// collect runs a parser on input and returns the emitted terms.
+func collect(parse parser, input string, opts Options) ([]domain.CompletionTerm, error) {
+	var terms []domain.CompletionTerm
+	err := parse(strings.NewReader(input), opts, func(term domain.CompletionTerm) error {
+		terms = append(terms, term)
+		return nil
+	})
+	return terms, err
+}
+
+// parserTest is a case of a parser table test.
+type parserTest struct {
+	name    string
+	input   string
+	opts    Options
+	want    []domain.CompletionTerm
+	wantErr error
+}
+
+// runParserTests runs the cases of a parser table test.
+func runParserTests(t *testing.T, parse parser, tests []parserTest) {
+	t.Helper()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := collect(parse, tt.input, tt.opts)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("terms = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRadLexCSV(t *testing.T) {
+	runParserTests(t, parseRadLexCSV, []parserTest{
+		{
+			name: "classes with synonyms and parents",
+			input: "Class ID,Preferred Label,Synonyms,Obsolete,Parents\n" +
+				"http://radlex.org/RID/RID5350,pleural effusion,hydrothorax|pleural fluid,false,http://radlex.org/RID/RID4994\n" +
+				"http://radlex.org/RID/RID1,retired class,,true,\n" +
+				"http://radlex.org/RID/RID2,,,false,\n",
+			want: []domain.CompletionTerm{{
+				ID:       "radlex:RID5350",
+				Term:     "pleural effusion",
+				Source:   radlexSource,
+				Synonyms: []string{"hydrothorax", "pleural fluid"},
+				System:   SystemRadLex,
+				Code:     "RID5350",
+				Parents:  []string{"RID4994"},
+			}},
+		},
+		{
+			name:    "missing required column",
+			input:   "Class ID,Synonyms\nhttp://radlex.org/RID/RID5350,hydrothorax\n",
+			wantErr: ErrInvalidInput,
+		},
+	})
+}
+
+func TestParseRadLexOWL(t *testing.T) {
+	const header = `<?xml version="1.0"?>
+<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+	xmlns:rdfs="http://www.w3.org/2000/01/rdf-schema#"
+	xmlns:owl="http://www.w3.org/2002/07/owl#"
+	xmlns:radlex="http://radlex.org/RID/">`
+
+	runParserTests(t, parseRadLexOWL, []parserTest{
+		{
+			name: "english names, synonyms and parents",
+			input: header + `
+	<owl:Class rdf:about="http://radlex.org/RID/RID5350">
+		<radlex:Preferred_name xml:lang="en">pleural effusion</radlex:Preferred_name>
+		<radlex:Preferred_name xml:lang="de">Pleuraerguss</radlex:Preferred_name>
+		<radlex:Synonym>hydrothorax</radlex:Synonym>
+		<rdfs:subClassOf rdf:resource="http://radlex.org/RID/RID4994"/>
+		<rdfs:subClassOf>
+			<owl:Restriction/>
+		</rdfs:subClassOf>
+	</owl:Class>
+	<owl:Class rdf:about="http://radlex.org/RID/RID6">
+		<rdfs:label xml:lang="en-US">lung</rdfs:label>
+	</owl:Class>
+</rdf:RDF>`,
+			want: []domain.CompletionTerm{
+				{
+					ID:       "radlex:RID5350",
+					Term:     "pleural effusion",
+					Source:   radlexSource,
+					Synonyms: []string{"hydrothorax"},
+					System:   SystemRadLex,
+					Code:     "RID5350",
+					Parents:  []string{"RID4994"},
+				},
+				{
+					ID:       "radlex:RID6",
+					Term:     "lung",
+					Source:   radlexSource,
+					Synonyms: []string{},
+					System:   SystemRadLex,
+					Code:     "RID6",
+					Parents:  []string{},
+				},
+			},
+		},
+		{
+			name: "deprecated and unnamed classes are skipped",
+			input: header + `
+	<owl:Class rdf:about="http://radlex.org/RID/RID1">
+		<radlex:Preferred_name>retired class</radlex:Preferred_name>
+		<owl:deprecated>true</owl:deprecated>
+	</owl:Class>
+	<owl:Class rdf:about="http://radlex.org/RID/RID2">
+		<rdfs:label xml:lang="fr">poumon</rdfs:label>
+	</owl:Class>
+</rdf:RDF>`,
+		},
+		{
+			name:    "malformed XML",
+			input:   header + `<owl:Class rdf:about="x">`,
+			wantErr: ErrInvalidInput,
+		},
+	})
+}
@@ -0,0 +1,160 @@
+// Package terminology imports the concepts of standard vocabularies, such as RadLex, SNOMED CT
+// and LOINC, as completion terms.
+package terminology
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/yangszwei/koala/internal/domain"
+	"github.com/yangszwei/koala/internal/usecase/completion"
+	"github.com/yangszwei/koala/pkg/iox"
+)
+
+// Format identifies a vocabulary release file.
+type Format string
+
+const (
+	FormatRadLexOWL           Format = "radlex-owl"
+	FormatRadLexCSV           Format = "radlex-csv"
+	FormatSNOMEDDescriptions  Format = "snomed-descriptions"
+	FormatSNOMEDRelationships Format = "snomed-relationships"
+	FormatLOINC               Format = "loinc"
+)
+
+// ParseFormat returns the Format for a format name.
+func ParseFormat(s string) (Format, error) {
+	format := Format(strings.ToLower(strings.TrimSpace(s)))
+	switch format {
+	case FormatRadLexOWL, FormatRadLexCSV, FormatSNOMEDDescriptions, FormatSNOMEDRelationships, FormatLOINC:
+		return format, nil
+	default:
+		return "", fmt.Errorf("%w: unsupported format %s", ErrInvalidInput, s)
+	}
+}
+
+// ErrInvalidInput is returned when a release file cannot be read in the requested format.
+var ErrInvalidInput = errors.New("invalid terminology input")
+
+// Code systems of the supported vocabularies.
+const (
+	SystemRadLex = "http://radlex.org"
+	SystemSNOMED = "http://snomed.info/sct"
+	SystemLOINC  = "http://loinc.org"
+)
+
+// Options configures which concepts of a release file are imported.
+type Options struct {
+	Language string          // Language code of the SNOMED CT descriptions to import; defaults to "en"
+	Concepts map[string]bool // SNOMED CT concepts to import, e.g., read by ReadRefset; nil imports all concepts
+	Classes  []string        // Prefixes of the LOINC classes to import, e.g., "RAD"; empty imports all classes
+}
+
+// Report summarizes the outcome of an import.
+type Report struct {
+	Imported int `json:"imported"` // Number of terms created or updated
+}
+
+// Service defines terminology import operations.
+type Service interface {
+	// Import reads the concepts of a release file and stores them as completion terms. Each
+	// concept is stored under an ID derived from its code, so re-importing a newer release
+	// updates the terms in place.
+	Import(ctx context.Context, r io.Reader, format Format, opts Options) (*Report, error)
+}
+
+// service implements the import operations on top of the completion service.
+type service struct {
+	terms completion.Service
+}
+
+// NewService returns a new instance of the terminology Service.
+func NewService(terms completion.Service) Service {
+	return &service{terms: terms}
+}
+
+// TermID returns the completion term ID of a concept, e.g., "snomed:22298006".
+func TermID(system, code string) string {
+	switch system {
+	case SystemRadLex:
+		return "radlex:" + code
+	case SystemSNOMED:
+		return "snomed:" + code
+	case SystemLOINC:
+		return "loinc:" + code
+	default:
+		return system + "|" + code
+	}
+}
+
+// Import parses the release file and streams its concepts to the completion service. Relationship
+// files only replace the parents of concepts that were already imported from a description file.
+func (s *service) Import(ctx context.Context, r io.Reader, format Format, opts Options) (*Report, error) {
+	r = iox.StripBOM(r)
+
+	var parse func(io.Reader, Options, func(domain.CompletionTerm) error) error
+	switch format {
+	case FormatRadLexOWL:
+		parse = parseRadLexOWL
+	case FormatRadLexCSV:
+		parse = parseRadLexCSV
+	case FormatSNOMEDDescriptions:
+		parse = parseSNOMEDDescriptions
+	case FormatSNOMEDRelationships:
+		parse = parseSNOMEDRelationships
+	case FormatLOINC:
+		parse = parseLOINC
+	default:
+		return nil, fmt.Errorf("%w: unsupported format %s", ErrInvalidInput, format)
+	}
+
+	ch := make(chan domain.CompletionTerm, 1000)
+	errCh := make(chan error, 1)
+
+	go func() {
+		defer close(ch)
+		defer close(errCh)
+		err := parse(r, opts, func(term domain.CompletionTerm) error {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case ch <- term:
+				return nil
+			}
+		})
+		if err != nil {
+			errCh <- err
+		}
+	}()
+
+	var imported int
+	var err error
+	if format == FormatSNOMEDRelationships {
+		imported, err = s.terms.ImportParents(ctx, ch)
+	} else {
+		imported, err = s.terms.Import(ctx, ch)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("import failed: %w", err)
+	}
+
+	if parseErr := <-errCh; parseErr != nil {
+		return nil, parseErr
+	}
+
+	return &Report{Imported: imported}, nil
+}
+
+// splitList splits a list of values separated by sep, dropping empty values.
+func splitList(s, sep string) []string {
+	var out []string
+	for _, v := range strings.Split(s, sep) {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,198 @@
+package terminology
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/yangszwei/koala/internal/domain"
+	"github.com/yangszwei/koala/pkg/iox"
+)
+
+// snomedSource is the source of the completion terms imported from SNOMED CT.
+const snomedSource = "SNOMED CT"
+
+// SNOMED CT concepts identifying description and relationship types.
+const (
+	snomedFSN     = "900000000000003001" // Fully specified name
+	snomedSynonym = "900000000000013009"
+	snomedIsA     = "116680003"
+)
+
+// snomedConcept collects the active descriptions of a concept.
+type snomedConcept struct {
+	fsn      string
+	synonyms []string
+}
+
+// readRF2 reads the rows of a tab-separated RF2 release file. RF2 files are not quoted, so they
+// cannot be read as CSV.
+func readRF2(r io.Reader, required []string, fn func(cols columns, record []string) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return fmt.Errorf("%w: failed to read RF2 headers: %v", ErrInvalidInput, err)
+		}
+		return fmt.Errorf("%w: empty RF2 file", ErrInvalidInput)
+	}
+	cols, err := newColumns(strings.Split(scanner.Text(), "\t"), required...)
+	if err != nil {
+		return err
+	}
+
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+		if err := fn(cols, strings.Split(line, "\t")); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read record: %w", err)
+	}
+	return nil
+}
+
+// ReadRefset reads the active members of an RF2 simple reference set, e.g., a subset of the concepts
+// used in radiology. The result can be used as Options.Concepts.
+func ReadRefset(r io.Reader) (map[string]bool, error) {
+	members := make(map[string]bool)
+	err := readRF2(iox.StripBOM(r), []string{"active", "referencedComponentId"}, func(cols columns, record []string) error {
+		if id := cols.get(record, "referencedComponentId"); id != "" && cols.get(record, "active") == "1" {
+			members[id] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(members) == 0 {
+		return nil, fmt.Errorf("%w: reference set has no active members", ErrInvalidInput)
+	}
+	return members, nil
+}
+
+// splitSemanticTag splits a fully specified name into the name and its semantic tag, e.g.,
+// "Pleural effusion (disorder)" into "Pleural effusion" and "disorder".
+func splitSemanticTag(fsn string) (string, string) {
+	if strings.HasSuffix(fsn, ")") {
+		if i := strings.LastIndex(fsn, " ("); i > 0 {
+			return fsn[:i], fsn[i+2 : len(fsn)-1]
+		}
+	}
+	return fsn, ""
+}
+
+// parseSNOMEDDescriptions reads an RF2 snapshot description file. The active descriptions in the
+// requested language are grouped by concept. RF2 files are not ordered by concept, so the rows are
+// streamed but terms are only emitted once the whole file is read; only the descriptions of the
+// concepts in opts.Concepts are kept, if it is set. The term of a concept is its fully specified
+// name without the semantic tag, which becomes the category; its synonyms become the term's synonyms.
+func parseSNOMEDDescriptions(r io.Reader, opts Options, emit func(domain.CompletionTerm) error) error {
+	language := strings.ToLower(opts.Language)
+	if language == "" {
+		language = "en"
+	}
+
+	concepts := make(map[string]*snomedConcept)
+	err := readRF2(r, []string{"active", "conceptId", "languageCode", "typeId", "term"}, func(cols columns, record []string) error {
+		if cols.get(record, "active") != "1" || strings.ToLower(cols.get(record, "languageCode")) != language {
+			return nil
+		}
+
+		id, text := cols.get(record, "conceptId"), cols.get(record, "term")
+		if id == "" || text == "" || (opts.Concepts != nil && !opts.Concepts[id]) {
+			return nil
+		}
+		concept, ok := concepts[id]
+		if !ok {
+			concept = &snomedConcept{}
+			concepts[id] = concept
+		}
+
+		switch cols.get(record, "typeId") {
+		case snomedFSN:
+			concept.fsn = text
+		case snomedSynonym:
+			concept.synonyms = append(concept.synonyms, text)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	ids := make([]string, 0, len(concepts))
+	for id := range concepts {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		concept := concepts[id]
+		name, tag := splitSemanticTag(concept.fsn)
+		synonyms := concept.synonyms
+		if name == "" {
+			if len(synonyms) == 0 {
+				continue
+			}
+			name, synonyms = synonyms[0], synonyms[1:]
+		}
+
+		term := domain.CompletionTerm{
+			ID:       TermID(SystemSNOMED, id),
+			Term:     name,
+			Category: tag,
+			Source:   snomedSource,
+			Synonyms: synonyms,
+			System:   SystemSNOMED,
+			Code:     id,
+		}
+		if err := emit(term); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseSNOMEDRelationships reads an RF2 snapshot relationship file and emits the parents of each
+// concept, given by its active is-a relationships. The emitted terms only carry an ID and parents.
+// If opts.Concepts is set, only the parents of its concepts are emitted.
+func parseSNOMEDRelationships(r io.Reader, opts Options, emit func(domain.CompletionTerm) error) error {
+	parents := make(map[string][]string)
+	err := readRF2(r, []string{"active", "sourceId", "destinationId", "typeId"}, func(cols columns, record []string) error {
+		if cols.get(record, "active") != "1" || cols.get(record, "typeId") != snomedIsA {
+			return nil
+		}
+		source, destination := cols.get(record, "sourceId"), cols.get(record, "destinationId")
+		if opts.Concepts != nil && !opts.Concepts[source] {
+			return nil
+		}
+		if source != "" && destination != "" {
+			parents[source] = append(parents[source], destination)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	ids := make([]string, 0, len(parents))
+	for id := range parents {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		if err := emit(domain.CompletionTerm{ID: TermID(SystemSNOMED, id), Parents: parents[id]}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
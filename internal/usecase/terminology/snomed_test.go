@@ -0,0 +1,158 @@
+package terminology
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/yangszwei/koala/internal/domain"
+)
+
+const snomedDescriptions = "id\teffectiveTime\tactive\tmoduleId\tconceptId\tlanguageCode\ttypeId\tterm\tcaseSignificanceId\n" +
+	"1\t20240101\t1\t0\t60046008\ten\t900000000000013009\tPleural effusion\t0\n" +
+	"2\t20240101\t1\t0\t60046008\ten\t900000000000003001\tPleural effusion (disorder)\t0\n" +
+	"3\t20240101\t1\t0\t60046008\ten\t900000000000013009\tHydrothorax\t0\n" +
+	"4\t20240101\t0\t0\t60046008\ten\t900000000000013009\tRetired synonym\t0\n" +
+	"5\t20240101\t1\t0\t60046008\tde\t900000000000013009\tPleuraerguss\t0\n" +
+	"6\t20240101\t1\t0\t39607008\ten\t900000000000013009\tLung\t0\r\n" +
+	"7\t20240101\t1\t0\t39607008\ten\t900000000000013009\tLung structure\t0\n"
+
+func TestParseSNOMEDDescriptions(t *testing.T) {
+	effusion := domain.CompletionTerm{
+		ID:       "snomed:60046008",
+		Term:     "Pleural effusion",
+		Category: "disorder",
+		Source:   snomedSource,
+		Synonyms: []string{"Pleural effusion", "Hydrothorax"},
+		System:   SystemSNOMED,
+		Code:     "60046008",
+	}
+	lung := domain.CompletionTerm{
+		ID:       "snomed:39607008",
+		Term:     "Lung",
+		Source:   snomedSource,
+		Synonyms: []string{"Lung structure"},
+		System:   SystemSNOMED,
+		Code:     "39607008",
+	}
+
+	runParserTests(t, parseSNOMEDDescriptions, []parserTest{
+		{
+			name:  "active descriptions grouped by concept",
+			input: snomedDescriptions,
+			want:  []domain.CompletionTerm{lung, effusion},
+		},
+		{
+			name:  "other language",
+			input: snomedDescriptions,
+			opts:  Options{Language: "DE"},
+			want: []domain.CompletionTerm{{
+				ID:       "snomed:60046008",
+				Term:     "Pleuraerguss",
+				Source:   snomedSource,
+				System:   SystemSNOMED,
+				Code:     "60046008",
+				Synonyms: []string{},
+			}},
+		},
+		{
+			name:  "filtered by reference set",
+			input: snomedDescriptions,
+			opts:  Options{Concepts: map[string]bool{"60046008": true}},
+			want:  []domain.CompletionTerm{effusion},
+		},
+		{
+			name:    "missing required column",
+			input:   "id\tconceptId\tterm\n1\t60046008\tPleural effusion\n",
+			wantErr: ErrInvalidInput,
+		},
+		{
+			name:    "empty file",
+			wantErr: ErrInvalidInput,
+		},
+	})
+}
+
+func TestParseSNOMEDRelationships(t *testing.T) {
+	const input = "id\teffectiveTime\tactive\tmoduleId\tsourceId\tdestinationId\trelationshipGroup\ttypeId\n" +
+		"1\t20240101\t1\t0\t60046008\t64572001\t0\t116680003\n" +
+		"2\t20240101\t1\t0\t60046008\t399154005\t0\t116680003\n" +
+		"3\t20240101\t0\t0\t60046008\t1000\t0\t116680003\n" +
+		"4\t20240101\t1\t0\t60046008\t39607008\t0\t363698007\n" +
+		"5\t20240101\t1\t0\t39607008\t91723000\t0\t116680003\n"
+
+	runParserTests(t, parseSNOMEDRelationships, []parserTest{
+		{
+			name:  "active is-a relationships",
+			input: input,
+			want: []domain.CompletionTerm{
+				{ID: "snomed:39607008", Parents: []string{"91723000"}},
+				{ID: "snomed:60046008", Parents: []string{"64572001", "399154005"}},
+			},
+		},
+		{
+			name:  "filtered by reference set",
+			input: input,
+			opts:  Options{Concepts: map[string]bool{"39607008": true}},
+			want:  []domain.CompletionTerm{{ID: "snomed:39607008", Parents: []string{"91723000"}}},
+		},
+	})
+}
+
+func TestReadRefset(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    map[string]bool
+		wantErr error
+	}{
+		{
+			name: "active members",
+			input: "\ufeffid\teffectiveTime\tactive\tmoduleId\trefsetId\treferencedComponentId\n" +
+				"a\t20240101\t1\t0\t1\t60046008\n" +
+				"b\t20240101\t0\t0\t1\t39607008\n" +
+				"c\t20240101\t1\t0\t1\t91723000\r\n",
+			want: map[string]bool{"60046008": true, "91723000": true},
+		},
+		{
+			name:    "no active members",
+			input:   "id\tactive\treferencedComponentId\na\t0\t60046008\n",
+			wantErr: ErrInvalidInput,
+		},
+		{
+			name:    "missing required column",
+			input:   "id\tactive\na\t1\n",
+			wantErr: ErrInvalidInput,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReadRefset(strings.NewReader(tt.input))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("members = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitSemanticTag(t *testing.T) {
+	tests := []struct {
+		fsn, name, tag string
+	}{
+		{"Pleural effusion (disorder)", "Pleural effusion", "disorder"},
+		{"Structure of lung (body structure)", "Structure of lung", "body structure"},
+		{"Lung", "Lung", ""},
+		{"(disorder)", "(disorder)", ""},
+	}
+
+	for _, tt := range tests {
+		if name, tag := splitSemanticTag(tt.fsn); name != tt.name || tag != tt.tag {
+			t.Errorf("splitSemanticTag(%q) = %q, %q, want %q, %q", tt.fsn, name, tag, tt.name, tt.tag)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+// Package thumbnail provides preview images of imaging documents, rendered by the archives the
+// studies are stored in.
+package thumbnail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/yangszwei/koala/internal/usecase/search"
+)
+
+var (
+	// ErrNotFound is returned when no document is indexed under an ID.
+	ErrNotFound = errors.New("document not found")
+	// ErrNoImage is returned when no image can be rendered for a document.
+	ErrNoImage = errors.New("no image available")
+	// ErrInvalidSize is returned when a thumbnail size is out of range.
+	ErrInvalidSize = errors.New("invalid thumbnail size")
+)
+
+// Bounds of the thumbnail sizes, in pixels.
+const (
+	MinSize = 32
+	MaxSize = 512
+)
+
+// sizes are the sizes thumbnails are rendered and cached in, from MinSize to MaxSize. Requested
+// sizes are rounded down to one of them, so that a document has only a few cached thumbnails.
+var sizes = []int{MinSize, 64, 128, 256, MaxSize}
+
+// snapSize returns the largest of sizes that is not larger than size.
+func snapSize(size int) int {
+	snapped := sizes[0]
+	for _, s := range sizes {
+		if s <= size {
+			snapped = s
+		}
+	}
+	return snapped
+}
+
+// Renderer renders representative images of the studies of documents.
+type Renderer interface {
+	// Render returns a JPEG or PNG image of the study of a document that fits in size × size
+	// pixels, or ErrNoImage if no archive holding the study can render it.
+	Render(ctx context.Context, doc search.Document, size int) ([]byte, error)
+}
+
+// Cache stores rendered images.
+type Cache interface {
+	// Get returns the image stored under key.
+	Get(key string) ([]byte, bool)
+	// Put stores an image under key.
+	Put(key string, data []byte) error
+	// Delete removes the image stored under key, if any.
+	Delete(key string) error
+}
+
+// Service defines the thumbnail operations.
+type Service interface {
+	// Get returns the thumbnail of the document with the given ID that fits in size × size pixels.
+	Get(ctx context.Context, id string, size int) ([]byte, error)
+	// Evict removes the cached thumbnails of the document with the given ID, e.g., once it is deleted.
+	Evict(id string) error
+}
+
+// service renders thumbnails on first request and caches them.
+type service struct {
+	docs     search.Service
+	renderer Renderer
+	cache    Cache
+
+	mu      sync.Mutex
+	renders map[string]*render // Renders in progress by cache key
+}
+
+// render is a thumbnail being rendered, shared by the concurrent requests for it.
+type render struct {
+	done chan struct{}
+	data []byte
+	err  error
+}
+
+// NewService returns a new instance of the thumbnail Service.
+func NewService(docs search.Service, renderer Renderer, cache Cache) Service {
+	return &service{docs: docs, renderer: renderer, cache: cache, renders: make(map[string]*render)}
+}
+
+// cacheKey returns the cache key of the thumbnail of a document in a size.
+func cacheKey(id string, size int) string {
+	return fmt.Sprintf("%s@%d", id, size)
+}
+
+// Get returns the cached thumbnail, or renders it if the document is an imaging document. The
+// document is looked up first even if the thumbnail is cached, so that the thumbnails of documents
+// deleted through another replica are not served, and are evicted. Concurrent requests for the same
+// thumbnail share a single render. A thumbnail that cannot be cached is still returned, since it
+// can be rendered again. The thumbnail is rendered in the largest of sizes that fits.
+func (s *service) Get(ctx context.Context, id string, size int) ([]byte, error) {
+	if size < MinSize || size > MaxSize {
+		return nil, ErrInvalidSize
+	}
+	size = snapSize(size)
+
+	doc, err := s.docs.Get(ctx, id)
+	if errors.Is(err, search.ErrNotFound) {
+		if err := s.Evict(id); err != nil {
+			return nil, err
+		}
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get document: %w", err)
+	}
+	if doc.Type != "image" && doc.Type != "report_image" {
+		return nil, ErrNoImage
+	}
+
+	key := cacheKey(id, size)
+	if data, ok := s.cache.Get(key); ok {
+		return data, nil
+	}
+
+	s.mu.Lock()
+	r, ok := s.renders[key]
+	if !ok {
+		r = &render{done: make(chan struct{})}
+		s.renders[key] = r
+	}
+	s.mu.Unlock()
+
+	if ok {
+		select {
+		case <-r.done:
+			return r.data, r.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	// The render is not cancelled with the request that started it, as other requests may wait for it
+	r.data, r.err = s.renderer.Render(context.WithoutCancel(ctx), *doc, size)
+	if r.err == nil {
+		_ = s.cache.Put(key, r.data)
+	}
+
+	s.mu.Lock()
+	delete(s.renders, key)
+	s.mu.Unlock()
+	close(r.done)
+
+	return r.data, r.err
+}
+
+// Evict removes the cached thumbnails of a document in every size.
+func (s *service) Evict(id string) error {
+	for _, size := range sizes {
+		if err := s.cache.Delete(cacheKey(id, size)); err != nil {
+			return fmt.Errorf("evict thumbnail: %w", err)
+		}
+	}
+	return nil
+}
+
+// evictingDocs is a search.Service that evicts the cached thumbnails of the documents it deletes.
+type evictingDocs struct {
+	search.Service
+	thumbnails Service
+}
+
+// EvictOnDelete returns docs with Delete also evicting the cached thumbnails of the deleted
+// document, since they show patient data that must not outlive the document.
+func EvictOnDelete(docs search.Service, thumbnails Service) search.Service {
+	return &evictingDocs{Service: docs, thumbnails: thumbnails}
+}
+
+// Delete removes the document and then its cached thumbnails.
+func (d *evictingDocs) Delete(ctx context.Context, id string) error {
+	if err := d.Service.Delete(ctx, id); err != nil {
+		return err
+	}
+	return d.thumbnails.Evict(id)
+}
@@ -0,0 +1,201 @@
+package thumbnail
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/yangszwei/koala/internal/usecase/search"
+)
+
+// memoryDocs is an in-memory document index.
+type memoryDocs struct {
+	search.Service
+	mu   sync.Mutex
+	docs map[string]search.Document
+}
+
+func (m *memoryDocs) Get(_ context.Context, id string) (*search.Document, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	doc, ok := m.docs[id]
+	if !ok {
+		return nil, search.ErrNotFound
+	}
+	return &doc, nil
+}
+
+func (m *memoryDocs) Delete(_ context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.docs, id)
+	return nil
+}
+
+// memoryCache is an in-memory Cache.
+type memoryCache struct {
+	mu    sync.Mutex
+	blobs map[string][]byte
+}
+
+func (c *memoryCache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	data, ok := c.blobs[key]
+	return data, ok
+}
+
+func (c *memoryCache) Put(key string, data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.blobs[key] = data
+	return nil
+}
+
+func (c *memoryCache) Delete(key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.blobs, key)
+	return nil
+}
+
+// slowRenderer counts renders, which wait until release is closed.
+type slowRenderer struct {
+	renders atomic.Int32
+	release chan struct{}
+}
+
+func (r *slowRenderer) Render(context.Context, search.Document, int) ([]byte, error) {
+	r.renders.Add(1)
+	<-r.release
+	return []byte("jpeg"), nil
+}
+
+func newTestService() (*service, *memoryDocs, *memoryCache, *slowRenderer) {
+	docs := &memoryDocs{docs: map[string]search.Document{
+		"image:pacs:1": {ID: "image:pacs:1", Type: "image"},
+		"report:ris:1": {ID: "report:ris:1", Type: "report"},
+	}}
+	cache := &memoryCache{blobs: make(map[string][]byte)}
+	renderer := &slowRenderer{release: make(chan struct{})}
+	return NewService(docs, renderer, cache).(*service), docs, cache, renderer
+}
+
+func TestGet(t *testing.T) {
+	tests := []struct {
+		name    string
+		id      string
+		size    int
+		cached  bool // Whether a thumbnail is cached before the document is deleted
+		delete  bool
+		wantErr error
+	}{
+		{name: "rendered", id: "image:pacs:1", size: 128},
+		{name: "rendered in a smaller size", id: "image:pacs:1", size: 200},
+		{name: "not an image", id: "report:ris:1", size: 128, wantErr: ErrNoImage},
+		{name: "size out of range", id: "image:pacs:1", size: 1024, wantErr: ErrInvalidSize},
+		{name: "deleted document", id: "image:pacs:1", size: 128, cached: true, delete: true, wantErr: ErrNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, docs, cache, renderer := newTestService()
+			close(renderer.release)
+			if tt.cached {
+				_ = cache.Put(cacheKey(tt.id, tt.size), []byte("jpeg"))
+			}
+			if tt.delete {
+				_ = docs.Delete(context.Background(), tt.id)
+			}
+
+			data, err := svc.Get(context.Background(), tt.id, tt.size)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			_, cached := cache.Get(cacheKey(tt.id, snapSize(tt.size)))
+			if tt.wantErr != nil {
+				if cached {
+					t.Error("thumbnail is cached after failed request")
+				}
+				return
+			}
+			if string(data) != "jpeg" || !cached {
+				t.Errorf("data = %q, cached = %t, want rendered and cached", data, cached)
+			}
+		})
+	}
+}
+
+func TestGetSharesRender(t *testing.T) {
+	svc, _, _, renderer := newTestService()
+
+	const requests = 5
+	var wg sync.WaitGroup
+	errs := make(chan error, requests)
+	for range requests {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := svc.Get(context.Background(), "image:pacs:1", 128)
+			errs <- err
+		}()
+	}
+
+	// Requests arriving after the render finished find the thumbnail in the cache
+	for renderer.renders.Load() == 0 {
+		runtime.Gosched()
+	}
+	close(renderer.release)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := renderer.renders.Load(); n != 1 {
+		t.Errorf("renders = %d, want 1", n)
+	}
+}
+
+func TestEvictOnDelete(t *testing.T) {
+	svc, docs, cache, _ := newTestService()
+	for _, size := range sizes {
+		_ = cache.Put(cacheKey("image:pacs:1", size), []byte("jpeg"))
+	}
+	_ = cache.Put(cacheKey("image:pacs:2", 128), []byte("jpeg"))
+
+	if err := EvictOnDelete(docs, svc).Delete(context.Background(), "image:pacs:1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := docs.Get(context.Background(), "image:pacs:1"); !errors.Is(err, search.ErrNotFound) {
+		t.Errorf("document not deleted: %v", err)
+	}
+	if len(cache.blobs) != 1 {
+		t.Errorf("cached thumbnails = %d, want only the other document's", len(cache.blobs))
+	}
+}
+
+func TestSnapSize(t *testing.T) {
+	tests := []struct {
+		size int
+		want int
+	}{
+		{size: MinSize, want: MinSize},
+		{size: 63, want: 32},
+		{size: 64, want: 64},
+		{size: 200, want: 128},
+		{size: MaxSize, want: MaxSize},
+	}
+
+	for _, tt := range tests {
+		if got := snapSize(tt.size); got != tt.want {
+			t.Errorf("snapSize(%d) = %d, want %d", tt.size, got, tt.want)
+		}
+	}
+}
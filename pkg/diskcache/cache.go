@@ -0,0 +1,176 @@
+// Package diskcache stores blobs as files in a directory, removing the least recently used ones
+// when their total size exceeds a limit.
+package diskcache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Cache is a size-limited cache of blobs on disk. It is safe for concurrent use.
+type Cache struct {
+	dir     string
+	maxSize int64
+	size    int64 // total size of the cached files
+	mu      sync.Mutex
+}
+
+// New opens the cache in dir, creating the directory if needed. Files left by a previous run
+// count towards maxSize.
+func New(dir string, maxSize int64) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create cache directory: %w", err)
+	}
+
+	c := &Cache{dir: dir, maxSize: maxSize}
+	entries, err := c.entries()
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		c.size += e.size
+	}
+	c.evict(entries)
+	return c, nil
+}
+
+// path returns the file a key is stored in.
+func (c *Cache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:]))
+}
+
+// Get returns the blob stored under key and marks it as recently used.
+func (c *Cache) Get(key string) ([]byte, bool) {
+	path := c.path(key)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	now := time.Now()
+	_ = os.Chtimes(path, now, now)
+	return data, true
+}
+
+// Put stores a blob under key, replacing any previous blob, and removes the least recently used
+// blobs if the cache grows beyond its size. Blobs larger than the cache are not stored.
+func (c *Cache) Put(key string, data []byte) error {
+	size := int64(len(data))
+	if size > c.maxSize {
+		return nil
+	}
+
+	tmp, err := os.CreateTemp(c.dir, ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("create cache file: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("write cache file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("write cache file: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	path := c.path(key)
+	if info, err := os.Stat(path); err == nil {
+		c.size -= info.Size()
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("store cache file: %w", err)
+	}
+	c.size += size
+
+	if c.size > c.maxSize {
+		entries, err := c.entries()
+		if err != nil {
+			return err
+		}
+		c.evict(entries)
+	}
+	return nil
+}
+
+// Delete removes the blob stored under key, if any.
+func (c *Cache) Delete(key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	path := c.path(key)
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("stat cache file: %w", err)
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("remove cache file: %w", err)
+	}
+	c.size -= info.Size()
+	return nil
+}
+
+// entry is a cached file.
+type entry struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// entries lists the cached files, ignoring files that are still being written.
+func (c *Cache) entries() ([]entry, error) {
+	dirEntries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return nil, fmt.Errorf("read cache directory: %w", err)
+	}
+
+	entries := make([]entry, 0, len(dirEntries))
+	for _, de := range dirEntries {
+		if de.IsDir() || strings.HasPrefix(de.Name(), ".") {
+			continue
+		}
+		info, err := de.Info()
+		if err != nil {
+			continue
+		}
+		entries = append(entries, entry{
+			path:    filepath.Join(c.dir, de.Name()),
+			size:    info.Size(),
+			modTime: info.ModTime(),
+		})
+	}
+	return entries, nil
+}
+
+// evict removes the least recently used files until the cache fits in its size. It recounts the
+// size from the listed files, so that files removed by other means are accounted for.
+func (c *Cache) evict(entries []entry) {
+	c.size = 0
+	for _, e := range entries {
+		c.size += e.size
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].modTime.Before(entries[j].modTime) })
+	for _, e := range entries {
+		if c.size <= c.maxSize {
+			return
+		}
+		if err := os.Remove(e.path); err == nil || os.IsNotExist(err) {
+			c.size -= e.size
+		}
+	}
+}
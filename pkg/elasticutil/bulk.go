@@ -9,7 +9,19 @@ import (
 	"github.com/elastic/go-elasticsearch/v8"
 )
 
-// BulkInsertChan reads from a channel of docs, batching and sending to ES.
+// BulkError reports the items of bulk requests that Elasticsearch rejected.
+type BulkError struct {
+	Failed int    // Number of rejected items
+	Reason string // Reason the first item was rejected for
+}
+
+func (e *BulkError) Error() string {
+	return fmt.Sprintf("%d bulk items failed: %s", e.Failed, e.Reason)
+}
+
+// BulkInsertChan reads from a channel of docs, batching and sending to ES. It returns the number
+// of docs ES indexed. Docs that ES rejects do not stop the remaining batches; they are reported
+// by a *BulkError once the channel is drained.
 func BulkInsertChan[T any](
 	ctx context.Context,
 	es *elasticsearch.Client,
@@ -17,56 +29,147 @@ func BulkInsertChan[T any](
 	ch <-chan T,
 	idFunc func(T) string,
 	batchSize int,
-) error {
+) (int, error) {
+	return bulkChan(ctx, es, indexName, ch, idFunc, batchSize, indexAction, false)
+}
+
+// BulkUpdateChan reads from a channel of partial docs, batching and sending them to ES as updates.
+// Fields missing from a doc keep their stored values. If upsert is true, docs that do not exist yet
+// are created; otherwise updates of missing docs are skipped. It returns the number of docs ES
+// created or updated, and reports rejected docs like BulkInsertChan.
+func BulkUpdateChan[T any](
+	ctx context.Context,
+	es *elasticsearch.Client,
+	indexName string,
+	ch <-chan T,
+	idFunc func(T) string,
+	batchSize int,
+	upsert bool,
+) (int, error) {
+	return bulkChan(ctx, es, indexName, ch, idFunc, batchSize, func(doc T) (string, interface{}) {
+		return "update", map[string]interface{}{"doc": doc, "doc_as_upsert": upsert}
+	}, !upsert)
+}
+
+// bulkAction is the action of a bulk request and the source line that follows its metadata line.
+type bulkAction[T any] func(doc T) (string, interface{})
+
+// indexAction replaces the whole document.
+func indexAction[T any](doc T) (string, interface{}) {
+	return "index", doc
+}
+
+// bulkChan batches docs from a channel and sends each batch with the given action. If skipMissing
+// is true, items that fail because their doc does not exist are neither counted nor errors.
+func bulkChan[T any](
+	ctx context.Context,
+	es *elasticsearch.Client,
+	indexName string,
+	ch <-chan T,
+	idFunc func(T) string,
+	batchSize int,
+	action bulkAction[T],
+	skipMissing bool,
+) (int, error) {
 	var batch []T
+	var total bulkOutcome
+
+	send := func() error {
+		outcome, err := sendBatch(ctx, es, indexName, batch, idFunc, action, skipMissing)
+		total.add(outcome)
+		batch = batch[:0]
+		return err
+	}
 
 	for {
 		select {
 		case <-ctx.Done():
-			return ctx.Err()
+			return total.succeeded, ctx.Err()
 		case doc, ok := <-ch:
 			if !ok {
 				if len(batch) > 0 {
-					return sendBatch(ctx, es, indexName, batch, idFunc)
+					if err := send(); err != nil {
+						return total.succeeded, err
+					}
 				}
-				return nil
+				return total.succeeded, total.err()
 			}
 
 			batch = append(batch, doc)
 			if len(batch) >= batchSize {
-				if err := sendBatch(ctx, es, indexName, batch, idFunc); err != nil {
-					return err
+				if err := send(); err != nil {
+					return total.succeeded, err
 				}
-				batch = batch[:0]
 			}
 		}
 	}
 }
 
-// sendBatch converts a slice of documents into a bulk API request.
+// bulkOutcome counts the items of bulk requests by their result.
+type bulkOutcome struct {
+	succeeded int
+	failed    int
+	reason    string // reason of the first failed item
+}
+
+// add merges the counts of another outcome.
+func (o *bulkOutcome) add(other bulkOutcome) {
+	o.succeeded += other.succeeded
+	o.failed += other.failed
+	if o.reason == "" {
+		o.reason = other.reason
+	}
+}
+
+// err returns a *BulkError if any item failed.
+func (o *bulkOutcome) err() error {
+	if o.failed == 0 {
+		return nil
+	}
+	return &BulkError{Failed: o.failed, Reason: o.reason}
+}
+
+// bulkResponse is the response of a bulk request. Each item is keyed by its action.
+type bulkResponse struct {
+	Errors bool `json:"errors"`
+	Items  []map[string]struct {
+		ID     string `json:"_id"`
+		Status int    `json:"status"`
+		Error  *struct {
+			Type   string `json:"type"`
+			Reason string `json:"reason"`
+		} `json:"error"`
+	} `json:"items"`
+}
+
+// sendBatch converts a slice of documents into a bulk API request and counts the items that ES
+// applied and rejected. An error is only returned if the request itself failed.
 func sendBatch[T any](
 	ctx context.Context,
 	es *elasticsearch.Client,
 	index string,
 	batch []T,
 	idFunc func(T) string,
-) error {
+	action bulkAction[T],
+	skipMissing bool,
+) (bulkOutcome, error) {
 	var buf bytes.Buffer
 
 	for _, doc := range batch {
+		name, source := action(doc)
 		meta := map[string]map[string]string{
-			"index": {
+			name: {
 				"_index": index,
 				"_id":    idFunc(doc),
 			},
 		}
 		metaLine, err := json.Marshal(meta)
 		if err != nil {
-			return fmt.Errorf("marshal meta: %w", err)
+			return bulkOutcome{}, fmt.Errorf("marshal meta: %w", err)
 		}
-		docLine, err := json.Marshal(doc)
+		docLine, err := json.Marshal(source)
 		if err != nil {
-			return fmt.Errorf("marshal doc: %w", err)
+			return bulkOutcome{}, fmt.Errorf("marshal doc: %w", err)
 		}
 		buf.Write(metaLine)
 		buf.WriteByte('\n')
@@ -76,13 +179,40 @@ func sendBatch[T any](
 
 	res, err := es.Bulk(bytes.NewReader(buf.Bytes()), es.Bulk.WithContext(ctx))
 	if err != nil {
-		return fmt.Errorf("bulk request failed: %w", err)
+		return bulkOutcome{}, fmt.Errorf("bulk request failed: %w", err)
 	}
 	defer res.Body.Close()
 
 	if res.IsError() {
-		return fmt.Errorf("bulk insert error: %s", res.String())
+		return bulkOutcome{}, fmt.Errorf("bulk insert error: %s", res.String())
+	}
+
+	var parsed bulkResponse
+	if err := json.NewDecoder(res.Body).Decode(&parsed); err != nil {
+		return bulkOutcome{}, fmt.Errorf("decode bulk response: %w", err)
 	}
 
-	return nil
+	var outcome bulkOutcome
+	if !parsed.Errors {
+		outcome.succeeded = len(parsed.Items)
+		return outcome, nil
+	}
+	for _, item := range parsed.Items {
+		for _, result := range item {
+			switch {
+			case result.Error == nil && result.Status >= 200 && result.Status < 300:
+				outcome.succeeded++
+			case skipMissing && result.Status == 404:
+				// The doc to update does not exist
+			default:
+				outcome.failed++
+				if outcome.reason == "" && result.Error != nil {
+					outcome.reason = fmt.Sprintf("%s: %s: %s", result.ID, result.Error.Type, result.Error.Reason)
+				} else if outcome.reason == "" {
+					outcome.reason = fmt.Sprintf("%s: status %d", result.ID, result.Status)
+				}
+			}
+		}
+	}
+	return outcome, nil
 }
@@ -0,0 +1,146 @@
+package elasticutil
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/elastic/go-elasticsearch/v8"
+)
+
+// newBulkServer returns a client of a fake Elasticsearch that answers each bulk item with the
+// status returned by status for its ID.
+func newBulkServer(t *testing.T, status func(id string) int) *elasticsearch.Client {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+
+		var items []string
+		hasErrors := false
+		scanner := bufio.NewScanner(r.Body)
+		for line := 0; scanner.Scan(); line++ {
+			if line%2 == 1 {
+				continue // Source line
+			}
+			meta := scanner.Text()
+			action := meta[2:strings.Index(meta, `":`)]
+			id := meta[strings.Index(meta, `"_id":"`)+7:]
+			id = id[:strings.Index(id, `"`)]
+
+			code := status(id)
+			if code >= 300 {
+				hasErrors = true
+				items = append(items, fmt.Sprintf(`{%q:{"_id":%q,"status":%d,"error":{"type":"test_exception","reason":"rejected %s"}}}`, action, id, code, id))
+			} else {
+				items = append(items, fmt.Sprintf(`{%q:{"_id":%q,"status":%d}}`, action, id, code))
+			}
+		}
+		fmt.Fprintf(w, `{"errors":%t,"items":[%s]}`, hasErrors, strings.Join(items, ","))
+	}))
+	t.Cleanup(srv.Close)
+
+	es, err := elasticsearch.NewClient(elasticsearch.Config{Addresses: []string{srv.URL}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return es
+}
+
+// feed returns a closed channel holding the IDs.
+func feed(ids ...string) <-chan string {
+	ch := make(chan string, len(ids))
+	for _, id := range ids {
+		ch <- id
+	}
+	close(ch)
+	return ch
+}
+
+func TestBulkInsertChan(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    func(id string) int
+		ids       []string
+		succeeded int
+		failed    int
+	}{
+		{
+			name:      "all indexed",
+			status:    func(string) int { return 201 },
+			ids:       []string{"a", "b", "c"},
+			succeeded: 3,
+		},
+		{
+			name: "rejected items across batches",
+			status: func(id string) int {
+				if id == "b" || id == "d" {
+					return 400
+				}
+				return 200
+			},
+			ids:       []string{"a", "b", "c", "d", "e"},
+			succeeded: 3,
+			failed:    2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			es := newBulkServer(t, tt.status)
+			n, err := BulkInsertChan(context.Background(), es, "test", feed(tt.ids...), func(id string) string { return id }, 2)
+
+			if n != tt.succeeded {
+				t.Errorf("succeeded = %d, want %d", n, tt.succeeded)
+			}
+			var bulkErr *BulkError
+			switch {
+			case tt.failed == 0 && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case tt.failed > 0 && !errors.As(err, &bulkErr):
+				t.Errorf("error = %v, want *BulkError", err)
+			case tt.failed > 0 && bulkErr.Failed != tt.failed:
+				t.Errorf("failed = %d, want %d", bulkErr.Failed, tt.failed)
+			case tt.failed > 0 && !strings.Contains(bulkErr.Reason, "rejected b"):
+				t.Errorf("reason = %q, want the first rejected item", bulkErr.Reason)
+			}
+		})
+	}
+}
+
+func TestBulkUpdateChanMissing(t *testing.T) {
+	status := func(id string) int {
+		if id == "missing" {
+			return 404
+		}
+		return 200
+	}
+
+	t.Run("skipped without upsert", func(t *testing.T) {
+		es := newBulkServer(t, status)
+		n, err := BulkUpdateChan(context.Background(), es, "test", feed("a", "missing", "b"), func(id string) string { return id }, 10, false)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if n != 2 {
+			t.Errorf("updated = %d, want 2", n)
+		}
+	})
+
+	t.Run("failed with upsert", func(t *testing.T) {
+		es := newBulkServer(t, status)
+		n, err := BulkUpdateChan(context.Background(), es, "test", feed("a", "missing"), func(id string) string { return id }, 10, true)
+		var bulkErr *BulkError
+		if !errors.As(err, &bulkErr) || bulkErr.Failed != 1 {
+			t.Fatalf("error = %v, want 1 failed item", err)
+		}
+		if n != 1 {
+			t.Errorf("updated = %d, want 1", n)
+		}
+	})
+}
@@ -0,0 +1,101 @@
+package elasticutil
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8"
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+)
+
+// scrollKeepAlive is how long Elasticsearch keeps a scroll open between pages.
+const scrollKeepAlive = time.Minute
+
+// Scroll searches an index with a query body, which may be nil to match all docs, and calls fn with
+// the hits of each page of size hits in the given sort order. Hits are decoded into T, e.g., a struct
+// with a "_source" field. It stops at the first error returned by fn, and clears the scroll when done.
+func Scroll[T any](
+	ctx context.Context,
+	es *elasticsearch.Client,
+	indexName string,
+	body interface{},
+	sort string,
+	size int,
+	fn func([]T) error,
+) error {
+	opts := []func(*esapi.SearchRequest){
+		es.Search.WithContext(ctx),
+		es.Search.WithIndex(indexName),
+		es.Search.WithSort(sort),
+		es.Search.WithSize(size),
+		es.Search.WithScroll(scrollKeepAlive),
+	}
+	if body != nil {
+		var buf bytes.Buffer
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			return fmt.Errorf("encode scroll query: %w", err)
+		}
+		opts = append(opts, es.Search.WithBody(&buf))
+	}
+
+	res, err := es.Search(opts...)
+	if err != nil {
+		return fmt.Errorf("scroll request: %w", err)
+	}
+
+	var scrollID string
+	defer func() {
+		if scrollID != "" {
+			res, err := es.ClearScroll(es.ClearScroll.WithScrollID(scrollID))
+			if err == nil {
+				res.Body.Close()
+			}
+		}
+	}()
+
+	for {
+		hits, next, err := decodeScrollPage[T](res)
+		if err != nil {
+			return err
+		}
+		scrollID = next
+		if len(hits) == 0 {
+			return nil
+		}
+		if err := fn(hits); err != nil {
+			return err
+		}
+
+		res, err = es.Scroll(
+			es.Scroll.WithContext(ctx),
+			es.Scroll.WithScrollID(scrollID),
+			es.Scroll.WithScroll(scrollKeepAlive),
+		)
+		if err != nil {
+			return fmt.Errorf("scroll request: %w", err)
+		}
+	}
+}
+
+// decodeScrollPage reads the hits and scroll ID of a page of scroll results and closes the response.
+func decodeScrollPage[T any](res *esapi.Response) ([]T, string, error) {
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return nil, "", fmt.Errorf("scroll error: %s", res.String())
+	}
+
+	var parsed struct {
+		ScrollID string `json:"_scroll_id"`
+		Hits     struct {
+			Hits []T `json:"hits"`
+		} `json:"hits"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&parsed); err != nil {
+		return nil, "", fmt.Errorf("decode scroll response: %w", err)
+	}
+	return parsed.Hits.Hits, parsed.ScrollID, nil
+}
@@ -0,0 +1,95 @@
+package elasticutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/elastic/go-elasticsearch/v8"
+)
+
+// newScrollServer returns a client of a fake Elasticsearch that serves the pages of hits with the
+// given IDs in turn, and counts the cleared scrolls.
+func newScrollServer(t *testing.T, pages [][]string, cleared *int) *elasticsearch.Client {
+	t.Helper()
+	page := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+
+		if r.Method == http.MethodDelete {
+			*cleared++
+			fmt.Fprint(w, `{"succeeded":true}`)
+			return
+		}
+
+		var hits []string
+		if page < len(pages) {
+			for _, id := range pages[page] {
+				hits = append(hits, fmt.Sprintf(`{"_id":%q,"_source":{"id":%q}}`, id, id))
+			}
+		}
+		page++
+		fmt.Fprintf(w, `{"_scroll_id":"s1","hits":{"hits":[%s]}}`, strings.Join(hits, ","))
+	}))
+	t.Cleanup(srv.Close)
+
+	es, err := elasticsearch.NewClient(elasticsearch.Config{Addresses: []string{srv.URL}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return es
+}
+
+func TestScroll(t *testing.T) {
+	errStop := errors.New("stop")
+	tests := []struct {
+		name    string
+		pages   [][]string
+		stop    string // ID after which fn stops the scroll
+		want    []string
+		wantErr error
+	}{
+		{name: "no hits"},
+		{name: "all pages", pages: [][]string{{"a", "b"}, {"c"}}, want: []string{"a", "b", "c"}},
+		{name: "stopped by fn", pages: [][]string{{"a", "b"}, {"c"}}, stop: "b", want: []string{"a", "b"}, wantErr: errStop},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cleared := 0
+			es := newScrollServer(t, tt.pages, &cleared)
+
+			type hit struct {
+				Source struct {
+					ID string `json:"id"`
+				} `json:"_source"`
+			}
+			var got []string
+			err := Scroll(context.Background(), es, "docs", nil, "_doc", 2, func(hits []hit) error {
+				for _, h := range hits {
+					got = append(got, h.Source.ID)
+					if h.Source.ID == tt.stop {
+						return errStop
+					}
+				}
+				return nil
+			})
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("hits = %v, want %v", got, tt.want)
+			}
+			if cleared != 1 {
+				t.Errorf("cleared scrolls = %d, want 1", cleared)
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package hl7
+
+import (
+	"strings"
+	"time"
+)
+
+// Acknowledgement codes used in MSA-1.
+const (
+	AckAccept = "AA" // Message accepted
+	AckError  = "AE" // Message could not be processed
+	AckReject = "AR" // Message rejected
+)
+
+// ACK builds an acknowledgement message for m with the given code and optional error text.
+// The sending and receiving applications of the original message are swapped.
+func ACK(m *Message, code, text string) []byte {
+	fs := string(m.fieldSep)
+	encoding := string([]byte{m.componentSep, m.repeatSep, m.escapeChar, m.subSep})
+
+	msh := []string{
+		"MSH", encoding,
+		rawField(m, 5), rawField(m, 6), // sending application and facility
+		rawField(m, 3), rawField(m, 4), // receiving application and facility
+		time.Now().Format("20060102150405"),
+		"",
+		"ACK" + string(m.componentSep) + m.Get("MSH-9.2"),
+		m.ControlID() + "-ACK",
+		rawField(m, 11),
+		rawField(m, 12),
+	}
+	msa := []string{"MSA", code, m.ControlID(), strings.NewReplacer("\r", " ", "\n", " ", fs, " ").Replace(text)}
+
+	return []byte(strings.Join(msh, fs) + "\r" + strings.Join(msa, fs) + "\r")
+}
+
+// rawField returns an MSH field without unescaping or splitting.
+func rawField(m *Message, field int) string {
+	for _, s := range m.Segments {
+		if s.Name() == "MSH" && field < len(s) {
+			return s[field]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,186 @@
+// Package hl7 provides a minimal HL7 v2 message parser, MLLP framing, and acknowledgement builder.
+package hl7
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Segment is a single segment of a message, split into its fields. Field 0 is the segment name.
+// For MSH segments the field separator itself is MSH-1, so field numbers match the standard.
+type Segment []string
+
+// Name returns the segment identifier (e.g., "PID").
+func (s Segment) Name() string {
+	if len(s) == 0 {
+		return ""
+	}
+	return s[0]
+}
+
+// Message is a parsed HL7 v2 message.
+type Message struct {
+	Segments []Segment
+
+	fieldSep     byte
+	componentSep byte
+	repeatSep    byte
+	escapeChar   byte
+	subSep       byte
+}
+
+// Parse parses an HL7 v2 message. Segments may be terminated by CR, LF or CRLF.
+// The message must start with an MSH segment that declares the encoding characters.
+func Parse(data []byte) (*Message, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) < 8 || !bytes.HasPrefix(data, []byte("MSH")) {
+		return nil, fmt.Errorf("message does not start with an MSH segment")
+	}
+
+	m := &Message{
+		fieldSep:     data[3],
+		componentSep: data[4],
+		repeatSep:    data[5],
+		escapeChar:   data[6],
+		subSep:       data[7],
+	}
+
+	lines := strings.FieldsFunc(string(data), func(r rune) bool { return r == '\r' || r == '\n' })
+	for _, line := range lines {
+		if line = strings.TrimSpace(line); line == "" {
+			continue
+		}
+		fields := strings.Split(line, string(m.fieldSep))
+		if fields[0] == "MSH" {
+			// MSH-1 is the field separator itself, so insert it to keep numbering aligned
+			fields = append([]string{"MSH", string(m.fieldSep)}, fields[1:]...)
+		}
+		m.Segments = append(m.Segments, fields)
+	}
+
+	return m, nil
+}
+
+// Type returns the message type and trigger event from MSH-9 (e.g., "ORU^R01").
+func (m *Message) Type() string {
+	return m.Get("MSH-9.1") + "^" + m.Get("MSH-9.2")
+}
+
+// ControlID returns the message control ID from MSH-10.
+func (m *Message) ControlID() string {
+	return m.Get("MSH-10")
+}
+
+// Bytes encodes the message again, with segments terminated by CR. Parse of the result returns
+// an equal message.
+func (m *Message) Bytes() []byte {
+	fs := string(m.fieldSep)
+	var buf bytes.Buffer
+	for _, s := range m.Segments {
+		if s.Name() == "MSH" && len(s) > 1 {
+			// MSH-1 is the field separator itself and is not separated from MSH-2
+			buf.WriteString("MSH" + fs + strings.Join(s[2:], fs))
+		} else {
+			buf.WriteString(strings.Join(s, fs))
+		}
+		buf.WriteByte('\r')
+	}
+	return buf.Bytes()
+}
+
+// Get returns the value at the given path in the first segment that has it, e.g. "PID-3.1".
+// A path without a component returns the whole field with component separators intact.
+func (m *Message) Get(path string) string {
+	values := m.GetAll(path)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+// GetAll returns the non-empty values at the given path across all segments of that type.
+// Only the first repetition of each field is considered.
+func (m *Message) GetAll(path string) []string {
+	seg, field, component, sub, err := parsePath(path)
+	if err != nil {
+		return nil
+	}
+
+	var values []string
+	for _, s := range m.Segments {
+		if s.Name() != seg || field >= len(s) {
+			continue
+		}
+		value := s[field]
+		if seg != "MSH" || field > 2 {
+			value = strings.SplitN(value, string(m.repeatSep), 2)[0]
+		}
+		if component > 0 {
+			value = nth(value, m.componentSep, component)
+			if sub > 0 {
+				value = nth(value, m.subSep, sub)
+			}
+		}
+		if value = m.unescape(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// ComponentSeparator returns the component separator declared in MSH-2.
+func (m *Message) ComponentSeparator() byte {
+	return m.componentSep
+}
+
+// SubcomponentSeparator returns the subcomponent separator declared in MSH-2.
+func (m *Message) SubcomponentSeparator() byte {
+	return m.subSep
+}
+
+// unescape replaces the standard HL7 escape sequences in a value.
+func (m *Message) unescape(s string) string {
+	esc := string(m.escapeChar)
+	if !strings.Contains(s, esc) {
+		return s
+	}
+	r := strings.NewReplacer(
+		esc+"F"+esc, string(m.fieldSep),
+		esc+"S"+esc, string(m.componentSep),
+		esc+"R"+esc, string(m.repeatSep),
+		esc+"T"+esc, string(m.subSep),
+		esc+"E"+esc, esc,
+		esc+".br"+esc, "\n",
+	)
+	return r.Replace(s)
+}
+
+// parsePath splits a path such as "OBR-4.2" into segment, field, component and subcomponent.
+func parsePath(path string) (seg string, field, component, sub int, err error) {
+	name, rest, ok := strings.Cut(path, "-")
+	if !ok || len(name) != 3 {
+		return "", 0, 0, 0, fmt.Errorf("invalid path %q", path)
+	}
+	parts := strings.Split(rest, ".")
+	nums := make([]int, 3)
+	for i, p := range parts {
+		if i >= len(nums) {
+			return "", 0, 0, 0, fmt.Errorf("invalid path %q", path)
+		}
+		if nums[i], err = strconv.Atoi(p); err != nil || nums[i] < 1 {
+			return "", 0, 0, 0, fmt.Errorf("invalid path %q", path)
+		}
+	}
+	return strings.ToUpper(name), nums[0], nums[1], nums[2], nil
+}
+
+// nth returns the n-th (1-based) part of s split by sep.
+func nth(s string, sep byte, n int) string {
+	parts := strings.Split(s, string(sep))
+	if n > len(parts) {
+		return ""
+	}
+	return parts[n-1]
+}
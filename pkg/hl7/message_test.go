@@ -0,0 +1,137 @@
+package hl7
+
+import (
+	"reflect"
+	"testing"
+)
+
+// oru is an ORU^R01 result using the standard encoding characters.
+const oru = "MSH|^~\\&|RIS|HOSP|KOALA|HOSP|20240101120000||ORU^R01^ORU_R01|MSG001|P|2.5\r" +
+	"PID|1||P1^^^HOSP~P2^^^OTHER||DOE^JANE^Q||19800101|F\r" +
+	"OBR|1|PLAC1|FILL1^RIS|CTCHEST^CT Chest^L&LOCAL\r" +
+	"OBX|1|TX|IMP||Nodule 5 mm \\T\\ stable\\.br\\No effusion||||||F\r" +
+	"OBX|2|TX|IMP||Ratio 1\\S\\2 \\F\\ \\R\\ \\E\\||||||F\r"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		wantErr  bool
+		segments []string
+	}{
+		{name: "carriage returns", data: oru, segments: []string{"MSH", "PID", "OBR", "OBX", "OBX"}},
+		{name: "line feeds and blank lines", data: "MSH|^~\\&|A\n\nPID|1\r\nOBR|1\n", segments: []string{"MSH", "PID", "OBR"}},
+		{name: "surrounding whitespace", data: "\n  MSH|^~\\&|A\rPID|1\r\n", segments: []string{"MSH", "PID"}},
+		{name: "no MSH segment", data: "PID|1||P1", wantErr: true},
+		{name: "truncated MSH segment", data: "MSH|^~", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := Parse([]byte(tt.data))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, s := range m.Segments {
+				names = append(names, s.Name())
+			}
+			if !reflect.DeepEqual(names, tt.segments) {
+				t.Errorf("segments = %v, want %v", names, tt.segments)
+			}
+		})
+	}
+}
+
+func TestMessageGet(t *testing.T) {
+	m, err := Parse([]byte(oru))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"MSH-1", "|"},
+		{"MSH-2", "^~\\&"},
+		{"MSH-9", "ORU^R01^ORU_R01"},
+		{"MSH-9.2", "R01"},
+		{"MSH-10", "MSG001"},
+		{"PID-3", "P1^^^HOSP"},
+		{"PID-3.4", "HOSP"},
+		{"PID-5.2", "JANE"},
+		{"pid-5.1", "DOE"},
+		{"OBR-4.3", "L&LOCAL"},
+		{"OBR-4.3.2", "LOCAL"},
+		{"OBX-5", "Nodule 5 mm & stable\nNo effusion"},
+		{"OBR-40", ""},
+		{"ZZZ-1", ""},
+		{"PID", ""},
+		{"PID-0", ""},
+		{"PID-1.2.3.4", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := m.Get(tt.path); got != tt.want {
+				t.Errorf("Get(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+
+	if got := m.Type(); got != "ORU^R01" {
+		t.Errorf("Type() = %q, want ORU^R01", got)
+	}
+	if got, want := m.GetAll("OBX-5"), []string{"Nodule 5 mm & stable\nNo effusion", "Ratio 1^2 | ~ \\"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("GetAll(OBX-5) = %q, want %q", got, want)
+	}
+}
+
+func TestMessageEscapes(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{name: "standard encoding characters", data: "MSH|^~\\&|A\rNTE|1||a\\F\\b\\S\\c\\R\\d\\T\\e\\E\\f\\.br\\g", want: "a|b^c~d&e\\f\ng"},
+		{name: "custom encoding characters", data: "MSH#:*!@#A\rNTE#1##a!F!b!S!c!R!d!T!e!E!f", want: "a#b:c*d@e!f"},
+		{name: "unknown escape kept", data: "MSH|^~\\&|A\rNTE|1||\\X0D\\", want: "\\X0D\\"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := Parse([]byte(tt.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := m.Get("NTE-3"); got != tt.want {
+				t.Errorf("NTE-3 = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMessageBytes(t *testing.T) {
+	m, err := Parse([]byte(oru))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(m.Bytes()); got != oru {
+		t.Errorf("Bytes() = %q, want %q", got, oru)
+	}
+
+	again, err := Parse(m.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(again, m) {
+		t.Errorf("parsed Bytes() = %+v, want %+v", again, m)
+	}
+}
@@ -0,0 +1,51 @@
+package hl7
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// MLLP framing characters.
+const (
+	startBlock = 0x0B
+	endBlock   = 0x1C
+	carriageCR = 0x0D
+)
+
+// ReadFrame reads a single MLLP-framed message and returns its content without framing characters.
+// Any data before the start block is discarded.
+func ReadFrame(r *bufio.Reader) ([]byte, error) {
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if b == startBlock {
+			break
+		}
+	}
+
+	data, err := r.ReadBytes(endBlock)
+	if err != nil {
+		if err == io.EOF {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	if b, err := r.ReadByte(); err != nil || b != carriageCR {
+		return nil, fmt.Errorf("mllp frame not terminated by carriage return")
+	}
+
+	return data[:len(data)-1], nil
+}
+
+// WriteFrame writes a message wrapped in MLLP framing characters.
+func WriteFrame(w io.Writer, data []byte) error {
+	frame := make([]byte, 0, len(data)+3)
+	frame = append(frame, startBlock)
+	frame = append(frame, data...)
+	frame = append(frame, endBlock, carriageCR)
+	_, err := w.Write(frame)
+	return err
+}
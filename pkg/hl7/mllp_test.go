@@ -0,0 +1,60 @@
+package hl7
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestReadFrame(t *testing.T) {
+	tests := []struct {
+		name    string
+		stream  string
+		want    []string
+		wantErr error
+	}{
+		{name: "single frame", stream: "\x0bMSH|^~\\&\r\x1c\r", want: []string{"MSH|^~\\&\r"}},
+		{name: "noise before start block", stream: "junk\x0bA\x1c\r\x0bB\x1c\r", want: []string{"A", "B"}},
+		{name: "missing end block", stream: "\x0bMSH|^~\\&", wantErr: io.ErrUnexpectedEOF},
+		{name: "missing carriage return", stream: "\x0bA\x1cX"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := bufio.NewReader(strings.NewReader(tt.stream))
+			for _, want := range tt.want {
+				got, err := ReadFrame(r)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(got) != want {
+					t.Errorf("frame = %q, want %q", got, want)
+				}
+			}
+			if tt.want != nil {
+				return
+			}
+			_, err := ReadFrame(r)
+			if err == nil || (tt.wantErr != nil && !errors.Is(err, tt.wantErr)) {
+				t.Errorf("error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWriteFrame(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteFrame(&buf, []byte("MSH|^~\\&\r")); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadFrame(bufio.NewReader(&buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "MSH|^~\\&\r" {
+		t.Errorf("round trip = %q", got)
+	}
+}
@@ -0,0 +1,50 @@
+package textutil
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// blockElements lists HTML elements that should be separated by a line break when converted to text.
+var blockElements = map[string]bool{
+	"address": true, "article": true, "blockquote": true, "br": true, "dd": true, "div": true,
+	"dl": true, "dt": true, "footer": true, "h1": true, "h2": true, "h3": true, "h4": true,
+	"h5": true, "h6": true, "header": true, "hr": true, "li": true, "ol": true, "p": true,
+	"pre": true, "section": true, "table": true, "td": true, "th": true, "tr": true, "ul": true,
+}
+
+// StripHTML converts an HTML or XHTML fragment to plain text.
+// Tags are removed, entities are unescaped, and block-level elements are separated by line breaks.
+// The contents of script and style elements are discarded.
+func StripHTML(s string) string {
+	var sb strings.Builder
+	z := html.NewTokenizer(strings.NewReader(s))
+	skip := 0
+
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			return Normalize(sb.String())
+		case html.TextToken:
+			if skip == 0 {
+				sb.Write(z.Text())
+			}
+		case html.StartTagToken, html.SelfClosingTagToken, html.EndTagToken:
+			name, _ := z.TagName()
+			tag := string(name)
+			if tag == "script" || tag == "style" {
+				if tt == html.StartTagToken {
+					skip++
+				} else if tt == html.EndTagToken && skip > 0 {
+					skip--
+				}
+				continue
+			}
+			if blockElements[tag] {
+				sb.WriteByte('\n')
+			}
+		}
+	}
+}
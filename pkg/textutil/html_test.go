@@ -0,0 +1,55 @@
+package textutil
+
+import "testing"
+
+func TestStripHTML(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "block elements are separated by a blank line",
+			in:   `<div><p>Findings: none</p><p>Impression: normal</p></div>`,
+			want: "Findings: none\n\nImpression: normal",
+		},
+		{
+			name: "inline elements do not break lines",
+			in:   `<p>No <b>acute</b> <i>findings</i>.</p>`,
+			want: "No acute findings.",
+		},
+		{
+			name: "line breaks and list items",
+			in:   `Result:<br/>normal<ul><li>one</li><li>two</li></ul>`,
+			want: "Result:\nnormal\n\none\n\ntwo",
+		},
+		{
+			name: "entities are unescaped",
+			in:   `<p>size &lt; 5&nbsp;mm &amp; stable</p>`,
+			want: "size < 5 mm & stable",
+		},
+		{
+			name: "script and style contents are dropped",
+			in:   `<style>p { color: red }</style><p>Text</p><script>alert(1)</script>`,
+			want: "Text",
+		},
+		{
+			name: "XHTML narrative",
+			in:   `<div xmlns="http://www.w3.org/1999/xhtml"><h1>CT Head</h1><table><tr><td>A</td><td>B</td></tr></table></div>`,
+			want: "CT Head\n\nA\n\nB",
+		},
+		{
+			name: "empty blocks are collapsed",
+			in:   `<p>one</p><p></p><p></p><p>two</p>`,
+			want: "one\n\ntwo",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := StripHTML(tt.in); got != tt.want {
+				t.Errorf("StripHTML(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
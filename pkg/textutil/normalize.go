@@ -0,0 +1,30 @@
+// Package textutil provides helpers for extracting plain text from common document formats
+// such as HTML, RTF and PDF.
+package textutil
+
+import "strings"
+
+// Normalize collapses runs of horizontal whitespace, trims each line, and removes
+// consecutive blank lines so that extracted text is compact but keeps its paragraph breaks.
+func Normalize(s string) string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	s = strings.ReplaceAll(s, "\r", "\n")
+
+	lines := strings.Split(s, "\n")
+	out := make([]string, 0, len(lines))
+	blank := false
+	for _, line := range lines {
+		line = strings.Join(strings.Fields(line), " ")
+		if line == "" {
+			if !blank && len(out) > 0 {
+				out = append(out, "")
+			}
+			blank = true
+			continue
+		}
+		out = append(out, line)
+		blank = false
+	}
+
+	return strings.TrimSpace(strings.Join(out, "\n"))
+}
@@ -0,0 +1,36 @@
+package textutil
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// ExtractPDF extracts the plain text content of all pages of a PDF document.
+// Malformed documents are reported as errors rather than panics.
+func ExtractPDF(data []byte) (text string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("malformed pdf: %v", r)
+		}
+	}()
+
+	reader, err := pdf.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", fmt.Errorf("open pdf: %w", err)
+	}
+
+	plain, err := reader.GetPlainText()
+	if err != nil {
+		return "", fmt.Errorf("extract pdf text: %w", err)
+	}
+
+	b, err := io.ReadAll(plain)
+	if err != nil {
+		return "", fmt.Errorf("read pdf text: %w", err)
+	}
+
+	return Normalize(string(b)), nil
+}
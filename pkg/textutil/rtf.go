@@ -0,0 +1,157 @@
+package textutil
+
+import (
+	"strconv"
+	"strings"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// rtfSkipDestinations lists RTF destinations whose content is not part of the document text.
+var rtfSkipDestinations = map[string]bool{
+	"colortbl": true, "datastore": true, "fonttbl": true, "footer": true, "generator": true,
+	"header": true, "info": true, "latentstyles": true, "listoverridetable": true,
+	"listtable": true, "object": true, "pict": true, "rsidtbl": true, "stylesheet": true,
+	"themedata": true, "xmlnstbl": true,
+}
+
+// rtfCodePages maps the \ansicpg code pages of single-byte encodings to their character maps.
+// Hex escapes of documents in other code pages are decoded as Windows-1252, the default.
+var rtfCodePages = map[int]*charmap.Charmap{
+	437: charmap.CodePage437, 850: charmap.CodePage850, 874: charmap.Windows874,
+	1250: charmap.Windows1250, 1251: charmap.Windows1251, 1252: charmap.Windows1252,
+	1253: charmap.Windows1253, 1254: charmap.Windows1254, 1255: charmap.Windows1255,
+	1256: charmap.Windows1256, 1257: charmap.Windows1257, 1258: charmap.Windows1258,
+}
+
+// StripRTF converts an RTF document to plain text.
+// Control words are removed, paragraph and line breaks are preserved, and hex and unicode
+// escapes are decoded. Hex escapes are bytes in the code page declared by \ansicpg, and the
+// fallback characters after unicode escapes are dropped as declared by \uc. Non-text destinations
+// such as font tables and pictures are skipped.
+func StripRTF(s string) string {
+	var sb strings.Builder
+
+	type group struct {
+		skip bool
+		uc   int // number of fallback characters following a \u escape
+	}
+	stack := []group{{uc: 1}}
+	skip := func() bool { return stack[len(stack)-1].skip }
+	pendingSkip := 0 // characters to drop after a \u escape
+	codePage := charmap.Windows1252
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch c {
+		case '{':
+			stack = append(stack, stack[len(stack)-1])
+		case '}':
+			if len(stack) > 1 {
+				stack = stack[:len(stack)-1]
+			}
+		case '\r', '\n':
+			// Raw line breaks are not significant in RTF.
+		case '\\':
+			if i+1 >= len(s) {
+				break
+			}
+			next := s[i+1]
+			switch {
+			case next == '\\' || next == '{' || next == '}':
+				if !skip() {
+					sb.WriteByte(next)
+				}
+				i++
+			case next == '*':
+				stack[len(stack)-1].skip = true
+				i++
+			case next == '~':
+				if !skip() {
+					sb.WriteByte(' ')
+				}
+				i++
+			case next == '\'':
+				if i+3 < len(s) {
+					if b, err := strconv.ParseUint(s[i+2:i+4], 16, 8); err == nil && !skip() {
+						if pendingSkip > 0 {
+							pendingSkip--
+						} else {
+							sb.WriteRune(codePage.DecodeByte(byte(b)))
+						}
+					}
+				}
+				i += 3
+			case isASCIILetter(next):
+				j := i + 1
+				for j < len(s) && isASCIILetter(s[j]) {
+					j++
+				}
+				word := s[i+1 : j]
+				k := j
+				if k < len(s) && (s[k] == '-' || isASCIIDigit(s[k])) {
+					k++
+					for k < len(s) && isASCIIDigit(s[k]) {
+						k++
+					}
+				}
+				param := s[j:k]
+				if k < len(s) && s[k] == ' ' {
+					k++
+				}
+				i = k - 1
+
+				if rtfSkipDestinations[word] {
+					stack[len(stack)-1].skip = true
+					continue
+				}
+				if skip() {
+					continue
+				}
+				switch word {
+				case "ansicpg":
+					if n, err := strconv.Atoi(param); err == nil && rtfCodePages[n] != nil {
+						codePage = rtfCodePages[n]
+					}
+				case "uc":
+					if n, err := strconv.Atoi(param); err == nil && n >= 0 {
+						stack[len(stack)-1].uc = n
+					}
+				case "par", "line", "sect", "page", "row":
+					sb.WriteByte('\n')
+				case "tab", "cell":
+					sb.WriteByte('\t')
+				case "u":
+					if n, err := strconv.Atoi(param); err == nil {
+						if n < 0 {
+							n += 65536
+						}
+						sb.WriteRune(rune(n))
+						pendingSkip = stack[len(stack)-1].uc
+					}
+				}
+			default:
+				i++
+			}
+		default:
+			if skip() {
+				continue
+			}
+			if pendingSkip > 0 {
+				pendingSkip--
+				continue
+			}
+			sb.WriteByte(c)
+		}
+	}
+
+	return Normalize(sb.String())
+}
+
+func isASCIILetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isASCIIDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
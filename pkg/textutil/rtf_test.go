@@ -0,0 +1,70 @@
+package textutil
+
+import "testing"
+
+func TestStripRTF(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "paragraphs and tabs",
+			in:   `{\rtf1\ansi{\fonttbl{\f0 Arial;}}\f0\fs20 Findings:\tab none\par Impression: normal\line end}`,
+			want: "Findings: none\nImpression: normal\nend",
+		},
+		{
+			name: "escaped braces and backslash",
+			in:   `{\rtf1 a \{b\} c\\d}`,
+			want: `a {b} c\d`,
+		},
+		{
+			name: "hex escapes default to Windows-1252",
+			in:   `{\rtf1\ansi caf\'e9 \'93quoted\'94}`,
+			want: "café “quoted”",
+		},
+		{
+			name: "hex escapes in the declared code page",
+			in:   `{\rtf1\ansi\ansicpg1251 \'cf\'f0\'e8}`,
+			want: "При",
+		},
+		{
+			name: "unicode escape with one fallback character",
+			in:   `{\rtf1 5\u8201?mm}`,
+			want: "5 mm",
+		},
+		{
+			name: "unicode escape with uc fallback count",
+			in:   `{\rtf1\uc2 \u8364\'80\'80 total}`,
+			want: "€ total",
+		},
+		{
+			name: "uc without fallback characters",
+			in:   `{\rtf1\uc0 \u946\u947 ratio}`,
+			want: "βγratio",
+		},
+		{
+			name: "uc is scoped to its group",
+			in:   `{\rtf1{\uc2 \u945xx}\u946x}`,
+			want: "αβ",
+		},
+		{
+			name: "negative unicode values",
+			in:   `{\rtf1 \u-3913?}`,
+			want: "\uf0b7",
+		},
+		{
+			name: "ignorable destinations are skipped",
+			in:   `{\rtf1{\*\generator Writer;}{\info{\author X}}{\pict abcd}Text}`,
+			want: "Text",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := StripRTF(tt.in); got != tt.want {
+				t.Errorf("StripRTF(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
@@ -4,16 +4,20 @@ import mdiMagnify from '@iconify-icons/mdi/magnify';
 import useTermSuggestions from '@/hooks/useTermSuggestions';
 
 import type { FormEvent } from 'react';
+import type { TermSuggestion } from '@/hooks/useTermSuggestions';
 
 interface Props {
 	className?: string;
 	query: string;
 	setQuery: (value: string) => void;
+	modality?: string;
+	/** Called instead of `setQuery` when a suggestion is selected. */
+	onSelect?: (suggestion: TermSuggestion) => void;
 	onSubmit: (e: FormEvent) => void;
 }
 
-export default function SearchBar({ query, setQuery, onSubmit }: Props) {
-	const { suggestions } = useTermSuggestions(query);
+export default function SearchBar({ query, setQuery, modality, onSelect, onSubmit }: Props) {
+	const { suggestions } = useTermSuggestions(query, modality);
 	const [selectedIndex, setSelectedIndex] = useState(-1);
 	const containerRef = useRef<HTMLDivElement>(null);
 	const [showSuggestions, setShowSuggestions] = useState(false);
@@ -22,6 +26,14 @@ export default function SearchBar({ query, setQuery, onSubmit }: Props) {
 		setSelectedIndex(-1);
 	}, [query]);
 
+	const select = (suggestion: TermSuggestion) => {
+		if (onSelect) {
+			onSelect(suggestion);
+		} else {
+			setQuery(suggestion.text);
+		}
+	};
+
 	useEffect(() => {
 		const handleClickOutside = (event: MouseEvent) => {
 			if (containerRef.current) {
@@ -63,7 +75,7 @@ export default function SearchBar({ query, setQuery, onSubmit }: Props) {
 								e.preventDefault();
 								const selected = suggestions[selectedIndex];
 								if (selected) {
-									setQuery(selected);
+									select(selected);
 								}
 							}
 						}}
@@ -87,12 +99,12 @@ export default function SearchBar({ query, setQuery, onSubmit }: Props) {
 							className={`cursor-pointer px-4 py-2 hover:bg-gray-100 ${index === selectedIndex ? 'bg-gray-200' : ''}`}
 							onMouseEnter={() => setSelectedIndex(index)}
 							onClick={() => {
-								setQuery(suggestion);
+								select(suggestion);
 								setSelectedIndex(-1);
 								setShowSuggestions(true);
 							}}
 						>
-							{suggestion}
+							{suggestion.label}
 						</li>
 					))}
 				</ul>
@@ -4,6 +4,7 @@ import mdiImageBrokenVariant from '@iconify-icons/mdi/image-broken-variant';
 import mdiImageOff from '@iconify-icons/mdi/image-off';
 import { useState } from 'react';
 
+import { apiBase } from '@/configs/path';
 import type { Result } from '@/models/search';
 
 export interface SearchResultItemProps {
@@ -20,9 +21,8 @@ export default function SearchResultItem({ result }: SearchResultItemProps) {
 	return (
 		<div className="flex cursor-pointer gap-4 p-3 transition hover:bg-gray-100">
 			<div className="relative flex h-32 w-28 shrink-0 items-center justify-center overflow-hidden rounded bg-gray-200 text-gray-400">
-				{document.type === 'report' ? (
+				{document.type !== 'report' ? (
 					<>
-						{/* TODO: determine thumbnail availability here */}
 						{!isThumbnailLoaded && (
 							<div className="absolute inset-0 flex items-center justify-center bg-transparent">
 								<Icon
@@ -32,8 +32,9 @@ export default function SearchResultItem({ result }: SearchResultItemProps) {
 							</div>
 						)}
 						<img
-							src={`https://picsum.photos/seed/${result.document.id}/200`}
+							src={`${apiBase}/documents/${encodeURIComponent(document.id)}/thumbnail?size=256`}
 							alt="thumbnail"
+							loading="lazy"
 							draggable="false"
 							className={`h-full w-full object-cover transition-opacity ${isThumbnailLoaded ? 'opacity-100' : 'opacity-0'}`}
 							onLoad={() => setIsThumbnailLoaded(true)}
@@ -27,6 +27,8 @@ export default function useQuery(): [Query, Dispatch<SetStateAction<Partial<Quer
 		return {
 			...{
 				search: params.get('q') ?? '',
+				concept: params.get('concept') ?? undefined,
+				autocorrect: params.get('autocorrect') === 'false' ? false : undefined,
 				type: params.get('type') ?? undefined,
 				modality: params.get('modality') ?? undefined,
 				patientId: params.get('patientId') ?? undefined,
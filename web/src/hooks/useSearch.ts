@@ -1,7 +1,7 @@
 import { useEffect, useState } from 'react';
 import { apiBase } from '@/configs/path';
 
-import type { Query, Result } from '@/models/search';
+import type { Expansion, Query, Result } from '@/models/search';
 
 /**
  * Custom React hook to perform search queries against the `/api/search` endpoint.
@@ -10,11 +10,19 @@ import type { Query, Result } from '@/models/search';
  * @returns An object containing:
  *
  *   - `data`: Array of search results (Document and score).
+ *   - `total`: The number of documents matching the query.
+ *   - `expansion`: The concepts a concept search was expanded to, if any.
+ *   - `didYouMean`: The spelling correction of the search text, if any.
+ *   - `corrected`: Whether the results are for `didYouMean` instead of the search text.
  *   - `loading`: Whether the request is in progress.
  *   - `error`: Any error encountered during the fetch.
  */
 export default function useSearch(query: Query) {
 	const [data, setData] = useState<Result[]>([]);
+	const [total, setTotal] = useState(0);
+	const [expansion, setExpansion] = useState<Expansion | undefined>();
+	const [didYouMean, setDidYouMean] = useState<string | undefined>();
+	const [corrected, setCorrected] = useState(false);
 	const [loading, setLoading] = useState(false);
 	const [error, setError] = useState<Error | null>(null);
 
@@ -33,8 +41,12 @@ export default function useSearch(query: Query) {
 		});
 		fetch(`${apiBase}/search?${params.toString()}`)
 			.then((res) => res.json())
-			.then((data) => {
-				setData(data || []);
+			.then((res) => {
+				setData(res.results || []);
+				setTotal(res.total || 0);
+				setExpansion(res.expansion);
+				setDidYouMean(res.didYouMean);
+				setCorrected(res.corrected ?? false);
 				setLoading(false);
 			})
 			.catch((err) => {
@@ -43,5 +55,5 @@ export default function useSearch(query: Query) {
 			});
 	}, [query]);
 
-	return { data, loading, error };
+	return { data, total, expansion, didYouMean, corrected, loading, error };
 }
@@ -1,28 +1,45 @@
 import { useEffect, useState } from 'react';
 import { apiBase } from '@/configs/path';
 
+/** An autocomplete suggestion for the search box. */
+export interface TermSuggestion {
+	/** The text inserted into the search box. */
+	text: string;
+	/** The text displayed in the suggestion list. */
+	label: string;
+	category?: string;
+	/** The `system|code` token of the concept the term names, if it is coded. */
+	coding?: string;
+}
+
 /**
  * Custom React hook to fetch term suggestions for a given query.
  *
  * @param {string} query - The search term used to fetch suggestions.
- * @returns {{ suggestions: string[]; loading: boolean }} An object containing the fetched suggestions and loading
+ * @param {string} [modality] - The modality the suggestions are restricted to.
+ * @returns {{ suggestions: TermSuggestion[]; loading: boolean }} An object containing the fetched suggestions and loading
  *   state.
  */
-export default function useTermSuggestions(query: string): { suggestions: string[]; loading: boolean } {
-	const [suggestions, setSuggestions] = useState<string[]>([]);
+export default function useTermSuggestions(
+	query: string,
+	modality?: string,
+): { suggestions: TermSuggestion[]; loading: boolean } {
+	const [suggestions, setSuggestions] = useState<TermSuggestion[]>([]);
 	const [loading, setLoading] = useState(false);
 
 	useEffect(() => {
 		if (!query) return;
 		setLoading(true);
-		fetch(`${apiBase}/terms/suggest?q=${encodeURIComponent(query)}`)
+		const params = new URLSearchParams({ q: query });
+		if (modality) params.set('modality', modality);
+		fetch(`${apiBase}/terms/suggest?${params}`)
 			.then((res) => res.json())
 			.then((res) => {
 				setSuggestions(res.results || []);
 				setLoading(false);
 			})
 			.catch(() => setLoading(false));
-	}, [query]);
+	}, [query, modality]);
 
 	return { suggestions, loading };
 }
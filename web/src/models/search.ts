@@ -10,12 +10,59 @@ export interface Document {
 	categories: string[];
 	reportText: string;
 	impression: string;
+	status?: string;
+	codes?: string[];
+	codeDisplay?: string;
+	performers?: string[];
+	performerIds?: string[];
+	issued?: string;
+	encounterId?: string;
+	accessionNumbers?: string[];
+	studyDescription?: string;
+	referringPhysician?: string;
+	institution?: string;
+	bodyParts?: string[];
+	modalities?: string[];
+	seriesCount?: number;
+	instanceCount?: number;
+	seriesDescriptions?: string[];
 }
 
 /** Represents a single search result with relevance score. */
 export interface Result {
 	document: Document;
 	score: number;
+	/** The clauses of a concept search the document matched, e.g. `code:...` or `text:...`. */
+	matched?: string[];
+}
+
+/** A concept a concept search was expanded to. */
+export interface ExpandedConcept {
+	/** The `system|code` token of the concept. */
+	coding: string;
+	term?: string;
+	synonyms?: string[];
+	/** 0 for the searched concept, 1 for its children, and so on. */
+	depth: number;
+}
+
+/** Explains which concepts a concept search matched documents by. */
+export interface Expansion {
+	concept: string;
+	concepts: ExpandedConcept[];
+	/** Whether descendants were left out to bound the search. */
+	truncated: boolean;
+}
+
+/** A page of search results. */
+export interface SearchResponse {
+	results: Result[];
+	total: number;
+	expansion?: Expansion;
+	/** The spelling correction of the search text. */
+	didYouMean?: string;
+	/** Whether the results are for `didYouMean`, as the search text matched nothing. */
+	corrected?: boolean;
 }
 
 /** Represents a category and the number of documents in that category. */
@@ -27,6 +74,10 @@ export interface CategoryBucket {
 /** Parameters used to query the search API. */
 export interface Query {
 	search: string;
+	/** The `system|code` token of a concept to search for instead of the search text. */
+	concept?: string;
+	/** Whether a search without results is retried with its spelling correction; defaults to true. */
+	autocorrect?: boolean;
 	type?: string;
 	modality?: string;
 	patientId?: string;
@@ -35,6 +86,13 @@ export interface Query {
 	toDate?: string;
 	gender?: string[];
 	category: string[];
+	bodyPart?: string[];
+	institution?: string;
+	status?: string[];
+	code?: string[];
+	performer?: string[];
+	accession?: string;
+	encounterId?: string;
 	limit?: number;
 	offset?: number;
 }
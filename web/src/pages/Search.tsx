@@ -19,11 +19,19 @@ export default function Search() {
 	const [showSidebar, setShowSidebar] = useState(false);
 	const [, setParams] = useSearchParams();
 
-	const { data, loading, error } = useSearch(submittedQuery);
+	const { data, expansion, didYouMean, corrected, loading, error } = useSearch(submittedQuery);
+	const related = expansion?.concepts.filter((concept) => concept.depth > 0) ?? [];
 
 	const handleSearch = (e: FormEvent) => {
 		e.preventDefault();
-		setSubmittedQuery(query);
+		setSubmittedQuery({ ...query, autocorrect: undefined });
+	};
+
+	// Searches for the given text, e.g. a spelling correction, as if it was typed in
+	const searchFor = (search: string, autocorrect?: boolean) => {
+		const next = { ...query, search, concept: undefined, autocorrect };
+		setQuery(next);
+		setSubmittedQuery(next);
 	};
 
 	// Update the URL parameters when the query changes
@@ -32,6 +40,8 @@ export default function Search() {
 			if (!query.search) return;
 			const newParams: Record<string, string | string[]> = {};
 			if (query.search) newParams.q = query.search;
+			if (query.concept) newParams.concept = query.concept;
+			if (query.autocorrect === false) newParams.autocorrect = 'false';
 			if (query.type) newParams.type = query.type;
 			if (query.modality) newParams.modality = query.modality;
 			if (query.patientId) newParams.patientId = query.patientId;
@@ -58,7 +68,13 @@ export default function Search() {
 						</a>
 					</div>
 					<div className="flex-grow px-2">
-						<SearchBar query={query.search} setQuery={(search) => setQuery({ search })} onSubmit={handleSearch} />
+						<SearchBar
+							query={query.search}
+							setQuery={(search) => setQuery({ search, concept: undefined })}
+							modality={query.modality}
+							onSelect={(suggestion) => setQuery({ search: suggestion.text, concept: suggestion.coding })}
+							onSubmit={handleSearch}
+						/>
 					</div>
 				</div>
 			</header>
@@ -108,6 +124,41 @@ export default function Search() {
 								{showSidebar ? 'Hide Filters' : 'Show Filters'}
 							</button>
 						</div>
+						{!loading && didYouMean && (
+							<div className="px-2 text-sm text-gray-600">
+								{corrected ? (
+									<>
+										Showing results for <span className="font-medium italic">{didYouMean}</span>. Search instead for{' '}
+										<button
+											type="button"
+											className="cursor-pointer text-[#24808B] underline"
+											onClick={() => searchFor(submittedQuery.search, false)}
+										>
+											{submittedQuery.search}
+										</button>
+									</>
+								) : (
+									<>
+										Did you mean{' '}
+										<button
+											type="button"
+											className="cursor-pointer font-medium text-[#24808B] italic underline"
+											onClick={() => searchFor(didYouMean)}
+										>
+											{didYouMean}
+										</button>
+										?
+									</>
+								)}
+							</div>
+						)}
+						{!loading && related.length > 0 && (
+							<div className="px-2 text-sm text-gray-500">
+								Including {related.length}
+								{expansion?.truncated ? '+' : ''} narrower concepts:{' '}
+								{related.map((concept) => concept.term || concept.coding).join(', ')}
+							</div>
+						)}
 						<div className="space-y-2">
 							{loading ? (
 								<div className="bg-white py-8 text-center text-gray-500 select-none">Loading results...</div>